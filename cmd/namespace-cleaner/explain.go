@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/StatCan/namespace-cleaner/internal/cleaner"
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+)
+
+// explain prints why a namespace will or will not be cleaned and returns
+// the process exit code. Nothing is changed in the cluster.
+func explain(ctx context.Context, cfg *config.Config, args []string, out io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(out, "Usage: namespace-cleaner explain <namespace>")
+		return 2
+	}
+
+	rules, err := policy.Load(cfg)
+	if err != nil {
+		fmt.Fprintf(out, "Invalid policy file: %v\n", err)
+		return 1
+	}
+
+	kubeClient := clients.NewKubeClient()
+	dynamicClient := clients.NewDynamicClient()
+	if policies := policy.NewClusterSource(cfg, dynamicClient, nil, rules); policies != nil {
+		if err := policies.Refresh(ctx); err != nil {
+			fmt.Fprintf(out, "Error reading cleanup policies: %v\n", err)
+			return 1
+		}
+	}
+	nsCleaner := cleaner.NewCleaner(cfg, kubeClient, dynamicClient, nil, nil, nil, rules)
+
+	explanation, err := cleaner.Explain(ctx, nsCleaner, clients.NewGraphClient(cfg), kubeClient, cfg, args[0], time.Now())
+	if err != nil {
+		fmt.Fprintf(out, "Error explaining namespace %s: %v\n", args[0], err)
+		return 1
+	}
+	if err := explanation.Write(out); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestExplain(t *testing.T) {
+	origKubeClient := clients.NewKubeClient
+	origDynamicClient := clients.NewDynamicClient
+	defer func() {
+		clients.NewKubeClient = origKubeClient
+		clients.NewDynamicClient = origDynamicClient
+	}()
+
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "team-ns",
+			Labels:      map[string]string{"app.kubeflow.org/part-of": "kubeflow-profile"},
+			Annotations: map[string]string{"owner": "user@example.com"},
+		},
+	})
+	clients.NewKubeClient = func() kubernetes.Interface { return client }
+	clients.NewDynamicClient = func() dynamic.Interface {
+		return dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	}
+
+	cfg := &config.Config{TestMode: true, TestUsers: []string{"user@example.com"}, AllowedDomains: []string{"example.com"}}
+
+	var out bytes.Buffer
+	if code := explain(context.TODO(), cfg, []string{"team-ns"}, &out); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "skipped (owner_exists)") {
+		t.Errorf("Expected the owner_exists outcome, got:\n%s", out.String())
+	}
+
+	ns, _ := client.CoreV1().Namespaces().Get(context.TODO(), "team-ns", metav1.GetOptions{})
+	if len(ns.Labels) != 1 {
+		t.Errorf("Explain must not change the namespace, got labels %v", ns.Labels)
+	}
+
+	out.Reset()
+	if code := explain(context.TODO(), cfg, nil, &out); code != 2 {
+		t.Errorf("Expected usage error without a namespace, got %d", code)
+	}
+}
@@ -2,24 +2,180 @@ package main
 
 import (
 	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/StatCan/namespace-cleaner/internal/admission"
+	"github.com/StatCan/namespace-cleaner/internal/audit"
 	"github.com/StatCan/namespace-cleaner/internal/cleaner"
 	"github.com/StatCan/namespace-cleaner/internal/clients"
 	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/metrics"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/internal/report"
+	"github.com/StatCan/namespace-cleaner/internal/tracing"
+	"github.com/StatCan/namespace-cleaner/internal/webhook"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
+	logging.Setup(cfg)
 
-	// Initialize clients
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify-audit":
+			os.Exit(verifyAudit(context.Background(), cfg, os.Args[2:], os.Stdout))
+		case "explain":
+			os.Exit(explain(context.Background(), cfg, os.Args[2:], os.Stdout))
+		}
+	}
+
+	// Initialize tracing before the clients so their requests are traced
 	ctx := context.Background()
+	shutdownTracing, err := tracing.Setup(ctx, cfg)
+	if err != nil {
+		logging.Fatal("Invalid tracing settings", logging.KeyError, err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Error flushing traces", logging.KeyError, err)
+		}
+	}()
+
+	// Initialize clients
 	graphClient := clients.NewGraphClient(cfg)
 	kubeClient := clients.NewKubeClient()
+	dynamicClient := clients.NewDynamicClient()
+
+	ownerNotifier, err := notifier.New(cfg)
+	if err != nil {
+		logging.Fatal("Failed to load notification templates", logging.KeyError, err)
+	}
+	reporter, err := webhook.New(cfg)
+	if err != nil {
+		logging.Fatal("Invalid webhook settings", logging.KeyError, err)
+	}
+	reportWriter, err := report.NewWriter(cfg, kubeClient)
+	if err != nil {
+		logging.Fatal("Invalid report settings", logging.KeyError, err)
+	}
+
+	admissionServer, err := admission.New(cfg, graphClient)
+	if err != nil {
+		logging.Fatal("Invalid admission webhook settings", logging.KeyError, err)
+	}
+
+	auditor, err := audit.New(cfg, kubeClient)
+	if err != nil {
+		logging.Fatal("Invalid audit settings", logging.KeyError, err)
+	}
+
+	rules, err := policy.Load(cfg)
+	if err != nil {
+		logging.Fatal("Invalid policy file", logging.KeyError, err)
+	}
+	for _, dir := range rules.TemplateDirs() {
+		if err := ownerNotifier.LoadTemplateDir(dir); err != nil {
+			logging.Fatal("Failed to load policy notification templates", logging.KeyError, err)
+		}
+	}
+	policies := policy.NewClusterSource(cfg, dynamicClient, ownerNotifier, rules)
 
 	// Create cleaner based on dry-run setting
-	nsCleaner := cleaner.NewCleaner(cfg.DryRun, kubeClient)
+	recorder := clients.NewEventRecorder(kubeClient)
+	nsCleaner := cleaner.NewCleaner(cfg, kubeClient, dynamicClient, ownerNotifier, recorder, auditor, rules)
+
+	if cfg.RunMode == config.RunModeController {
+		runController(ctx, cfg, nsCleaner, graphClient, kubeClient, policies, admissionServer, reporter, reportWriter)
+		return
+	}
+
+	run(ctx, cfg, nsCleaner, graphClient, kubeClient, policies, reporter, reportWriter)
+
+	// Push metrics so alerts survive the Job pod
+	if cfg.PushgatewayURL != "" {
+		if err := metrics.Push(ctx, cfg.PushgatewayURL); err != nil {
+			slog.Error("Error pushing metrics", logging.KeyError, err)
+		}
+	}
+}
+
+// runController serves metrics and the admission webhook, and runs the
+// cleaner every RunInterval and whenever a cleanup policy changes, until
+// the process is asked to stop
+func runController(
+	ctx context.Context,
+	cfg *config.Config,
+	nsCleaner cleaner.NamespaceCleaner,
+	graphClient *msgraphsdk.GraphServiceClient,
+	kubeClient kubernetes.Interface,
+	policies *policy.ClusterSource,
+	admissionServer *admission.Server,
+	reporter *webhook.Reporter,
+	reportWriter *report.Writer,
+) {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := metrics.Serve(cfg.MetricsAddr)
+	defer server.Shutdown(context.Background())
+	if admissionServer != nil {
+		webhookServer := admissionServer.Start()
+		defer webhookServer.Shutdown(context.Background())
+	}
+
+	ticker := time.NewTicker(cfg.RunInterval)
+	defer ticker.Stop()
+
+	// A nil channel never fires when policies are not read from the cluster
+	var policyChanges <-chan struct{}
+	if policies != nil {
+		policyChanges = policies.Watch(ctx)
+	}
+
+	for {
+		run(ctx, cfg, nsCleaner, graphClient, kubeClient, policies, reporter, reportWriter)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-policyChanges:
+			slog.Info("Cleanup policies changed, running again")
+		}
+	}
+}
+
+// run processes all namespaces once and reports the outcome
+func run(
+	ctx context.Context,
+	cfg *config.Config,
+	nsCleaner cleaner.NamespaceCleaner,
+	graphClient *msgraphsdk.GraphServiceClient,
+	kubeClient kubernetes.Interface,
+	policies *policy.ClusterSource,
+	reporter *webhook.Reporter,
+	reportWriter *report.Writer,
+) {
+	start := time.Now()
+
+	// Read the cleanup policies; without them namespaces would be
+	// evaluated under the wrong settings, so the run is skipped
+	if policies != nil {
+		if err := policies.Refresh(ctx); err != nil {
+			slog.Error("Error reading cleanup policies", logging.KeyError, err)
+			return
+		}
+	}
 
 	// Execute namespace cleaning
 	stats := cleaner.ProcessNamespaces(
@@ -28,11 +184,31 @@ func main() {
 		graphClient,
 		kubeClient,
 		cfg,
-		time.Now(),
+		start,
 	)
+	end := time.Now()
+	metrics.ObserveRun(stats, start, end)
+
+	if policies != nil {
+		if err := policies.ReportStatus(ctx, stats, end); err != nil {
+			slog.Error("Error reporting cleanup policy status", logging.KeyError, err)
+		}
+	}
+
+	// Write the machine-readable report
+	if err := reportWriter.Write(ctx, report.New(stats, cfg.DryRun, end)); err != nil {
+		slog.Error("Error writing run report", logging.KeyError, err)
+	}
 
 	// Print summary if in dry-run mode
 	if cfg.DryRun {
 		stats.PrintSummary()
 	}
-}
\ No newline at end of file
+
+	// Post the run report for administrators
+	if reporter != nil {
+		if err := reporter.Send(ctx, stats); err != nil {
+			slog.Error("Error sending webhook report", logging.KeyError, err)
+		}
+	}
+}
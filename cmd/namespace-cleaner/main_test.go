@@ -5,6 +5,9 @@ import (
 	"os"
 	"testing"
 
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 
@@ -27,10 +30,12 @@ func TestMainFunction(t *testing.T) {
 	// Save original functions and restore after test
 	origGraphClient := clients.NewGraphClient
 	origKubeClient := clients.NewKubeClient
+	origDynamicClient := clients.NewDynamicClient
 	origUserExists := clients.UserExists
 	defer func() {
 		clients.NewGraphClient = origGraphClient
 		clients.NewKubeClient = origKubeClient
+		clients.NewDynamicClient = origDynamicClient
 		clients.UserExists = origUserExists
 	}()
 
@@ -41,10 +46,13 @@ func TestMainFunction(t *testing.T) {
 	clients.NewKubeClient = func() kubernetes.Interface {
 		return fake.NewSimpleClientset() // empty cluster
 	}
+	clients.NewDynamicClient = func() dynamic.Interface {
+		return dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	}
 
 	// Mock user exists function
-	clients.UserExists = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) bool {
-		return true
+	clients.UserExists = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (bool, error) {
+		return true, nil
 	}
 
 	// Run main
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+// verifyAudit checks the audit trail for gaps and tampering and returns
+// the process exit code. The trail is read from -file when given, else
+// from the configured sink.
+func verifyAudit(ctx context.Context, cfg *config.Config, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("verify-audit", flag.ContinueOnError)
+	flags.SetOutput(out)
+	file := flags.String("file", "", "JSON-lines audit export to verify instead of the configured sink")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	records, err := readAuditTrail(ctx, cfg, *file)
+	if err != nil {
+		fmt.Fprintf(out, "Error reading audit trail: %v\n", err)
+		return 2
+	}
+
+	problems := audit.Verify(records)
+	for _, problem := range problems {
+		fmt.Fprintln(out, problem)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(out, "Audit trail is NOT intact: %d problem(s) in %d record(s)\n", len(problems), len(records))
+		return 1
+	}
+	fmt.Fprintf(out, "Audit trail is intact: %d record(s)\n", len(records))
+	return 0
+}
+
+func readAuditTrail(ctx context.Context, cfg *config.Config, file string) ([]audit.Record, error) {
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return audit.ParseRecords(f)
+	}
+
+	if cfg.AuditSink == config.AuditSinkNone {
+		return nil, fmt.Errorf("auditing is off, set AUDIT_SINK or pass -file")
+	}
+	// File trails can be checked away from the cluster
+	var kubeClient kubernetes.Interface
+	if cfg.AuditSink != config.AuditSinkFile {
+		kubeClient = clients.NewKubeClient()
+	}
+	store, err := audit.NewStore(cfg, kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	return store.Records(ctx)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestVerifyAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	cfg := &config.Config{AuditSink: config.AuditSinkFile, AuditFile: path, AuditActor: "tester"}
+	auditor, err := audit.New(cfg, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for _, ns := range []string{"ns-a", "ns-b", "ns-c"} {
+		if err := auditor.Record(context.TODO(), audit.Entry{Action: audit.ActionDelete, Namespace: ns}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	if code := verifyAudit(context.TODO(), cfg, nil, &out); code != 0 {
+		t.Errorf("Expected exit code 0, got %d: %s", code, out.String())
+	}
+
+	// Tamper with the export and check it with -file
+	data, _ := os.ReadFile(path)
+	tampered := filepath.Join(t.TempDir(), "tampered.jsonl")
+	os.WriteFile(tampered, []byte(strings.Replace(string(data), "ns-b", "ns-x", 1)), 0o600)
+
+	out.Reset()
+	if code := verifyAudit(context.TODO(), &config.Config{}, []string{"-file", tampered}, &out); code != 1 {
+		t.Errorf("Expected exit code 1, got %d: %s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "record 2: hash mismatch") {
+		t.Errorf("Expected the altered record to be reported, got %s", out.String())
+	}
+
+	out.Reset()
+	if code := verifyAudit(context.TODO(), &config.Config{AuditSink: config.AuditSinkNone}, nil, &out); code != 2 {
+		t.Errorf("Expected exit code 2 without a sink, got %d", code)
+	}
+}
@@ -0,0 +1,259 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/metrics"
+	"github.com/StatCan/namespace-cleaner/internal/owner"
+)
+
+// Paths the webhooks are served on
+const (
+	PathValidate = "/validate"
+	PathMutate   = "/mutate"
+)
+
+// Results of a review, as recorded in metrics
+const (
+	resultAllowed = "allowed"
+	resultWarned  = "warned"
+	resultDenied  = "denied"
+	resultStamped = "stamped"
+)
+
+// ownerAnnotation holds the namespace owner's email, as read by the cleaner
+const ownerAnnotation = "owner"
+
+// maxReviewSize caps the admission review bodies read
+const maxReviewSize = 1 << 20
+
+// Server answers the API server's admission reviews for namespaces
+type Server struct {
+	cfg        *config.Config
+	graph      *msgraphsdk.GraphServiceClient
+	selector   labels.Selector
+	deny       bool
+	checkOwner bool
+	ownerRules []ownerRule
+}
+
+// New creates the admission server from configuration. It returns nil
+// when no admission address is configured, which turns the webhook off.
+func New(cfg *config.Config, graph *msgraphsdk.GraphServiceClient) (*Server, error) {
+	if cfg.AdmissionAddr == "" {
+		return nil, nil
+	}
+	if cfg.RunMode != config.RunModeController {
+		return nil, errors.New("the admission webhook needs RUN_MODE=controller")
+	}
+
+	switch cfg.AdmissionMode {
+	case config.AdmissionModeDeny, config.AdmissionModeWarn:
+	default:
+		return nil, fmt.Errorf("unknown admission mode %q", cfg.AdmissionMode)
+	}
+	selector, err := labels.Parse(cfg.AdmissionSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admission selector: %w", err)
+	}
+	ownerRules, err := parseOwnerRules(cfg.AdmissionOwnerRules)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		cfg:        cfg,
+		graph:      graph,
+		selector:   selector,
+		deny:       cfg.AdmissionMode == config.AdmissionModeDeny,
+		checkOwner: cfg.AdmissionCheckOwner,
+		ownerRules: ownerRules,
+	}, nil
+}
+
+// Handler routes admission reviews to the webhooks
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(PathValidate, s.reviewHandler("validate", s.validate))
+	mux.Handle(PathMutate, s.reviewHandler("mutate", s.mutate))
+	return mux
+}
+
+// Start serves the webhooks over TLS in the background, as the API server
+// requires
+func (s *Server) Start() *http.Server {
+	server := &http.Server{Addr: s.cfg.AdmissionAddr, Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		err := server.ListenAndServeTLS(s.cfg.AdmissionCertFile, s.cfg.AdmissionKeyFile)
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Error serving admission webhook", logging.KeyError, err)
+		}
+	}()
+	return server
+}
+
+// reviewHandler decodes an admission review, answers it with review and
+// writes the response back
+func (s *Server) reviewHandler(
+	webhook string,
+	review func(context.Context, *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, string),
+) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "admission reviews must be posted", http.StatusMethodNotAllowed)
+			return
+		}
+		var in admissionv1.AdmissionReview
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxReviewSize)).Decode(&in); err != nil || in.Request == nil {
+			http.Error(w, "invalid admission review", http.StatusBadRequest)
+			return
+		}
+
+		response, result := review(r.Context(), in.Request)
+		response.UID = in.Request.UID
+		metrics.ObserveAdmission(webhook, result)
+
+		out := admissionv1.AdmissionReview{TypeMeta: in.TypeMeta, Response: response}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			slog.Error("Error writing admission response", logging.KeyError, err)
+		}
+	})
+}
+
+// validate admits namespaces matching the selector only when they are
+// created with a well-formed owner from an allowed domain, and, when
+// configured, one that exists. In warn mode they are admitted with a
+// warning instead.
+func (s *Server) validate(ctx context.Context, req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, string) {
+	allowed := &admissionv1.AdmissionResponse{Allowed: true}
+	ns, err := s.matchingNamespace(req)
+	if err != nil {
+		return deny(metav1.StatusReasonBadRequest, http.StatusBadRequest, err.Error()), resultDenied
+	}
+	if ns == nil {
+		return allowed, resultAllowed
+	}
+
+	logger := slog.With(logging.KeyNamespace, ns.Name, "user", req.UserInfo.Username)
+	problem, err := s.ownerProblem(ctx, ns.Annotations[ownerAnnotation])
+	if err != nil {
+		// The identity provider being down must not block namespace
+		// creation
+		logger.Warn("Error verifying namespace owner", logging.KeyError, err)
+		allowed.Warnings = []string{fmt.Sprintf("namespace-cleaner could not verify the owner: %v", err)}
+		return allowed, resultWarned
+	}
+	if problem == "" {
+		return allowed, resultAllowed
+	}
+
+	message := fmt.Sprintf("namespace %s: %s; it could never be cleaned up", ns.Name, problem)
+	if s.deny {
+		logger.Info("Denied namespace", "problem", problem)
+		return deny(metav1.StatusReasonForbidden, http.StatusForbidden, message), resultDenied
+	}
+	logger.Info("Admitted namespace with a warning", "problem", problem)
+	allowed.Warnings = []string{message}
+	return allowed, resultWarned
+}
+
+// matchingNamespace decodes the namespace a request creates. It returns
+// nil for other operations and namespaces not matching the selector.
+func (s *Server) matchingNamespace(req *admissionv1.AdmissionRequest) (*corev1.Namespace, error) {
+	if req.Operation != admissionv1.Create {
+		return nil, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := json.Unmarshal(req.Object.Raw, ns); err != nil {
+		return nil, fmt.Errorf("decoding namespace: %w", err)
+	}
+	if !s.selector.Matches(labels.Set(ns.Labels)) {
+		return nil, nil
+	}
+	return ns, nil
+}
+
+// ownerProblem describes what is wrong with an owner annotation, or
+// returns an empty string for a valid one. Like the cleaner, it accepts a
+// list of owners as long as one is in an allowed domain and, when
+// checked, exists. The error reports a failed owner lookup.
+func (s *Server) ownerProblem(ctx context.Context, value string) (string, error) {
+	owners, err := owner.Parse(value, s.cfg.OwnerDomainAliases)
+	if errors.Is(err, owner.ErrEmpty) {
+		return fmt.Sprintf("the %q annotation is missing", ownerAnnotation), nil
+	}
+	if err != nil {
+		return fmt.Sprintf("the %q annotation is not a valid email address list: %v", ownerAnnotation, err), nil
+	}
+
+	var allowed []string
+	var denied string
+	matches := map[string]clients.DomainMatch{}
+	for _, email := range owners {
+		match, _ := clients.MatchOwnerDomain(email, s.cfg)
+		if match.Allowed() {
+			allowed = append(allowed, email)
+			matches[email] = match
+		} else if match.Action != "" && denied == "" {
+			denied = fmt.Sprintf("owner %s is in a denied domain (%s)", email, match.Pattern)
+		}
+	}
+	if len(allowed) == 0 {
+		if denied != "" {
+			return denied, nil
+		}
+		return fmt.Sprintf("owner %s is not in an allowed domain (%s)",
+			strings.Join(owners, ", "), strings.Join(s.cfg.AllowedDomains, ", ")), nil
+	}
+	if !s.checkOwner {
+		return "", nil
+	}
+
+	for _, email := range allowed {
+		switch matches[email].Action {
+		case config.DomainActionKeep:
+			return "", nil
+		case config.DomainActionExpire:
+			continue
+		}
+		exists, err := clients.UserExists(ctx, s.cfg, s.graph, email)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("owner %s does not exist", strings.Join(allowed, ", ")), nil
+}
+
+// deny refuses a request with a status reason, code and message
+func deny(reason metav1.StatusReason, code int32, message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    code,
+			Reason:  reason,
+			Message: message,
+		},
+	}
+}
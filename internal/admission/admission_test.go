@@ -0,0 +1,191 @@
+package admission
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func newConfig(mode string) *config.Config {
+	return &config.Config{
+		AllowedDomains:    []string{"statcan.gc.ca"},
+		RunMode:           config.RunModeController,
+		AdmissionAddr:     ":8443",
+		AdmissionSelector: "app.kubeflow.org/part-of=kubeflow-profile",
+		AdmissionMode:     mode,
+	}
+}
+
+// postReview sends a namespace creation to path and returns the response
+func postReview(t *testing.T, handler http.Handler, path string, operation admissionv1.Operation, username string, ns *corev1.Namespace) *admissionv1.AdmissionResponse {
+	t.Helper()
+	raw, err := json.Marshal(ns)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "review-1",
+			Operation: operation,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var out admissionv1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("Decoding response failed: %v", err)
+	}
+	if out.Response == nil || out.Response.UID != "review-1" {
+		t.Fatalf("Expected a response to review-1, got %+v", out.Response)
+	}
+	return out.Response
+}
+
+func newNamespace(owner string, profile bool) *corev1.Namespace {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+	if profile {
+		ns.Labels = map[string]string{"app.kubeflow.org/part-of": "kubeflow-profile"}
+	}
+	if owner != "" {
+		ns.Annotations = map[string]string{"owner": owner}
+	}
+	return ns
+}
+
+func TestValidate(t *testing.T) {
+	original := clients.UserExists
+	defer func() { clients.UserExists = original }()
+	clients.UserExists = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (bool, error) {
+		switch email {
+		case "gone@statcan.gc.ca":
+			return false, nil
+		case "throttled@statcan.gc.ca":
+			return false, errors.New("throttled")
+		}
+		return true, nil
+	}
+
+	testCases := []struct {
+		name        string
+		mode        string
+		checkOwner  bool
+		operation   admissionv1.Operation
+		ns          *corev1.Namespace
+		wantAllowed bool
+		wantMessage string
+	}{
+		{"valid owner", config.AdmissionModeDeny, true, admissionv1.Create, newNamespace("user@statcan.gc.ca", true), true, ""},
+		{"subdomain owner", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("user@cloud.statcan.gc.ca", true), true, ""},
+		{"missing owner denied", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("", true), false, "annotation is missing"},
+		{"missing owner warned", config.AdmissionModeWarn, false, admissionv1.Create, newNamespace("", true), true, "annotation is missing"},
+		{"malformed owner", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("User <user@statcan.gc.ca>", true), false, "not a valid email"},
+		{"two at signs", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("a@b@statcan.gc.ca", true), false, "exactly one @"},
+		{"spaces trimmed", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace(" user@statcan.gc.ca ", true), true, ""},
+		{"mailto and case", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("mailto:User@StatCan.GC.CA", true), true, ""},
+		{"owner list", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("user@example.com, user@statcan.gc.ca", true), true, ""},
+		{"aliased domain", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("user@canada.ca", true), true, ""},
+		{"bad owner in list", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("user@statcan.gc.ca;a@@b", true), false, "exactly one @"},
+		{"existing owner in list", config.AdmissionModeDeny, true, admissionv1.Create, newNamespace("gone@statcan.gc.ca user@statcan.gc.ca", true), true, ""},
+		{"denied domain", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("user@external.statcan.gc.ca", true), false, "denied domain (external.statcan.gc.ca)"},
+		{"denied owner in list", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("user@external.statcan.gc.ca user@statcan.gc.ca", true), true, ""},
+		{"kept domain not looked up", config.AdmissionModeDeny, true, admissionv1.Create, newNamespace("gone@svc.statcan.gc.ca", true), true, ""},
+		{"expired domain", config.AdmissionModeDeny, true, admissionv1.Create, newNamespace("user@old.statcan.gc.ca", true), false, "does not exist"},
+		{"other domain", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("user@example.com", true), false, "not in an allowed domain"},
+		{"missing user", config.AdmissionModeDeny, true, admissionv1.Create, newNamespace("gone@statcan.gc.ca", true), false, "does not exist"},
+		{"missing user not checked", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("gone@statcan.gc.ca", true), true, ""},
+		{"lookup failure admitted", config.AdmissionModeDeny, true, admissionv1.Create, newNamespace("throttled@statcan.gc.ca", true), true, "could not verify"},
+		{"other namespaces", config.AdmissionModeDeny, false, admissionv1.Create, newNamespace("", false), true, ""},
+		{"updates", config.AdmissionModeDeny, false, admissionv1.Update, newNamespace("", true), true, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newConfig(tc.mode)
+			cfg.AdmissionCheckOwner = tc.checkOwner
+			cfg.OwnerDomainAliases = map[string]string{"canada.ca": "statcan.gc.ca"}
+			cfg.DeniedDomains = []string{"external.statcan.gc.ca"}
+			cfg.DomainActions = map[string]string{"svc.statcan.gc.ca": config.DomainActionKeep, "old.statcan.gc.ca": config.DomainActionExpire}
+			server, err := New(cfg, nil)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			response := postReview(t, server.Handler(), PathValidate, tc.operation, "user@statcan.gc.ca", tc.ns)
+			if response.Allowed != tc.wantAllowed {
+				t.Errorf("Expected allowed=%v, got %+v", tc.wantAllowed, response)
+			}
+			message := strings.Join(response.Warnings, "\n")
+			if response.Result != nil {
+				message = response.Result.Message
+			}
+			if (tc.wantMessage == "") != (message == "") || !strings.Contains(message, tc.wantMessage) {
+				t.Errorf("Expected a message with %q, got %q", tc.wantMessage, message)
+			}
+		})
+	}
+}
+
+func TestReviewHandlerRejectsBadRequests(t *testing.T) {
+	server, err := New(newConfig(config.AdmissionModeDeny), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathValidate, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, PathValidate, strings.NewReader(`{"kind":"AdmissionReview"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a request, got %d", rec.Code)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if server, err := New(&config.Config{}, nil); server != nil || err != nil {
+		t.Errorf("Expected the webhook to be off without an address, got %v, %v", server, err)
+	}
+
+	cronjob := newConfig(config.AdmissionModeDeny)
+	cronjob.RunMode = config.RunModeCronJob
+	badMode := newConfig("block")
+	badSelector := newConfig(config.AdmissionModeDeny)
+	badSelector.AdmissionSelector = "tier in (a"
+
+	badRule := newConfig(config.AdmissionModeDeny)
+	badRule.AdmissionOwnerRules = []string{"^(oidc:=$1"}
+
+	for name, cfg := range map[string]*config.Config{"cronjob": cronjob, "mode": badMode, "selector": badSelector, "owner rule": badRule} {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("Expected an error for the %s", name)
+		}
+	}
+}
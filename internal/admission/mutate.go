@@ -0,0 +1,119 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/owner"
+)
+
+// CreationSourceAnnotation records the user whose username the owner
+// annotation was filled in from
+const CreationSourceAnnotation = "namespace-cleaner/creation-source"
+
+// defaultOwnerRule takes usernames that are email addresses as they are,
+// with or without the "oidc:" prefix API servers commonly add
+const defaultOwnerRule = `^(?:oidc:)?([^@:]+@[^@:]+)$=$1`
+
+// ownerRule maps usernames matching pattern to template, which can refer
+// to the pattern's groups as $1 or ${name}
+type ownerRule struct {
+	pattern  *regexp.Regexp
+	template string
+}
+
+// parseOwnerRules compiles "regexp=template" rules. The template follows
+// the last "=", so patterns may hold one.
+func parseOwnerRules(rules []string) ([]ownerRule, error) {
+	if len(rules) == 0 {
+		rules = []string{defaultOwnerRule}
+	}
+	parsed := make([]ownerRule, 0, len(rules))
+	for _, rule := range rules {
+		i := strings.LastIndex(rule, "=")
+		if i < 1 || i == len(rule)-1 {
+			return nil, fmt.Errorf("owner rule %q is not regexp=template", rule)
+		}
+		pattern, err := regexp.Compile(strings.TrimSpace(rule[:i]))
+		if err != nil {
+			return nil, fmt.Errorf("owner rule %q: %w", rule, err)
+		}
+		parsed = append(parsed, ownerRule{pattern: pattern, template: strings.TrimSpace(rule[i+1:])})
+	}
+	return parsed, nil
+}
+
+// mapOwner returns the owner email the first rule matching username maps
+// it to, with its domain aliases applied. The email must be well-formed
+// and in an allowed domain.
+func (s *Server) mapOwner(username string) (string, error) {
+	for _, rule := range s.ownerRules {
+		match := rule.pattern.FindStringSubmatchIndex(username)
+		if match == nil {
+			continue
+		}
+		email := string(rule.pattern.ExpandString(nil, rule.template, username, match))
+		normalized, err := owner.Normalize(strings.ToLower(email))
+		if err != nil {
+			return "", fmt.Errorf("%q maps to %q, which is not a valid email address: %w", username, email, err)
+		}
+		email = owner.MapAlias(normalized, s.cfg.OwnerDomainAliases)
+		if match, _ := clients.MatchOwnerDomain(email, s.cfg); !match.Allowed() {
+			return "", fmt.Errorf("%q maps to %s, which is not in an allowed domain", username, email)
+		}
+		return email, nil
+	}
+	return "", fmt.Errorf("no owner rule matches %q", username)
+}
+
+// mutate fills in the owner annotation of namespaces matching the selector
+// created without one, from the username of the request, and records that
+// user as the creation source. Namespaces whose creator maps to no owner
+// are admitted unchanged, for the validating webhook to judge.
+func (s *Server) mutate(ctx context.Context, req *admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, string) {
+	allowed := &admissionv1.AdmissionResponse{Allowed: true}
+	ns, err := s.matchingNamespace(req)
+	if err != nil {
+		return deny(metav1.StatusReasonBadRequest, http.StatusBadRequest, err.Error()), resultDenied
+	}
+	if ns == nil || ns.Annotations[ownerAnnotation] != "" {
+		return allowed, resultAllowed
+	}
+
+	logger := slog.With(logging.KeyNamespace, ns.Name, "user", req.UserInfo.Username)
+	owner, err := s.mapOwner(req.UserInfo.Username)
+	if err != nil {
+		logger.Info("Not filling in the namespace owner", logging.KeyError, err)
+		return allowed, resultAllowed
+	}
+
+	annotations := map[string]string{}
+	for key, value := range ns.Annotations {
+		annotations[key] = value
+	}
+	annotations[ownerAnnotation] = owner
+	annotations[CreationSourceAnnotation] = req.UserInfo.Username
+
+	// "add" replaces the annotations when the namespace already has some
+	patch, err := json.Marshal([]map[string]interface{}{
+		{"op": "add", "path": "/metadata/annotations", "value": annotations},
+	})
+	if err != nil {
+		return deny(metav1.StatusReasonInternalError, http.StatusInternalServerError, err.Error()), resultDenied
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	allowed.Patch = patch
+	allowed.PatchType = &patchType
+	logger.Info("Filled in the namespace owner", logging.KeyOwner, owner)
+	return allowed, resultStamped
+}
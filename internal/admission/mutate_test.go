@@ -0,0 +1,94 @@
+package admission
+
+import (
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestMutate(t *testing.T) {
+	withOther := newNamespace("", true)
+	withOther.Annotations = map[string]string{"team": "das"}
+
+	testCases := []struct {
+		name      string
+		rules     []string
+		username  string
+		ns        *corev1.Namespace
+		operation admissionv1.Operation
+		wantOwner string
+	}{
+		{"email username", nil, "User@StatCan.gc.ca", newNamespace("", true), admissionv1.Create, "user@statcan.gc.ca"},
+		{"oidc prefix", nil, "oidc:user@statcan.gc.ca", newNamespace("", true), admissionv1.Create, "user@statcan.gc.ca"},
+		{"other annotations kept", nil, "user@statcan.gc.ca", withOther, admissionv1.Create, "user@statcan.gc.ca"},
+		{"service account", nil, "system:serviceaccount:kubeflow:profiles-controller", newNamespace("", true), admissionv1.Create, ""},
+		{"owner already set", nil, "user@statcan.gc.ca", newNamespace("other@statcan.gc.ca", true), admissionv1.Create, ""},
+		{"other domain", nil, "user@example.com", newNamespace("", true), admissionv1.Create, ""},
+		{"other namespaces", nil, "user@statcan.gc.ca", newNamespace("", false), admissionv1.Create, ""},
+		{"updates", nil, "user@statcan.gc.ca", newNamespace("", true), admissionv1.Update, ""},
+		{"first matching rule", []string{`^(?P<id>[a-z]+)\.(?P<last>[a-z]+)$=${id}.${last}@cloud.statcan.gc.ca`, `^(.+)$=$1@statcan.gc.ca`},
+			"jane.doe", newNamespace("", true), admissionv1.Create, "jane.doe@cloud.statcan.gc.ca"},
+		{"later rule", []string{`^(?P<id>[a-z]+)\.(?P<last>[a-z]+)$=${id}.${last}@cloud.statcan.gc.ca`, `^(.+)$=$1@statcan.gc.ca`},
+			"jdoe", newNamespace("", true), admissionv1.Create, "jdoe@statcan.gc.ca"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newConfig(config.AdmissionModeDeny)
+			cfg.AdmissionOwnerRules = tc.rules
+			server, err := New(cfg, nil)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			response := postReview(t, server.Handler(), PathMutate, tc.operation, tc.username, tc.ns)
+			if !response.Allowed {
+				t.Fatalf("Expected the namespace to be admitted, got %+v", response)
+			}
+			if tc.wantOwner == "" {
+				if response.Patch != nil {
+					t.Errorf("Expected no patch, got %s", response.Patch)
+				}
+				return
+			}
+
+			var patch []struct {
+				Op    string            `json:"op"`
+				Path  string            `json:"path"`
+				Value map[string]string `json:"value"`
+			}
+			if err := json.Unmarshal(response.Patch, &patch); err != nil || len(patch) != 1 {
+				t.Fatalf("Expected one patch operation, got %s (%v)", response.Patch, err)
+			}
+			annotations := patch[0].Value
+			if patch[0].Path != "/metadata/annotations" || annotations["owner"] != tc.wantOwner {
+				t.Errorf("Expected owner %s, got %+v", tc.wantOwner, patch[0])
+			}
+			if annotations[CreationSourceAnnotation] != tc.username {
+				t.Errorf("Expected creation source %s, got %q", tc.username, annotations[CreationSourceAnnotation])
+			}
+			for key, value := range tc.ns.Annotations {
+				if annotations[key] != value {
+					t.Errorf("Expected annotation %s=%s to be kept, got %v", key, value, annotations)
+				}
+			}
+		})
+	}
+}
+
+func TestParseOwnerRules(t *testing.T) {
+	for _, rule := range []string{"no-template", "=template", "pattern=", "([a-z]=$1"} {
+		if _, err := parseOwnerRules([]string{rule}); err == nil {
+			t.Errorf("Expected an error for %q", rule)
+		}
+	}
+
+	rules, err := parseOwnerRules([]string{`^(?:a=b)?(.+)$=$1@statcan.gc.ca`})
+	if err != nil || len(rules) != 1 || rules[0].template != "$1@statcan.gc.ca" {
+		t.Errorf("Expected the template after the last =, got %+v, %v", rules, err)
+	}
+}
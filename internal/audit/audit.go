@@ -0,0 +1,235 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+// Audited actions
+const (
+	ActionLabel    = "label"
+	ActionUnlabel  = "unlabel"
+	ActionDelete   = "delete"
+	ActionExtend   = "extend"
+	ActionTransfer = "transfer"
+)
+
+// tokenPath holds the pod's service account token, used to name the actor
+const tokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Evidence is the identity lookup an action was based on
+type Evidence struct {
+	Source    string    `json:"source"`
+	Lookup    string    `json:"lookup"`
+	Found     bool      `json:"found"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Entry is what the caller knows about an action
+type Entry struct {
+	Action    string   `json:"action"`
+	Namespace string   `json:"namespace"`
+	Owner     string   `json:"owner"`
+	Reason    string   `json:"reason"`
+	DeleteAt  string   `json:"deleteAt,omitempty"`
+	Evidence  Evidence `json:"evidence"`
+	// RequestedBy names who asked for an owner-initiated action
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// NewOwner is who a namespace was handed to
+	NewOwner string `json:"newOwner,omitempty"`
+}
+
+// Record is one link of the audit trail. Hash covers every other field,
+// including the previous record's hash, so editing, removing or
+// reordering records breaks the chain.
+type Record struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	ConfigHash string    `json:"configHash"`
+	Entry
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash"`
+}
+
+// Store persists the audit trail
+type Store interface {
+	// Head returns the last record of the trail, or nil when it is empty
+	Head(ctx context.Context) (*Record, error)
+	// Append adds a record to the end of the trail
+	Append(ctx context.Context, rec Record) error
+	// Records returns the whole trail in order
+	Records(ctx context.Context) ([]Record, error)
+}
+
+// Auditor chains and stores audit records
+type Auditor struct {
+	store      Store
+	actor      string
+	configHash string
+	now        func() time.Time
+
+	mu   sync.Mutex
+	head *Record
+	read bool
+}
+
+// New creates an auditor for the configured sink. It returns nil when
+// auditing is off.
+func New(cfg *config.Config, kubeClient kubernetes.Interface) (*Auditor, error) {
+	store, err := NewStore(cfg, kubeClient)
+	if err != nil || store == nil {
+		return nil, err
+	}
+	configHash, err := ConfigHash(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auditor{
+		store:      store,
+		actor:      actor(cfg),
+		configHash: configHash,
+		now:        time.Now,
+	}, nil
+}
+
+// Record appends an entry to the trail. It is a no-op on a nil auditor.
+func (a *Auditor) Record(ctx context.Context, entry Entry) error {
+	if a == nil {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// The head is read once, then followed in memory
+	if !a.read {
+		head, err := a.store.Head(ctx)
+		if err != nil {
+			return fmt.Errorf("reading audit trail head: %w", err)
+		}
+		a.head, a.read = head, true
+	}
+
+	rec := Record{
+		Seq:        1,
+		Time:       a.now().UTC(),
+		Actor:      a.actor,
+		ConfigHash: a.configHash,
+		Entry:      entry,
+	}
+	if a.head != nil {
+		rec.Seq = a.head.Seq + 1
+		rec.PrevHash = a.head.Hash
+	}
+	rec.Hash = rec.computeHash()
+
+	if err := a.store.Append(ctx, rec); err != nil {
+		return err
+	}
+	a.head = &rec
+	return nil
+}
+
+// computeHash hashes the record's JSON form with an empty Hash field
+func (r Record) computeHash() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks a trail for gaps, broken links and altered records. It
+// returns one message per problem found.
+func Verify(records []Record) []string {
+	var problems []string
+	var prev *Record
+	for i := range records {
+		rec := records[i]
+
+		wantSeq := int64(1)
+		wantPrev := ""
+		if prev != nil {
+			wantSeq = prev.Seq + 1
+			wantPrev = prev.Hash
+		}
+
+		if rec.Seq != wantSeq {
+			problems = append(problems, fmt.Sprintf("record %d: expected seq %d, records are missing or reordered", rec.Seq, wantSeq))
+		}
+		if rec.PrevHash != wantPrev {
+			problems = append(problems, fmt.Sprintf("record %d: previous hash does not match record %d", rec.Seq, wantSeq-1))
+		}
+		if rec.Hash != rec.computeHash() {
+			problems = append(problems, fmt.Sprintf("record %d: hash mismatch, the record was altered", rec.Seq))
+		}
+		prev = &records[i]
+	}
+	return problems
+}
+
+// ConfigHash fingerprints the settings in effect, leaving out secrets. The
+// SMTP username is left out with the password: it is a credential, and
+// rotating the mail account does not change what the cleaner does.
+func ConfigHash(cfg *config.Config) (string, error) {
+	redacted := *cfg
+	redacted.ClientSecret = ""
+	redacted.SMTPUsername = ""
+	redacted.SMTPPassword = ""
+	redacted.WebhookURL = ""
+
+	data, err := json.Marshal(redacted)
+	if err != nil {
+		return "", fmt.Errorf("hashing config: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// actor names who performed the actions: AUDIT_ACTOR when set, else the
+// service account from the pod's token, else the component name
+func actor(cfg *config.Config) string {
+	if cfg.AuditActor != "" {
+		return cfg.AuditActor
+	}
+	if token, err := os.ReadFile(tokenPath); err == nil {
+		if sub := tokenSubject(string(token)); sub != "" {
+			return sub
+		}
+	}
+	return "namespace-cleaner"
+}
+
+// tokenSubject reads the sub claim of a JWT without verifying it, which
+// is enough to name the pod's own service account
+func tokenSubject(token string) string {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.Sub
+}
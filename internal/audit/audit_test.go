@@ -0,0 +1,160 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func newTestAuditor(t *testing.T, store Store) *Auditor {
+	t.Helper()
+	now := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	return &Auditor{
+		store:      store,
+		actor:      "system:serviceaccount:das:namespace-cleaner",
+		configHash: "abc",
+		now: func() time.Time {
+			now = now.Add(time.Minute)
+			return now
+		},
+	}
+}
+
+func sampleTrail(t *testing.T, n int) []Record {
+	t.Helper()
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	auditor := newTestAuditor(t, store)
+	for i := 0; i < n; i++ {
+		entry := Entry{Action: ActionLabel, Namespace: "ns", Owner: "user@example.com", Reason: "owner_not_found"}
+		if err := auditor.Record(context.TODO(), entry); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	records, err := store.Records(context.TODO())
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	return records
+}
+
+func TestRecordChainsEntries(t *testing.T) {
+	records := sampleTrail(t, 3)
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+
+	for i, rec := range records {
+		if rec.Seq != int64(i+1) {
+			t.Errorf("Record %d has seq %d", i, rec.Seq)
+		}
+		if i > 0 && rec.PrevHash != records[i-1].Hash {
+			t.Errorf("Record %d is not linked to the previous one", rec.Seq)
+		}
+	}
+	if records[0].PrevHash != "" {
+		t.Errorf("First record should have no previous hash, got %q", records[0].PrevHash)
+	}
+	if problems := Verify(records); len(problems) != 0 {
+		t.Errorf("Expected an intact trail, got %v", problems)
+	}
+}
+
+func TestRecordContinuesExistingTrail(t *testing.T) {
+	store := &FileStore{Path: filepath.Join(t.TempDir(), "audit.jsonl")}
+	for run := 0; run < 2; run++ {
+		if err := newTestAuditor(t, store).Record(context.TODO(), Entry{Action: ActionDelete}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	records, _ := store.Records(context.TODO())
+	if len(records) != 2 || records[1].Seq != 2 || records[1].PrevHash != records[0].Hash {
+		t.Errorf("Second run should continue the chain, got %+v", records)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	testCases := []struct {
+		name   string
+		change func([]Record) []Record
+		want   string
+	}{
+		{"altered record", func(r []Record) []Record {
+			r[1].Owner = "someone@example.com"
+			return r
+		}, "hash mismatch"},
+		{"removed record", func(r []Record) []Record {
+			return append(r[:1], r[2:]...)
+		}, "missing or reordered"},
+		{"rewritten record", func(r []Record) []Record {
+			r[1].Namespace = "other"
+			r[1].Hash = r[1].computeHash()
+			return r
+		}, "previous hash does not match"},
+		{"truncated start", func(r []Record) []Record {
+			return r[1:]
+		}, "expected seq 1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := Verify(tc.change(sampleTrail(t, 3)))
+			if !strings.Contains(strings.Join(problems, "\n"), tc.want) {
+				t.Errorf("Expected a problem containing %q, got %v", tc.want, problems)
+			}
+		})
+	}
+}
+
+func TestRecordNilAuditor(t *testing.T) {
+	var auditor *Auditor
+	if err := auditor.Record(context.TODO(), Entry{}); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestConfigHashIgnoresSecrets(t *testing.T) {
+	hash := func(cfg *config.Config) string {
+		t.Helper()
+		sum, err := ConfigHash(cfg)
+		if err != nil {
+			t.Fatalf("ConfigHash failed: %v", err)
+		}
+		return sum
+	}
+	a := hash(&config.Config{GracePeriod: 30, ClientSecret: "one", SMTPUsername: "one", SMTPPassword: "one"})
+	b := hash(&config.Config{GracePeriod: 30, ClientSecret: "two", SMTPUsername: "two", SMTPPassword: "two"})
+	c := hash(&config.Config{GracePeriod: 7})
+
+	if a != b {
+		t.Error("Secrets should not change the config hash")
+	}
+	if a == c {
+		t.Error("Settings should change the config hash")
+	}
+}
+
+func TestTokenSubject(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"system:serviceaccount:das:namespace-cleaner"}`))
+
+	testCases := []struct {
+		token string
+		want  string
+	}{
+		{"header." + payload + ".signature\n", "system:serviceaccount:das:namespace-cleaner"},
+		{"not-a-jwt", ""},
+		{"header.!!!.signature", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := tokenSubject(tc.token); got != tc.want {
+			t.Errorf("tokenSubject(%q) = %q, want %q", tc.token, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+const (
+	// trailKey holds the JSON lines in each ConfigMap or Secret
+	trailKey = "audit.jsonl"
+	// trailLabel marks the objects of a trail and names it
+	trailLabel = "namespace-cleaner/audit-trail"
+	// headSeqKey and headHashKey hold the chain head for syslog trails
+	headSeqKey  = "seq"
+	headHashKey = "hash"
+)
+
+// NewStore opens the configured audit sink. It returns nil when
+// auditing is off.
+func NewStore(cfg *config.Config, kubeClient kubernetes.Interface) (Store, error) {
+	switch cfg.AuditSink {
+	case config.AuditSinkNone:
+		return nil, nil
+	case config.AuditSinkFile:
+		return &FileStore{Path: cfg.AuditFile}, nil
+	case config.AuditSinkConfigMap, config.AuditSinkSecret:
+		ns, name, err := splitObject(cfg.AuditObject)
+		if err != nil {
+			return nil, err
+		}
+		return &objectStore{
+			kubeClient: kubeClient,
+			secret:     cfg.AuditSink == config.AuditSinkSecret,
+			namespace:  ns,
+			name:       name,
+			maxBytes:   cfg.AuditMaxBytes,
+		}, nil
+	case config.AuditSinkSyslog:
+		ns, name, err := splitObject(cfg.AuditObject)
+		if err != nil {
+			return nil, err
+		}
+		writer, err := dialSyslog(cfg.AuditSyslogAddr)
+		if err != nil {
+			return nil, err
+		}
+		return &syslogStore{writer: writer, kubeClient: kubeClient, namespace: ns, name: name}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", cfg.AuditSink)
+	}
+}
+
+// FileStore keeps the trail as JSON lines in a local file
+type FileStore struct {
+	Path string
+}
+
+// Head returns the last record in the file
+func (s *FileStore) Head(ctx context.Context) (*Record, error) {
+	records, err := s.Records(ctx)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[len(records)-1], nil
+}
+
+// Append adds a record to the end of the file
+func (s *FileStore) Append(ctx context.Context, rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Records reads every record in the file. A missing file is an empty trail.
+func (s *FileStore) Records(ctx context.Context) ([]Record, error) {
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRecords(f)
+}
+
+// ParseRecords reads JSON-lines records. Anything before the first "{" on
+// a line is ignored, so syslog exports can be checked as they are.
+func ParseRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		start := strings.Index(text, "{")
+		if start == -1 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal([]byte(text[start:]), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// objectStore keeps the trail in numbered ConfigMaps or Secrets named
+// <name>-0001, <name>-0002, ... A new object is started once the current
+// one would grow past maxBytes, and the full one is made immutable.
+type objectStore struct {
+	kubeClient kubernetes.Interface
+	secret     bool
+	namespace  string
+	name       string
+	maxBytes   int
+}
+
+// trailObject is the part of a ConfigMap or Secret the store works with
+type trailObject struct {
+	index int
+	data  []byte
+}
+
+// Head returns the last record of the newest object
+func (s *objectStore) Head(ctx context.Context) (*Record, error) {
+	objects, err := s.list(ctx)
+	if err != nil || len(objects) == 0 {
+		return nil, err
+	}
+
+	records, err := ParseRecords(bytes.NewReader(objects[len(objects)-1].data))
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return &records[len(records)-1], nil
+}
+
+// Append adds a record to the newest object, rotating when it is full
+func (s *objectStore) Append(ctx context.Context, rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	objects, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return s.create(ctx, 1, line)
+	}
+
+	current := objects[len(objects)-1]
+	if s.maxBytes > 0 && len(current.data)+len(line) > s.maxBytes {
+		if err := s.seal(ctx, current); err != nil {
+			return err
+		}
+		return s.create(ctx, current.index+1, line)
+	}
+	return s.update(ctx, current.index, append(current.data, line...), false)
+}
+
+// Records concatenates the records of every object in order
+func (s *objectStore) Records(ctx context.Context) ([]Record, error) {
+	objects, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for _, obj := range objects {
+		recs, err := ParseRecords(bytes.NewReader(obj.data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.objectName(obj.index), err)
+		}
+		records = append(records, recs...)
+	}
+	return records, nil
+}
+
+// list returns the trail's objects ordered by index
+func (s *objectStore) list(ctx context.Context) ([]trailObject, error) {
+	opts := metav1.ListOptions{LabelSelector: trailLabel + "=" + s.name}
+
+	var objects []trailObject
+	if s.secret {
+		list, err := s.kubeClient.CoreV1().Secrets(s.namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			if index, ok := s.index(item.Name); ok {
+				objects = append(objects, trailObject{index: index, data: item.Data[trailKey]})
+			}
+		}
+	} else {
+		list, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			if index, ok := s.index(item.Name); ok {
+				objects = append(objects, trailObject{index: index, data: []byte(item.Data[trailKey])})
+			}
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].index < objects[j].index })
+	return objects, nil
+}
+
+// create starts a new object holding data
+func (s *objectStore) create(ctx context.Context, index int, data []byte) error {
+	meta := s.objectMeta(index)
+	var err error
+	if s.secret {
+		_, err = s.kubeClient.CoreV1().Secrets(s.namespace).Create(ctx, &corev1.Secret{
+			ObjectMeta: meta,
+			Data:       map[string][]byte{trailKey: data},
+		}, metav1.CreateOptions{})
+	} else {
+		_, err = s.kubeClient.CoreV1().ConfigMaps(s.namespace).Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: meta,
+			Data:       map[string]string{trailKey: string(data)},
+		}, metav1.CreateOptions{})
+	}
+	return err
+}
+
+// seal makes a full object immutable
+func (s *objectStore) seal(ctx context.Context, obj trailObject) error {
+	return s.update(ctx, obj.index, obj.data, true)
+}
+
+// update replaces an object's data
+func (s *objectStore) update(ctx context.Context, index int, data []byte, immutable bool) error {
+	meta := s.objectMeta(index)
+	var err error
+	if s.secret {
+		_, err = s.kubeClient.CoreV1().Secrets(s.namespace).Update(ctx, &corev1.Secret{
+			ObjectMeta: meta,
+			Data:       map[string][]byte{trailKey: data},
+			Immutable:  &immutable,
+		}, metav1.UpdateOptions{})
+	} else {
+		_, err = s.kubeClient.CoreV1().ConfigMaps(s.namespace).Update(ctx, &corev1.ConfigMap{
+			ObjectMeta: meta,
+			Data:       map[string]string{trailKey: string(data)},
+			Immutable:  &immutable,
+		}, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+func (s *objectStore) objectMeta(index int) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      s.objectName(index),
+		Namespace: s.namespace,
+		Labels:    map[string]string{trailLabel: s.name},
+	}
+}
+
+func (s *objectStore) objectName(index int) string {
+	return fmt.Sprintf("%s-%04d", s.name, index)
+}
+
+// index parses the number at the end of an object name
+func (s *objectStore) index(objectName string) (int, bool) {
+	suffix, found := strings.CutPrefix(objectName, s.name+"-")
+	if !found {
+		return 0, false
+	}
+	index, err := strconv.Atoi(suffix)
+	return index, err == nil
+}
+
+// syslogStore sends each record to a syslog endpoint. The trail itself
+// cannot be read back, so the chain head is kept in a small ConfigMap and
+// exports must be checked with verify-audit -file.
+type syslogStore struct {
+	writer     io.Writer
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+}
+
+// Head returns the seq and hash of the last record sent
+func (s *syslogStore) Head(ctx context.Context) (*Record, error) {
+	cm, err := s.kubeClient.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seq, err := strconv.ParseInt(cm.Data[headSeqKey], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit head in %s/%s: %w", s.namespace, s.name, err)
+	}
+	return &Record{Seq: seq, Hash: cm.Data[headHashKey]}, nil
+}
+
+// Append sends a record, then moves the chain head forward
+func (s *syslogStore) Append(ctx context.Context, rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.writer.Write(line); err != nil {
+		return err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+		Data: map[string]string{
+			headSeqKey:  strconv.FormatInt(rec.Seq, 10),
+			headHashKey: rec.Hash,
+		},
+	}
+	configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+	}
+	return err
+}
+
+// Records is not supported for syslog trails
+func (s *syslogStore) Records(ctx context.Context) ([]Record, error) {
+	return nil, errors.New("syslog audit trails must be exported and checked with verify-audit -file")
+}
+
+// dialSyslog connects to an address such as udp://syslog:514
+func dialSyslog(addr string) (io.Writer, error) {
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid syslog address %q, expected udp://host:port or tcp://host:port", addr)
+	}
+	return syslog.Dial(u.Scheme, u.Host, syslog.LOG_INFO|syslog.LOG_AUTH, "namespace-cleaner")
+}
+
+// splitObject parses "<namespace>/<name>"
+func splitObject(value string) (string, string, error) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("audit object %q is not <namespace>/<name>", value)
+	}
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestNewStore(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfg     config.Config
+		wantNil bool
+		wantErr bool
+	}{
+		{"none", config.Config{AuditSink: config.AuditSinkNone}, true, false},
+		{"file", config.Config{AuditSink: config.AuditSinkFile, AuditFile: "audit.jsonl"}, false, false},
+		{"configmap", config.Config{AuditSink: config.AuditSinkConfigMap, AuditObject: "das/audit"}, false, false},
+		{"bad object", config.Config{AuditSink: config.AuditSinkSecret, AuditObject: "audit"}, true, true},
+		{"bad syslog address", config.Config{AuditSink: config.AuditSinkSyslog, AuditObject: "das/audit", AuditSyslogAddr: "syslog"}, true, true},
+		{"unknown", config.Config{AuditSink: "kafka"}, true, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := NewStore(&tc.cfg, fake.NewSimpleClientset())
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if (store == nil) != tc.wantNil {
+				t.Errorf("Unexpected store %v", store)
+			}
+		})
+	}
+}
+
+func TestObjectStoreRotates(t *testing.T) {
+	for _, secret := range []bool{false, true} {
+		client := fake.NewSimpleClientset()
+		store := &objectStore{kubeClient: client, secret: secret, namespace: "das", name: "audit", maxBytes: 700}
+		auditor := newTestAuditor(t, store)
+
+		for i := 0; i < 5; i++ {
+			if err := auditor.Record(context.TODO(), Entry{Action: ActionLabel, Namespace: "ns"}); err != nil {
+				t.Fatalf("Record failed: %v", err)
+			}
+		}
+
+		records, err := store.Records(context.TODO())
+		if err != nil {
+			t.Fatalf("Records failed: %v", err)
+		}
+		if len(records) != 5 {
+			t.Fatalf("Expected 5 records, got %d", len(records))
+		}
+		if problems := Verify(records); len(problems) != 0 {
+			t.Errorf("Expected an intact trail, got %v", problems)
+		}
+
+		// Each record is over 350 bytes, so every object holds one
+		if secret {
+			first, err := client.CoreV1().Secrets("das").Get(context.TODO(), "audit-0001", metav1.GetOptions{})
+			if err != nil || first.Immutable == nil || !*first.Immutable {
+				t.Errorf("Expected the first Secret to be sealed, got %+v (%v)", first, err)
+			}
+			if _, err := client.CoreV1().Secrets("das").Get(context.TODO(), "audit-0005", metav1.GetOptions{}); err != nil {
+				t.Errorf("Expected a fifth Secret: %v", err)
+			}
+		} else {
+			first, err := client.CoreV1().ConfigMaps("das").Get(context.TODO(), "audit-0001", metav1.GetOptions{})
+			if err != nil || first.Immutable == nil || !*first.Immutable {
+				t.Errorf("Expected the first ConfigMap to be sealed, got %+v (%v)", first, err)
+			}
+			if _, err := client.CoreV1().ConfigMaps("das").Get(context.TODO(), "audit-0005", metav1.GetOptions{}); err != nil {
+				t.Errorf("Expected a fifth ConfigMap: %v", err)
+			}
+		}
+	}
+}
+
+func TestSyslogStore(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer conn.Close()
+
+	client := fake.NewSimpleClientset()
+	store, err := NewStore(&config.Config{
+		AuditSink:       config.AuditSinkSyslog,
+		AuditObject:     "das/audit-head",
+		AuditSyslogAddr: "udp://" + conn.LocalAddr().String(),
+	}, client)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := newTestAuditor(t, store).Record(context.TODO(), Entry{Action: ActionDelete, Namespace: "ns"}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	// Messages arrive with a syslog header the parser skips
+	var lines []string
+	buf := make([]byte, 4096)
+	for i := 0; i < 2; i++ {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom failed: %v", err)
+		}
+		lines = append(lines, string(buf[:n]))
+	}
+
+	records, err := ParseRecords(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("ParseRecords failed: %v", err)
+	}
+	if problems := Verify(records); len(records) != 2 || len(problems) != 0 {
+		t.Errorf("Expected an intact trail of 2 records, got %d (%v)", len(records), problems)
+	}
+
+	head, err := store.Head(context.TODO())
+	if err != nil || head.Seq != 2 || head.Hash != records[1].Hash {
+		t.Errorf("Unexpected head %+v (%v)", head, err)
+	}
+	if _, err := store.Records(context.TODO()); err == nil {
+		t.Error("Expected syslog trails to be unreadable")
+	}
+}
@@ -0,0 +1,284 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+const (
+	// idleSinceKey marks namespaces labeled for inactivity rather than a
+	// missing owner, and holds the last activity seen when they were
+	idleSinceKey = "namespace-cleaner/idle-since"
+
+	// notebookActivityKey is where the Kubeflow notebook controller keeps
+	// the last time a notebook kernel was busy
+	notebookActivityKey = "notebooks.kubeflow.org/last-activity"
+
+	checkActivity = "activity"
+)
+
+// Activity is the newest sign of use found in a namespace. With nothing
+// found, it is the namespace's creation.
+type Activity struct {
+	IdleSince time.Time
+	Source    string
+}
+
+// idleDays is how many whole days a namespace has been idle at a time
+func (a Activity) idleDays(at time.Time) int {
+	return int(math.Floor(at.Sub(a.IdleSince).Hours() / 24))
+}
+
+// LastActivity finds the newest sign of use in a namespace among the
+// configured activity sources. Use that is still going on, such as a
+// mounted volume, counts as activity at the run's reference time now.
+func (c *Cleaner) LastActivity(ctx context.Context, ns *corev1.Namespace, now time.Time) (Activity, error) {
+	activity := Activity{IdleSince: ns.CreationTimestamp.Time, Source: "namespace created"}
+	seen := func(at time.Time, source string) {
+		if at.After(activity.IdleSince) {
+			activity = Activity{IdleSince: at.UTC(), Source: source}
+		}
+	}
+
+	// The pods and PVC sources share one pod list
+	var pods *corev1.PodList
+	listPods := func() (*corev1.PodList, error) {
+		if pods != nil {
+			return pods, nil
+		}
+		var err error
+		pods, err = c.kubeClient.CoreV1().Pods(ns.Name).List(ctx, metav1.ListOptions{})
+		return pods, err
+	}
+
+	for _, source := range c.activitySources {
+		var err error
+		switch source {
+		case config.ActivitySourcePods:
+			err = podActivity(listPods, seen)
+		case config.ActivitySourceNotebooks:
+			err = c.notebookActivity(ctx, ns.Name, seen)
+		case config.ActivitySourceEvents:
+			err = c.eventActivity(ctx, ns.Name, seen)
+		case config.ActivitySourcePVCs:
+			err = volumeActivity(listPods, now, seen)
+		}
+		if err != nil {
+			return Activity{}, fmt.Errorf("reading %s: %w", source, err)
+		}
+	}
+	return activity, nil
+}
+
+// podActivity reports the newest pod start time
+func podActivity(listPods func() (*corev1.PodList, error), seen func(time.Time, string)) error {
+	pods, err := listPods()
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.StartTime != nil {
+			seen(pod.Status.StartTime.Time, "pod "+pod.Name+" started")
+		}
+	}
+	return nil
+}
+
+// notebookActivity reports the last kernel activity of Kubeflow Notebooks
+func (c *Cleaner) notebookActivity(ctx context.Context, nsName string, seen func(time.Time, string)) error {
+	if c.dynamicClient == nil {
+		return nil
+	}
+	notebooks, err := c.dynamicClient.Resource(notebookGVR).Namespace(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, nb := range notebooks.Items {
+		if at, err := time.Parse(time.RFC3339, nb.GetAnnotations()[notebookActivityKey]); err == nil {
+			seen(at, "notebook "+nb.GetName()+" active")
+		}
+	}
+	return nil
+}
+
+// eventActivity reports the newest Event in the namespace. The cleaner's
+// own events are ignored so its decisions do not count as use.
+func (c *Cleaner) eventActivity(ctx context.Context, nsName string, seen func(time.Time, string)) error {
+	events, err := c.kubeClient.CoreV1().Events(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, event := range events.Items {
+		if event.Source.Component == "namespace-cleaner" || event.ReportingController == "namespace-cleaner" {
+			continue
+		}
+		at := event.LastTimestamp.Time
+		if at.IsZero() {
+			at = event.EventTime.Time
+		}
+		seen(at, fmt.Sprintf("event %s on %s/%s", event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Name))
+	}
+	return nil
+}
+
+// volumeActivity treats a PersistentVolumeClaim mounted by a running pod
+// as in use at now
+func volumeActivity(listPods func() (*corev1.PodList, error), now time.Time, seen func(time.Time, string)) error {
+	pods, err := listPods()
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if claim := volume.PersistentVolumeClaim; claim != nil {
+				seen(now, "pvc "+claim.ClaimName+" mounted by "+pod.Name)
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// MarkIdle labels a namespace for deletion because it has not been used,
+// keeping the last activity seen
+func (c *Cleaner) MarkIdle(ctx context.Context, nsName, graceDate, idleSince string) error {
+	if c.dryRun {
+		slog.Info("Would label idle namespace", logging.KeyNamespace, nsName, logging.KeyAction, "label",
+			"delete_at", graceDate, "idle_since", idleSince)
+		return nil
+	}
+
+	patch := mustMarshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      map[string]string{labelKey: graceDate},
+			"annotations": map[string]string{idleSinceKey: idleSince},
+		},
+	})
+	_, err := c.kubeClient.CoreV1().Namespaces().Patch(
+		ctx, nsName, types.MergePatchType, patch, metav1.PatchOptions{},
+	)
+	return err
+}
+
+// evaluateActivity looks up a namespace's last activity and records it
+// as a check. When the lookup fails the namespace is skipped for this
+// run, reported by the second result.
+func evaluateActivity(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	today time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) (Activity, bool) {
+	activity, err := cleaner.LastActivity(ctx, ns, today)
+	if err != nil {
+		decision.AddCheck(checkActivity, "failed: %v", err)
+		decision.Fail("activity_failed", logError(logger, stats, "Error reading activity of %s: %v", ns.Name, err))
+		return Activity{}, false
+	}
+
+	decision.AddCheck(checkActivity, "idle since %s (%s), %d day(s)",
+		activity.IdleSince.Format(time.RFC3339), activity.Source, activity.idleDays(today))
+	logger.Debug("Read namespace activity", "idle_since", activity.IdleSince, "source", activity.Source)
+	return activity, true
+}
+
+// labelIdle labels a namespace whose owner exists but which has not been
+// used for more than cfg.IdleDays
+func labelIdle(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email, graceDate string,
+	today time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) {
+	activity, ok := evaluateActivity(ctx, cleaner, ns, today, stats, decision, logger)
+	if !ok {
+		return
+	}
+	if activity.idleDays(today) <= cfg.IdleDays {
+		stats.IncSkippedExistingUser()
+		decision.Skip("owner_exists")
+		return
+	}
+
+	idleSince := activity.IdleSince.Format(labelTimeLayout)
+	if err := cleaner.MarkIdle(ctx, ns.Name, graceDate, idleSince); err != nil {
+		decision.Fail("idle", logError(logger, stats, "Error labeling idle ns %s: %v", ns.Name, err))
+		return
+	}
+	stats.AddLabeled(ns.Name)
+	stats.IncLabeledIdle()
+	auditAction(ctx, cleaner, cfg, audit.ActionLabel, "idle", ns.Name, email, graceDate,
+		lookupEvidence(cfg, email, true), stats, logger)
+	deleteAt, _ := time.ParseInLocation(labelTimeLayout, graceDate, time.UTC)
+	stats.AddPending(ns.Name, deleteAt)
+	decision.Labeled("idle", deleteAt)
+	cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonLabeled,
+		fmt.Sprintf("The namespace has not been used since %s (%s); it will be deleted after %s unless it is used again",
+			idleSince, activity.Source, graceDate))
+
+	notifyOwner(ctx, cleaner, graph, ns, cfg, notifier.Notice{
+		Event:     notifier.EventIdle,
+		Namespace: ns.Name,
+		Owner:     email,
+		IdleSince: activity.IdleSince,
+		DeleteAt:  deleteAt,
+	})
+}
+
+// recentlyUsed defers the deletion of a departed owner's namespace that
+// was used within the last cfg.ActiveDays, such as during a handover. A
+// failed lookup defers it too.
+func recentlyUsed(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	today time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) bool {
+	activity, ok := evaluateActivity(ctx, cleaner, ns, today, stats, decision, logger)
+	if !ok {
+		return true
+	}
+	if activity.idleDays(today) >= cfg.ActiveDays {
+		return false
+	}
+
+	stats.IncSkippedRecentlyUsed()
+	decision.Skip("recently_used")
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonDeferred,
+		fmt.Sprintf("Deletion deferred: the namespace was used on %s (%s)", activity.IdleSince.Format(time.RFC3339), activity.Source))
+	return true
+}
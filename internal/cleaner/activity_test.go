@@ -0,0 +1,261 @@
+package cleaner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func TestLastActivity(t *testing.T) {
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(month time.Month) metav1.Time {
+		return metav1.NewTime(time.Date(2022, month, 1, 0, 0, 0, 0, time.UTC))
+	}
+	podStart, eventTime := at(3), at(5)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", CreationTimestamp: metav1.NewTime(created)}}
+	client := fake.NewSimpleClientset(
+		ns,
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "job", Namespace: "test-ns"},
+			Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+				Name:         "data",
+				VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}},
+			}}},
+			Status: corev1.PodStatus{Phase: corev1.PodSucceeded, StartTime: &podStart},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "job.1", Namespace: "test-ns"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "job"},
+			Reason:         "Pulled",
+			LastTimestamp:  eventTime,
+		},
+		&corev1.Event{
+			ObjectMeta:          metav1.ObjectMeta{Name: "test-ns.1", Namespace: "test-ns"},
+			ReportingController: "namespace-cleaner",
+			LastTimestamp:       at(12),
+		},
+	)
+	notebook := newNotebook("test-ns", "jupyter")
+	notebook.SetAnnotations(map[string]string{notebookActivityKey: "2022-07-01T00:00:00Z"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{notebookGVR: "NotebookList"},
+		notebook,
+	)
+
+	testCases := []struct {
+		sources    []string
+		want       time.Time
+		wantSource string
+	}{
+		{nil, created, "namespace created"},
+		{[]string{config.ActivitySourcePods}, podStart.Time, "pod job started"},
+		{[]string{config.ActivitySourcePods, config.ActivitySourceEvents}, eventTime.Time, "event Pulled on Pod/job"},
+		{[]string{config.ActivitySourceEvents, config.ActivitySourceNotebooks}, time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), "notebook jupyter active"},
+		// The claim is only mounted by a finished pod
+		{[]string{config.ActivitySourcePVCs}, created, "namespace created"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.sources, ","), func(t *testing.T) {
+			cleaner := NewCleaner(&config.Config{ActivitySources: tc.sources}, client, dynamicClient, nil, nil, nil, nil)
+			activity, err := cleaner.LastActivity(context.TODO(), ns, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("LastActivity failed: %v", err)
+			}
+			if !activity.IdleSince.Equal(tc.want) || activity.Source != tc.wantSource {
+				t.Errorf("Expected %s (%s), got %s (%s)", tc.want, tc.wantSource, activity.IdleSince, activity.Source)
+			}
+		})
+	}
+}
+
+func TestLastActivityMountedVolume(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "test-ns"},
+		Spec: corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "data",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}},
+		}}},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+	cleaner := NewCleaner(&config.Config{ActivitySources: []string{config.ActivitySourcePVCs}}, client, nil, nil, nil, nil, nil)
+
+	now := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	activity, err := cleaner.LastActivity(context.TODO(), ns, now)
+	if err != nil {
+		t.Fatalf("LastActivity failed: %v", err)
+	}
+	if !activity.IdleSince.Equal(now) || activity.Source != "pvc data mounted by web" {
+		t.Errorf("Expected the mounted claim to count as current use, got %+v", activity)
+	}
+
+	client.ClearActions()
+	cleaner = NewCleaner(&config.Config{ActivitySources: []string{config.ActivitySourcePods, config.ActivitySourcePVCs}}, client, nil, nil, nil, nil, nil)
+	if _, err := cleaner.LastActivity(context.TODO(), ns, now); err != nil {
+		t.Fatalf("LastActivity failed: %v", err)
+	}
+	if actions := client.Actions(); len(actions) != 1 {
+		t.Errorf("Expected the pods and PVC sources to share one pod list, got %d calls", len(actions))
+	}
+}
+
+func TestMarkIdle(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}})
+	cleaner := NewCleaner(&config.Config{}, client, nil, nil, nil, nil, nil)
+
+	if err := cleaner.MarkIdle(context.TODO(), "test-ns", "2023-02-01_00-00-00Z", "2022-06-01_00-00-00Z"); err != nil {
+		t.Fatalf("MarkIdle failed: %v", err)
+	}
+	ns, _ := client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if ns.Labels[labelKey] != "2023-02-01_00-00-00Z" || ns.Annotations[idleSinceKey] != "2022-06-01_00-00-00Z" {
+		t.Errorf("Unexpected metadata %v %v", ns.Labels, ns.Annotations)
+	}
+
+	if err := cleaner.RemoveLabel(context.TODO(), "test-ns"); err != nil {
+		t.Fatalf("RemoveLabel failed: %v", err)
+	}
+	ns, _ = client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if _, found := ns.Annotations[idleSinceKey]; found {
+		t.Error("Expected RemoveLabel to drop the idle-since annotation")
+	}
+}
+
+func TestActivityPolicy(t *testing.T) {
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) Activity {
+		return Activity{IdleSince: today.Add(-time.Duration(days) * 24 * time.Hour), Source: "pod web started"}
+	}
+
+	testCases := []struct {
+		name         string
+		ownerExists  bool
+		labeled      bool
+		idleLabeled  bool
+		activity     Activity
+		wantDecision string
+		wantReason   string
+		wantEvent    string
+	}{
+		{"idle owner namespace labeled", true, false, false, daysAgo(200), stats.DecisionLabeled, "idle", ReasonLabeled},
+		{"used owner namespace skipped", true, false, false, daysAgo(30), stats.DecisionSkipped, "owner_exists", ""},
+		{"still idle is deleted", true, true, true, daysAgo(200), stats.DecisionDeleted, "grace_period_expired", ReasonDeleted},
+		{"used again is unlabeled", true, true, true, daysAgo(1), stats.DecisionLabelRemoved, "used_again", ReasonLabelRemoved},
+		{"departed owner recently used", false, true, false, daysAgo(3), stats.DecisionSkipped, "recently_used", ReasonDeferred},
+		{"departed owner idle", false, true, false, daysAgo(30), stats.DecisionDeleted, "grace_period_expired", ReasonDeleted},
+		{"owner found drops idle label", true, true, false, daysAgo(200), stats.DecisionLabelRemoved, "owner_found", ReasonLabelRemoved},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			restore := MockUserExists(tc.ownerExists)
+			defer restore()
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Labels:      map[string]string{},
+					Annotations: map[string]string{"owner": "user@example.com"},
+				},
+			}
+			if tc.idleLabeled {
+				ns.Annotations[idleSinceKey] = "2022-06-01_00-00-00Z"
+			}
+
+			cleaner := &mockCleaner{activity: map[string]Activity{"test-ns": tc.activity}}
+			s := &stats.Stats{}
+			cfg := &config.Config{AllowedDomains: []string{"example.com"}, IdleDays: 90, ActiveDays: 14}
+			if tc.labeled {
+				ns.Labels[labelKey] = "2023-01-05_00-00-00Z"
+				processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+			} else {
+				processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-02-10_00-00-00Z", today, s)
+			}
+
+			decision := s.Decisions[0]
+			if decision.Decision != tc.wantDecision || decision.Reason != tc.wantReason {
+				t.Errorf("Expected %s (%s), got %s (%s)", tc.wantDecision, tc.wantReason, decision.Decision, decision.Reason)
+			}
+			if tc.wantEvent != "" && !contains(cleaner.events, tc.wantEvent) {
+				t.Errorf("Expected a %s event, got %v", tc.wantEvent, cleaner.events)
+			}
+			if tc.wantReason == "idle" {
+				if s.LabeledIdle != 1 || cleaner.notices[0].Event != notifier.EventIdle {
+					t.Errorf("Expected an idle label and notice, got %d %v", s.LabeledIdle, cleaner.notices)
+				}
+			}
+			if tc.wantReason == "recently_used" && s.SkippedRecentlyUsed != 1 {
+				t.Error("Expected a recently used skip")
+			}
+		})
+	}
+}
+
+func TestActivityWhileQuarantined(t *testing.T) {
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	// Scaling the workloads down raised an event just now
+	scaledDown := Activity{IdleSince: today, Source: "event ScalingReplicaSet on Deployment/web"}
+
+	testCases := []struct {
+		name        string
+		ownerExists bool
+		idleLabeled bool
+	}{
+		{"idle namespace stays labeled", true, true},
+		{"departed owner is not deferred", false, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			restore := MockUserExists(tc.ownerExists)
+			defer restore()
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-ns",
+					Labels: map[string]string{
+						labelKey:           "2023-01-05_00-00-00Z",
+						quarantineLabelKey: "2023-01-20_00-00-00Z",
+					},
+					Annotations: map[string]string{"owner": "user@example.com"},
+				},
+			}
+			if tc.idleLabeled {
+				ns.Annotations[idleSinceKey] = "2022-06-01_00-00-00Z"
+			}
+
+			cleaner := &mockCleaner{activity: map[string]Activity{"test-ns": scaledDown}}
+			s := &stats.Stats{}
+			cfg := &config.Config{
+				AllowedDomains:    []string{"example.com"},
+				IdleDays:          90,
+				ActiveDays:        14,
+				QuarantineEnabled: true,
+				QuarantinePeriod:  15,
+			}
+			processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+
+			if len(cleaner.restored) != 0 || len(cleaner.labelsRemoved) != 0 {
+				t.Errorf("The quarantine's own activity should not lift it, got restored %v, unlabeled %v",
+					cleaner.restored, cleaner.labelsRemoved)
+			}
+			if got := s.Decisions[0].Reason; got == "used_again" || got == "recently_used" {
+				t.Errorf("Expected the activity to be ignored, got %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package cleaner
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+)
+
+// Audit appends an action to the audit trail. Nothing is recorded in dry
+// run, since nothing was changed.
+func (c *Cleaner) Audit(ctx context.Context, entry audit.Entry) error {
+	if c.dryRun {
+		if c.auditor != nil {
+			slog.Info("Would record audit entry", logging.KeyNamespace, entry.Namespace, logging.KeyAction, entry.Action, logging.KeyReason, entry.Reason)
+		}
+		return nil
+	}
+	return c.auditor.Record(ctx, entry)
+}
@@ -0,0 +1,56 @@
+package cleaner
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestAudit(t *testing.T) {
+	testCases := []struct {
+		name   string
+		dryRun bool
+		want   int
+	}{
+		{"records entry", false, 1},
+		{"dry run records nothing", true, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				DryRun:     tc.dryRun,
+				AuditSink:  config.AuditSinkFile,
+				AuditFile:  filepath.Join(t.TempDir(), "audit.jsonl"),
+				AuditActor: "tester",
+			}
+			auditor, err := audit.New(cfg, nil)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+
+			cleaner := NewCleaner(cfg, fake.NewSimpleClientset(), nil, nil, nil, auditor, nil)
+			if err := cleaner.Audit(context.TODO(), audit.Entry{Action: audit.ActionDelete, Namespace: "test-ns"}); err != nil {
+				t.Fatalf("Audit failed: %v", err)
+			}
+
+			records, err := (&audit.FileStore{Path: cfg.AuditFile}).Records(context.TODO())
+			if err != nil {
+				t.Fatalf("Records failed: %v", err)
+			}
+			if len(records) != tc.want {
+				t.Errorf("Expected %d records, got %d", tc.want, len(records))
+			}
+		})
+	}
+
+	// A cleaner without an auditor must not fail
+	if err := NewCleaner(&config.Config{}, fake.NewSimpleClientset(), nil, nil, nil, nil, nil).Audit(context.TODO(), audit.Entry{}); err != nil {
+		t.Errorf("Expected no error without an auditor, got %v", err)
+	}
+}
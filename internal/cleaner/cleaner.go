@@ -2,11 +2,22 @@ package cleaner
 
 import (
 	"context"
-	"log"
+	"log/slog"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
 )
 
 const (
@@ -18,27 +29,74 @@ const (
 type NamespaceCleaner interface {
 	LabelNamespace(ctx context.Context, nsName, graceDate string) error
 	RemoveLabel(ctx context.Context, nsName string) error
-	DeleteNamespace(ctx context.Context, nsName string, testMode bool) error
+	ExtendDeletion(ctx context.Context, nsName, deleteAt, originalDeleteAt, extendedBy string) error
+	DeleteNamespace(ctx context.Context, nsName string, now time.Time) error
+	QuarantineNamespace(ctx context.Context, nsName, purgeDate string) error
+	RestoreNamespace(ctx context.Context, nsName string) error
+	ProfileOwner(ctx context.Context, nsName string) (string, error)
+	Contributors(ctx context.Context, nsName string) ([]Contributor, error)
+	TransferOwnership(ctx context.Context, nsName, previousOwner, newOwner string) error
+	LastActivity(ctx context.Context, ns *corev1.Namespace, now time.Time) (Activity, error)
+	MarkIdle(ctx context.Context, nsName, graceDate, idleSince string) error
+	EarliestRoleBindingUser(ctx context.Context, nsName string) (string, error)
+	MarkOwnerless(ctx context.Context, nsName, graceDate string) error
+	Policy() *policy.Engine
+	NotifyOwner(ctx context.Context, ns *corev1.Namespace, notice notifier.Notice) error
+	RecordEvent(ns *corev1.Namespace, eventType, reason, message string)
+	Audit(ctx context.Context, entry audit.Entry) error
 }
 
 // Cleaner implements NamespaceCleaner with mode switching
 type Cleaner struct {
-	dryRun     bool
-	kubeClient kubernetes.Interface
+	dryRun          bool
+	deletionMode    string
+	propagation     metav1.DeletionPropagation
+	waitForDeletion bool
+	deletionTimeout time.Duration
+	activitySources []string
+	kubeClient      kubernetes.Interface
+	dynamicClient   dynamic.Interface
+	notifier        *notifier.Notifier
+	recorder        record.EventRecorder
+	auditor         *audit.Auditor
+	rules           *policy.Engine
 }
 
 // NewCleaner creates a new cleaner instance
-func NewCleaner(dryRun bool, kubeClient kubernetes.Interface) *Cleaner {
+func NewCleaner(
+	cfg *config.Config,
+	kubeClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	notifier *notifier.Notifier,
+	recorder record.EventRecorder,
+	auditor *audit.Auditor,
+	rules *policy.Engine,
+) *Cleaner {
+	propagation := metav1.DeletePropagationBackground
+	if cfg.DeletionPropagation == config.PropagationForeground {
+		propagation = metav1.DeletePropagationForeground
+	}
+
 	return &Cleaner{
-		dryRun:     dryRun,
-		kubeClient: kubeClient,
+		dryRun:          cfg.DryRun,
+		deletionMode:    cfg.DeletionMode,
+		propagation:     propagation,
+		waitForDeletion: cfg.WaitForDeletion,
+		deletionTimeout: cfg.DeletionTimeout,
+		activitySources: cfg.ActivitySources,
+		kubeClient:      kubeClient,
+		dynamicClient:   dynamicClient,
+		notifier:        notifier,
+		recorder:        recorder,
+		auditor:         auditor,
+		rules:           rules,
 	}
 }
 
 // LabelNamespace adds deletion label to a namespace
 func (c *Cleaner) LabelNamespace(ctx context.Context, nsName, graceDate string) error {
 	if c.dryRun {
-		log.Printf("[DRY RUN] Would label %s with delete-at=%s", nsName, graceDate)
+		slog.Info("Would label namespace", logging.KeyNamespace, nsName, logging.KeyAction, "label", "delete_at", graceDate)
 		return nil
 	}
 
@@ -49,41 +107,110 @@ func (c *Cleaner) LabelNamespace(ctx context.Context, nsName, graceDate string)
 	return err
 }
 
-// RemoveLabel deletes the deletion label from a namespace
+// RemoveLabel deletes the deletion label from a namespace, along with the
+// annotations kept while it was labeled, so a later label starts afresh.
+// The owner's extend-until goes too: an old extension must not move the
+// next label.
 func (c *Cleaner) RemoveLabel(ctx context.Context, nsName string) error {
 	if c.dryRun {
-		log.Printf("[DRY RUN] Would remove delete-at label from %s", nsName)
+		slog.Info("Would remove delete-at label", logging.KeyNamespace, nsName, logging.KeyAction, "unlabel")
 		return nil
 	}
 
-	patch := []byte(`{"metadata":{"labels":{"` + labelKey + `":null}}}`)
+	patch := mustMarshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{labelKey: nil},
+			"annotations": map[string]interface{}{
+				idleSinceKey:        nil,
+				ownerlessKey:        nil,
+				originalDeleteAtKey: nil,
+				extendedByKey:       nil,
+				extendUntilKey:      nil,
+			},
+		},
+	})
 	_, err := c.kubeClient.CoreV1().Namespaces().Patch(
 		ctx, nsName, types.MergePatchType, patch, metav1.PatchOptions{},
 	)
 	if err != nil {
-		log.Printf("Error removing label from %s: %v", nsName, err)
+		slog.Error("Error removing label", logging.KeyNamespace, nsName, logging.KeyAction, "unlabel", logging.KeyError, err)
 	}
 	return err
 }
 
-// DeleteNamespace deletes a namespace
-func (c *Cleaner) DeleteNamespace(ctx context.Context, nsName string, testMode bool) error {
+// DeleteNamespace deletes a namespace, or its owning Profile in profile mode.
+// A namespace already terminating is judged stuck against now, the run's
+// reference time.
+func (c *Cleaner) DeleteNamespace(ctx context.Context, nsName string, now time.Time) error {
+	if c.deletionMode == config.DeletionModeProfile {
+		profile, err := c.findProfile(ctx, nsName)
+		if err != nil {
+			return err
+		}
+		if profile != nil {
+			if err := c.deleteProfile(ctx, nsName, profile.GetName()); err != nil {
+				return err
+			}
+			return c.awaitDeletion(ctx, nsName)
+		}
+		slog.Info("No Profile owns namespace, deleting it directly", logging.KeyNamespace, nsName, logging.KeyAction, "delete")
+	}
+
 	if c.dryRun {
-		log.Printf("[DRY RUN] Would delete namespace %s", nsName)
+		slog.Info("Would delete namespace", logging.KeyNamespace, nsName, logging.KeyAction, "delete")
 		return nil
 	}
 
-	if testMode {
-		ns, err := c.kubeClient.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
-		nsCopy := ns.DeepCopy()
-		nsCopy.Finalizers = nil
-		_, err = c.kubeClient.CoreV1().Namespaces().Update(ctx, nsCopy, metav1.UpdateOptions{})
-		if err != nil {
-			return err
+	ns, err := c.kubeClient.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
 		}
+		return err
+	}
+	if ns.DeletionTimestamp != nil {
+		return c.checkTerminating(ns, now)
+	}
+
+	propagation := c.propagation
+	err = c.kubeClient.CoreV1().Namespaces().Delete(ctx, nsName, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+	if err != nil {
+		return err
 	}
-	return c.kubeClient.CoreV1().Namespaces().Delete(ctx, nsName, metav1.DeleteOptions{})
+	return c.awaitDeletion(ctx, nsName)
+}
+
+// NotifyOwner emails a notice unless its event is disabled or the same
+// notice was already sent for the current delete-at date
+func (c *Cleaner) NotifyOwner(ctx context.Context, ns *corev1.Namespace, notice notifier.Notice) error {
+	if !c.notifier.Enabled(notice.Event) {
+		return nil
+	}
+
+	key := notice.AnnotationKey()
+	sentFor := notice.DeleteAt.Format(labelTimeLayout)
+	if ns.Annotations[key] == sentFor {
+		return nil
+	}
+
+	if c.dryRun {
+		slog.Info("Would send notice", logging.KeyNamespace, ns.Name, logging.KeyOwner, notice.Owner, logging.KeyAction, "notify", "event", notice.Event)
+		return nil
+	}
+
+	if err := c.notifier.Send(notice); err != nil {
+		return err
+	}
+	if notice.Event == notifier.EventDeleted {
+		// Nothing left to annotate
+		return nil
+	}
+
+	patch := []byte(`{"metadata":{"annotations":{"` + key + `":"` + sentFor + `"}}}`)
+	_, err := c.kubeClient.CoreV1().Namespaces().Patch(
+		ctx, ns.Name, types.MergePatchType, patch, metav1.PatchOptions{},
+	)
+	return err
 }
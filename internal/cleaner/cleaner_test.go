@@ -3,16 +3,21 @@ package cleaner
 import (
 	"context"
 	"testing"
+	"time"
 
 	clienttesting "k8s.io/client-go/testing"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/internal/notifier/smtptest"
 )
 
 func TestCleanerDryRun(t *testing.T) {
 	client := fake.NewSimpleClientset()
-	cleaner := NewCleaner(true, client) // Dry-run mode
+	cleaner := NewCleaner(&config.Config{DryRun: true}, client, nil, nil, nil, nil, nil) // Dry-run mode
 
 	// Test label operation
 	if err := cleaner.LabelNamespace(context.TODO(), "test-ns", "2023-01-01"); err != nil {
@@ -20,7 +25,7 @@ func TestCleanerDryRun(t *testing.T) {
 	}
 
 	// Test delete operation
-	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", false); err != nil {
+	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", time.Now()); err != nil {
 		t.Fatalf("DeleteNamespace failed: %v", err)
 	}
 
@@ -37,7 +42,7 @@ func TestCleanerRealOperations(t *testing.T) {
 		},
 	}
 	client := fake.NewSimpleClientset(ns)
-	cleaner := NewCleaner(false, client) // Real mode
+	cleaner := NewCleaner(&config.Config{}, client, nil, nil, nil, nil, nil) // Real mode
 
 	// Test labeling
 	if err := cleaner.LabelNamespace(context.TODO(), "test-ns", "2023-01-01"); err != nil {
@@ -51,7 +56,7 @@ func TestCleanerRealOperations(t *testing.T) {
 	}
 
 	// Test deletion
-	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", false); err != nil {
+	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", time.Now()); err != nil {
 		t.Fatalf("DeleteNamespace failed: %v", err)
 	}
 
@@ -61,7 +66,7 @@ func TestCleanerRealOperations(t *testing.T) {
 	}
 }
 
-func TestCleanerRemoveFinalizers(t *testing.T) {
+func TestCleanerKeepsFinalizers(t *testing.T) {
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:       "test-ns",
@@ -69,31 +74,77 @@ func TestCleanerRemoveFinalizers(t *testing.T) {
 		},
 	}
 	client := fake.NewSimpleClientset(ns)
-	cleaner := NewCleaner(false, client)
+	cleaner := NewCleaner(&config.Config{DeletionPropagation: config.PropagationForeground}, client, nil, nil, nil, nil, nil)
 
-	// Test deletion with test mode (should remove finalizers)
-	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", true); err != nil {
+	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", time.Now()); err != nil {
 		t.Fatalf("DeleteNamespace failed: %v", err)
 	}
 
-	// Check that namespace was updated to remove finalizers
-	actions := client.Actions()
-	if len(actions) < 2 {
-		t.Fatalf("Expected at least 2 actions, got %d", len(actions))
+	// Finalizers must be left to their controllers, so only a get and a
+	// delete are expected
+	for _, action := range client.Actions() {
+		if _, ok := action.(clienttesting.UpdateAction); ok {
+			t.Fatalf("Namespace should not be updated before deletion: %#v", action)
+		}
 	}
 
-	// The second action should be the update that removes finalizers
-	updateAction, ok := actions[1].(clienttesting.UpdateAction)
+	deleteAction, ok := client.Actions()[len(client.Actions())-1].(clienttesting.DeleteAction)
 	if !ok {
-		t.Fatalf("Second action is not an update: %#v", actions[1])
+		t.Fatalf("Last action is not a delete: %#v", client.Actions())
+	}
+	policy := deleteAction.GetDeleteOptions().PropagationPolicy
+	if policy == nil || *policy != metav1.DeletePropagationForeground {
+		t.Errorf("Expected foreground propagation, got %v", policy)
 	}
+}
 
-	updatedNs, ok := updateAction.GetObject().(*corev1.Namespace)
-	if !ok {
-		t.Fatalf("Updated object is not a namespace: %#v", updateAction.GetObject())
+func TestNotifyOwnerOnce(t *testing.T) {
+	sink, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatalf("Failed to start SMTP sink: %v", err)
 	}
+	defer sink.Close()
+
+	cfg := &config.Config{
+		SMTPHost:     sink.Host(),
+		SMTPPort:     sink.Port(),
+		SMTPFrom:     "cleaner@example.com",
+		NotifyEvents: []string{"labeled"},
+	}
+	ownerNotifier, err := notifier.New(cfg)
+	if err != nil {
+		t.Fatalf("notifier.New failed: %v", err)
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+	client := fake.NewSimpleClientset(ns)
+	cleaner := NewCleaner(cfg, client, nil, ownerNotifier, nil, nil, nil)
 
-	if len(updatedNs.Finalizers) > 0 {
-		t.Errorf("Finalizers should be removed in test mode, found: %v", updatedNs.Finalizers)
+	notice := notifier.Notice{
+		Event:     notifier.EventLabeled,
+		Namespace: "test-ns",
+		Owner:     "user@example.com",
+		DeleteAt:  time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
 	}
-}
\ No newline at end of file
+	if err := cleaner.NotifyOwner(context.TODO(), ns, notice); err != nil {
+		t.Fatalf("NotifyOwner failed: %v", err)
+	}
+
+	// A later run sees the annotation and does not send again
+	annotated, _ := client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if err := cleaner.NotifyOwner(context.TODO(), annotated, notice); err != nil {
+		t.Fatalf("NotifyOwner failed: %v", err)
+	}
+	if got := len(sink.Messages()); got != 1 {
+		t.Errorf("Expected 1 message, got %d", got)
+	}
+
+	// Disabled events are not sent
+	notice.Event = notifier.EventDeleted
+	if err := cleaner.NotifyOwner(context.TODO(), annotated, notice); err != nil {
+		t.Fatalf("NotifyOwner failed: %v", err)
+	}
+	if got := len(sink.Messages()); got != 1 {
+		t.Errorf("Disabled event should not be sent, got %d messages", got)
+	}
+}
@@ -0,0 +1,217 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// Annotations the Kubeflow profile controller and dashboard put on the
+// RoleBindings and AuthorizationPolicies they create for a namespace's
+// owner and contributors
+const (
+	contributorUserKey = "user"
+	contributorRoleKey = "role"
+
+	// userIDHeader is the header Kubeflow AuthorizationPolicies match the
+	// contributor on
+	userIDHeader = "request.headers[kubeflow-userid]"
+
+	checkContributors = "contributors"
+)
+
+var authorizationPolicyGVR = schema.GroupVersionResource{
+	Group:    "security.istio.io",
+	Version:  "v1beta1",
+	Resource: "authorizationpolicies",
+}
+
+// Contributor is a user granted access to a namespace
+type Contributor struct {
+	Email  string
+	Role   string
+	Source string
+}
+
+// Contributors lists the users with access to a namespace, read from
+// RoleBindings carrying the Kubeflow user and role annotations and from
+// the matching Istio AuthorizationPolicies. A user granted the same role
+// by both is listed once. They are sorted by email so successors and
+// co-owners are picked the same way on every run.
+func (c *Cleaner) Contributors(ctx context.Context, nsName string) ([]Contributor, error) {
+	bindings, err := c.kubeClient.RbacV1().RoleBindings(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var contributors []Contributor
+	seen := map[Contributor]bool{}
+	add := func(email, role, source string) {
+		key := Contributor{Email: email, Role: role}
+		if email == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		contributors = append(contributors, Contributor{Email: email, Role: role, Source: source})
+	}
+
+	for _, rb := range bindings.Items {
+		add(rb.Annotations[contributorUserKey], rb.Annotations[contributorRoleKey], "RoleBinding/"+rb.Name)
+	}
+
+	policies, err := c.authorizationPolicies(ctx, nsName)
+	if err != nil {
+		return nil, err
+	}
+	for _, policy := range policies {
+		source := "AuthorizationPolicy/" + policy.GetName()
+		role := policy.GetAnnotations()[contributorRoleKey]
+		if email := policy.GetAnnotations()[contributorUserKey]; email != "" {
+			add(email, role, source)
+			continue
+		}
+		for _, email := range policyUsers(&policy) {
+			add(email, role, source)
+		}
+	}
+
+	sort.SliceStable(contributors, func(i, j int) bool {
+		return contributors[i].Email < contributors[j].Email
+	})
+	return contributors, nil
+}
+
+// authorizationPolicies lists a namespace's Istio AuthorizationPolicies.
+// Clusters without Istio have none.
+func (c *Cleaner) authorizationPolicies(ctx context.Context, nsName string) ([]unstructured.Unstructured, error) {
+	if c.dynamicClient == nil {
+		return nil, nil
+	}
+
+	list, err := c.dynamicClient.Resource(authorizationPolicyGVR).Namespace(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// policyUsers reads the users an AuthorizationPolicy lets in through the
+// kubeflow-userid header
+func policyUsers(policy *unstructured.Unstructured) []string {
+	rules, _, _ := unstructured.NestedSlice(policy.Object, "spec", "rules")
+
+	var users []string
+	for _, rule := range rules {
+		fields, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(fields, "when")
+		for _, condition := range conditions {
+			fields, ok := condition.(map[string]interface{})
+			if !ok || fields["key"] != userIDHeader {
+				continue
+			}
+			values, _, _ := unstructured.NestedStringSlice(fields, "values")
+			users = append(users, values...)
+		}
+	}
+	return users
+}
+
+// activeContributor returns the first contributor other than the owner who
+// holds one of cfg.ContributorRoles and exists in Entra ID, or nil
+func activeContributor(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	nsName string,
+	cfg *config.Config,
+	email string,
+) (*Contributor, error) {
+	contributors, err := cleaner.Contributors(ctx, nsName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range contributors {
+		if !containsString(cfg.ContributorRoles, c.Role) {
+			continue
+		}
+		active, err := activeUser(ctx, cfg, graph, c.Email, email)
+		if err != nil {
+			return nil, err
+		}
+		if active {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// activeUser reports whether someone other than the owner, in an allowed
+// domain, exists in Entra ID. The domain's keep and expire rules answer
+// without a lookup.
+func activeUser(ctx context.Context, cfg *config.Config, graph *msgraphsdk.GraphServiceClient, candidate, owner string) (bool, error) {
+	if candidate == "" || strings.EqualFold(candidate, owner) {
+		return false, nil
+	}
+	match, _ := clients.MatchOwnerDomain(candidate, cfg)
+	switch match.Action {
+	case config.DomainActionAllow:
+		return clients.UserExists(ctx, cfg, graph, candidate)
+	case config.DomainActionKeep:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// contributorExists looks for an active contributor once the owner is
+// gone and returns their email, or "" when there is none. When the lookup
+// fails the namespace is skipped for this run, reported by the second result.
+func contributorExists(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email string,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) (string, bool) {
+	contributor, err := activeContributor(ctx, cleaner, graph, ns.Name, cfg, email)
+	if err != nil {
+		decision.AddCheck(checkContributors, "failed: %v", err)
+		stats.IncSkippedLookupFailed()
+		decision.Fail("lookup_failed", logError(logger, stats, "Error looking up contributors of %s: %v", ns.Name, err))
+		cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonLookupFailed,
+			fmt.Sprintf("Skipped: could not look up contributors: %v", err))
+		return "", false
+	}
+	if contributor == nil {
+		decision.AddCheck(checkContributors, "none active with a role in [%s]", strings.Join(cfg.ContributorRoles, ", "))
+		return "", true
+	}
+
+	decision.AddCheck(checkContributors, "%s (%s, from %s) is active", contributor.Email, contributor.Role, contributor.Source)
+	logger.Debug("Found active contributor", "contributor", contributor.Email, "role", contributor.Role)
+	return contributor.Email, true
+}
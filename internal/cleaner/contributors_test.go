@@ -0,0 +1,144 @@
+package cleaner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func newAuthorizationPolicy(name string, annotations map[string]string, users ...interface{}) *unstructured.Unstructured {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{
+					"when": []interface{}{
+						map[string]interface{}{"key": userIDHeader, "values": users},
+					},
+				},
+			},
+		},
+	}}
+	policy.SetAPIVersion("security.istio.io/v1beta1")
+	policy.SetKind("AuthorizationPolicy")
+	policy.SetNamespace("test-ns")
+	policy.SetName(name)
+	policy.SetAnnotations(annotations)
+	return policy
+}
+
+func TestContributors(t *testing.T) {
+	binding := func(name, user, role string) *rbacv1.RoleBinding {
+		rb := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns"}}
+		if user != "" {
+			rb.Annotations = map[string]string{contributorUserKey: user, contributorRoleKey: role}
+		}
+		return rb
+	}
+	client := fake.NewSimpleClientset(
+		binding("user-zed-example-com-clusterrole-edit", "zed@example.com", "edit"),
+		binding("namespaceAdmin", "owner@example.com", "admin"),
+		binding("default-editor", "", ""),
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{authorizationPolicyGVR: "AuthorizationPolicyList"},
+		newAuthorizationPolicy("user-zed-example-com-clusterrole-edit",
+			map[string]string{contributorUserKey: "zed@example.com", contributorRoleKey: "edit"}),
+		newAuthorizationPolicy("user-amy-example-com-clusterrole-view",
+			map[string]string{contributorRoleKey: "view"}, "amy@example.com"),
+	)
+	cleaner := NewCleaner(&config.Config{}, client, dynamicClient, nil, nil, nil, nil)
+
+	contributors, err := cleaner.Contributors(context.TODO(), "test-ns")
+	if err != nil {
+		t.Fatalf("Contributors failed: %v", err)
+	}
+	want := []Contributor{
+		{Email: "amy@example.com", Role: "view", Source: "AuthorizationPolicy/user-amy-example-com-clusterrole-view"},
+		{Email: "owner@example.com", Role: "admin", Source: "RoleBinding/namespaceAdmin"},
+		{Email: "zed@example.com", Role: "edit", Source: "RoleBinding/user-zed-example-com-clusterrole-edit"},
+	}
+	if len(contributors) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, contributors)
+	}
+	for i := range want {
+		if contributors[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], contributors[i])
+		}
+	}
+}
+
+func TestContributorsKeepNamespace(t *testing.T) {
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	contributors := map[string][]Contributor{"test-ns": {
+		{Email: "old@example.com", Role: "admin", Source: "RoleBinding/namespaceAdmin"},
+		{Email: "viewer@example.com", Role: "view", Source: "RoleBinding/viewer"},
+		{Email: "editor@example.com", Role: "edit", Source: "RoleBinding/editor"},
+	}}
+
+	testCases := []struct {
+		name         string
+		enabled      bool
+		users        []string
+		labeled      bool
+		wantDecision string
+		wantReason   string
+	}{
+		{"unlabeled kept by contributor", true, []string{"editor@example.com"}, false, stats.DecisionSkipped, "contributor_exists"},
+		{"unlabeled without active contributor", true, []string{"viewer@example.com"}, false, stats.DecisionLabeled, "owner_not_found"},
+		{"unlabeled with option off", false, []string{"editor@example.com"}, false, stats.DecisionLabeled, "owner_not_found"},
+		{"labeled kept by contributor", true, []string{"editor@example.com"}, true, stats.DecisionLabelRemoved, "contributor_found"},
+		{"labeled without active contributor", true, nil, true, stats.DecisionDeleted, "grace_period_expired"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Labels:      map[string]string{},
+					Annotations: map[string]string{"owner": "old@example.com"},
+				},
+			}
+			cleaner := &mockCleaner{contributors: contributors}
+			s := &stats.Stats{}
+			cfg := &config.Config{
+				TestMode:             true,
+				TestUsers:            tc.users,
+				AllowedDomains:       []string{"example.com"},
+				ContributorRoles:     []string{"admin", "edit"},
+				ContributorsAsOwners: tc.enabled,
+			}
+
+			if tc.labeled {
+				ns.Labels[labelKey] = "2023-01-05_00-00-00Z"
+				processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+			} else {
+				processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-02-10_00-00-00Z", today, s)
+			}
+
+			decision := s.Decisions[0]
+			if decision.Decision != tc.wantDecision || decision.Reason != tc.wantReason {
+				t.Errorf("Expected %s (%s), got %s (%s)", tc.wantDecision, tc.wantReason, decision.Decision, decision.Reason)
+			}
+			if tc.wantReason == "contributor_found" {
+				entry := cleaner.audited[0]
+				if entry.Owner != "old@example.com" || entry.Evidence.Lookup != "editor@example.com" || !entry.Evidence.Found {
+					t.Errorf("Unexpected audit entry %+v", entry)
+				}
+			}
+		})
+	}
+}
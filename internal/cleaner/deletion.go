@@ -0,0 +1,157 @@
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// deletionPollInterval is how often awaitDeletion checks the namespace
+const deletionPollInterval = 2 * time.Second
+
+// blockingConditions are the namespace conditions the namespace controller
+// sets when it cannot finish removing a namespace's content
+var blockingConditions = []corev1.NamespaceConditionType{
+	corev1.NamespaceContentRemaining,
+	corev1.NamespaceFinalizersRemaining,
+	corev1.NamespaceDeletionContentFailure,
+	corev1.NamespaceDeletionDiscoveryFailure,
+	corev1.NamespaceDeletionGVParsingFailure,
+}
+
+// StuckNamespaceError reports a namespace that did not finish terminating
+type StuckNamespaceError struct {
+	Namespace string
+	// Remaining lists what blocks finalization, taken from the namespace
+	// finalizers and status conditions
+	Remaining []string
+}
+
+func (e *StuckNamespaceError) Error() string {
+	if len(e.Remaining) == 0 {
+		return fmt.Sprintf("namespace %s is stuck terminating", e.Namespace)
+	}
+	return fmt.Sprintf("namespace %s is stuck terminating: %s", e.Namespace, strings.Join(e.Remaining, "; "))
+}
+
+// IsStuckNamespace reports whether err is a StuckNamespaceError
+func IsStuckNamespace(err error) bool {
+	var stuck *StuckNamespaceError
+	return errors.As(err, &stuck)
+}
+
+// TerminatingNamespaceError reports a namespace that is already being
+// deleted and still within the deletion timeout. Its deletion is pending,
+// not done, so it is neither counted nor audited again.
+type TerminatingNamespaceError struct {
+	Namespace string
+	// Since is when the namespace started terminating
+	Since time.Time
+}
+
+func (e *TerminatingNamespaceError) Error() string {
+	return fmt.Sprintf("namespace %s has been terminating since %s", e.Namespace, e.Since.UTC().Format(time.RFC3339))
+}
+
+// IsTerminatingNamespace reports whether err is a TerminatingNamespaceError
+func IsTerminatingNamespace(err error) bool {
+	var terminating *TerminatingNamespaceError
+	return errors.As(err, &terminating)
+}
+
+// awaitDeletion waits until a namespace is gone when waiting is enabled
+func (c *Cleaner) awaitDeletion(ctx context.Context, nsName string) error {
+	if c.dryRun || !c.waitForDeletion {
+		return nil
+	}
+
+	var last *corev1.Namespace
+	err := wait.PollUntilContextTimeout(ctx, deletionPollInterval, c.deletionTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			ns, err := c.kubeClient.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			last = ns
+			return false, nil
+		})
+	if err == nil {
+		return nil
+	}
+	if last != nil && wait.Interrupted(err) {
+		return stuckError(last)
+	}
+	return err
+}
+
+// checkTerminating reports a namespace that is already terminating: as
+// stuck once it has been terminating for longer than the deletion timeout,
+// and as still terminating before that, both measured at now
+func (c *Cleaner) checkTerminating(ns *corev1.Namespace, now time.Time) error {
+	if now.Sub(ns.DeletionTimestamp.Time) < c.deletionTimeout {
+		return &TerminatingNamespaceError{Namespace: ns.Name, Since: ns.DeletionTimestamp.Time}
+	}
+	return stuckError(ns)
+}
+
+// deleteFailed records a DeleteNamespace error on the decision. A namespace
+// still terminating from an earlier run is pending; a stuck one is counted
+// and reported with an event before the failure is recorded.
+func deleteFailed(
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	err error,
+	reason string,
+	deletionDate time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) {
+	if IsTerminatingNamespace(err) {
+		logger.Info("Namespace is still terminating", "reason", reason)
+		stats.AddPending(ns.Name, deletionDate)
+		decision.Pending("terminating", deletionDate)
+		return
+	}
+	if IsStuckNamespace(err) {
+		stats.IncStuck()
+		cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonStuck, err.Error())
+	}
+	decision.Fail(reason, logError(logger, stats, "Error deleting ns %s: %v", ns.Name, err))
+}
+
+// stuckError collects the finalizers and failing conditions of a namespace
+func stuckError(ns *corev1.Namespace) *StuckNamespaceError {
+	stuck := &StuckNamespaceError{Namespace: ns.Name}
+	for _, finalizer := range ns.Finalizers {
+		stuck.Remaining = append(stuck.Remaining, "finalizer "+finalizer)
+	}
+	for _, finalizer := range ns.Spec.Finalizers {
+		stuck.Remaining = append(stuck.Remaining, "finalizer "+string(finalizer))
+	}
+	for _, cond := range ns.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		for _, blocking := range blockingConditions {
+			if cond.Type == blocking {
+				stuck.Remaining = append(stuck.Remaining, cond.Message)
+				break
+			}
+		}
+	}
+	return stuck
+}
@@ -0,0 +1,154 @@
+package cleaner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// blockedNamespace returns a namespace whose content cannot be removed
+func blockedNamespace() *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test-ns",
+			Finalizers: []string{"example.com/cleanup"},
+		},
+		Spec: corev1.NamespaceSpec{
+			Finalizers: []corev1.FinalizerName{corev1.FinalizerKubernetes},
+		},
+		Status: corev1.NamespaceStatus{
+			Phase: corev1.NamespaceTerminating,
+			Conditions: []corev1.NamespaceCondition{
+				{
+					Type:    corev1.NamespaceContentRemaining,
+					Status:  corev1.ConditionTrue,
+					Message: "Some resources are remaining: notebooks.kubeflow.org has 1 resource instances",
+				},
+				{
+					Type:   corev1.NamespaceDeletionDiscoveryFailure,
+					Status: corev1.ConditionFalse,
+				},
+			},
+		},
+	}
+}
+
+func TestDeleteNamespaceWaitsForRemoval(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}})
+	cleaner := NewCleaner(&config.Config{WaitForDeletion: true, DeletionTimeout: time.Second}, client, nil, nil, nil, nil, nil)
+
+	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", time.Now()); err != nil {
+		t.Fatalf("DeleteNamespace failed: %v", err)
+	}
+}
+
+func TestDeleteNamespaceWaitTimeout(t *testing.T) {
+	client := fake.NewSimpleClientset(blockedNamespace())
+	// Keep the namespace around on delete, as a blocked finalizer would
+	client.PrependReactor("delete", "namespaces", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, nil
+	})
+	cleaner := NewCleaner(&config.Config{WaitForDeletion: true, DeletionTimeout: 10 * time.Millisecond}, client, nil, nil, nil, nil, nil)
+
+	err := cleaner.DeleteNamespace(context.TODO(), "test-ns", time.Now())
+	if !IsStuckNamespace(err) {
+		t.Fatalf("Expected StuckNamespaceError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "notebooks.kubeflow.org") {
+		t.Errorf("Error should list remaining resources: %v", err)
+	}
+}
+
+func TestDeleteNamespaceStuckTerminating(t *testing.T) {
+	now := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name      string
+		since     time.Time
+		wantStuck bool
+	}{
+		{"recently terminating", now.Add(-4 * time.Minute), false},
+		{"terminating past timeout", now.Add(-6 * time.Minute), true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := blockedNamespace()
+			deletedAt := metav1.NewTime(tc.since)
+			ns.DeletionTimestamp = &deletedAt
+			client := fake.NewSimpleClientset(ns)
+			cleaner := NewCleaner(&config.Config{DeletionTimeout: 5 * time.Minute}, client, nil, nil, nil, nil, nil)
+
+			err := cleaner.DeleteNamespace(context.TODO(), "test-ns", now)
+			if got := IsStuckNamespace(err); got != tc.wantStuck {
+				t.Fatalf("stuck = %v, want %v (err: %v)", got, tc.wantStuck, err)
+			}
+			if !tc.wantStuck {
+				if !IsTerminatingNamespace(err) {
+					t.Fatalf("Expected TerminatingNamespaceError, got %v", err)
+				}
+				return
+			}
+
+			stuck := err.(*StuckNamespaceError)
+			want := []string{
+				"finalizer example.com/cleanup",
+				"finalizer kubernetes",
+				"Some resources are remaining: notebooks.kubeflow.org has 1 resource instances",
+			}
+			if strings.Join(stuck.Remaining, "|") != strings.Join(want, "|") {
+				t.Errorf("Remaining = %v, want %v", stuck.Remaining, want)
+			}
+		})
+	}
+}
+
+func TestDeleteNamespaceAlreadyGone(t *testing.T) {
+	cleaner := NewCleaner(&config.Config{}, fake.NewSimpleClientset(), nil, nil, nil, nil, nil)
+
+	if err := cleaner.DeleteNamespace(context.TODO(), "missing-ns", time.Now()); err != nil {
+		t.Errorf("Deleting a missing namespace should succeed, got %v", err)
+	}
+}
+
+func TestProcessTerminatingNamespace(t *testing.T) {
+	restore := MockUserExists(false)
+	defer restore()
+
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	deletionDate := time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-ns",
+			Labels:      map[string]string{labelKey: deletionDate.Format(labelTimeLayout)},
+			Annotations: map[string]string{"owner": "user@example.com"},
+		},
+	}
+	cleaner := &mockCleaner{deleteErr: &TerminatingNamespaceError{Namespace: "test-ns", Since: today}}
+	s := &stats.Stats{}
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}}
+	processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+
+	decision := s.Decisions[0]
+	if decision.Decision != stats.DecisionPending || decision.Reason != "terminating" {
+		t.Errorf("Expected pending (terminating), got %s (%s)", decision.Decision, decision.Reason)
+	}
+	if s.Deleted != 0 || len(s.Errors) != 0 {
+		t.Errorf("A terminating namespace should be neither deleted nor failed, got %d deleted, errors %v", s.Deleted, s.Errors)
+	}
+	if !s.PendingDeletion["test-ns"].Equal(deletionDate) {
+		t.Errorf("Expected the namespace to be pending, got %v", s.PendingDeletion)
+	}
+	if len(cleaner.audited) != 0 || len(cleaner.notices) != 0 {
+		t.Errorf("Expected no audit record or notice, got %v and %v", cleaner.audited, cleaner.notices)
+	}
+}
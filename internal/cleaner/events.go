@@ -0,0 +1,42 @@
+package cleaner
+
+import (
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+)
+
+// Reasons of the events recorded on namespaces, one per cleaner decision
+const (
+	ReasonLabeled      = "MarkedForDeletion"
+	ReasonLabelRemoved = "DeletionCancelled"
+	ReasonSkipped      = "CleanupSkipped"
+	ReasonInvalidLabel = "InvalidDeleteAtLabel"
+	ReasonQuarantined  = "NamespaceQuarantined"
+	ReasonRestored     = "QuarantineReverted"
+	ReasonDeleted      = "NamespaceDeleted"
+	ReasonStuck        = "DeletionStuck"
+	ReasonLookupFailed = "OwnerLookupFailed"
+
+	ReasonExtended          = "DeletionExtended"
+	ReasonExtensionRejected = "ExtensionRejected"
+	ReasonTransferred       = "OwnershipTransferred"
+	ReasonDeferred          = "DeletionDeferred"
+)
+
+// RecordEvent records an event on a namespace so its users can see the
+// decision with kubectl describe. It does nothing without a recorder.
+func (c *Cleaner) RecordEvent(ns *corev1.Namespace, eventType, reason, message string) {
+	if c.recorder == nil {
+		return
+	}
+
+	if c.dryRun {
+		slog.Info("Would record event", logging.KeyNamespace, ns.Name, logging.KeyReason, reason, "message", message)
+		return
+	}
+
+	c.recorder.Event(ns, eventType, reason, message)
+}
@@ -0,0 +1,46 @@
+package cleaner
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestRecordEvent(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+
+	testCases := []struct {
+		name   string
+		dryRun bool
+		want   int
+	}{
+		{"records event", false, 1},
+		{"dry run records nothing", true, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			cleaner := NewCleaner(&config.Config{DryRun: tc.dryRun}, fake.NewSimpleClientset(), nil, nil, recorder, nil, nil)
+
+			cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonLabeled, "owner gone")
+
+			if len(recorder.Events) != tc.want {
+				t.Fatalf("Expected %d events, got %d", tc.want, len(recorder.Events))
+			}
+			if tc.want > 0 {
+				if got := <-recorder.Events; got != "Warning MarkedForDeletion owner gone" {
+					t.Errorf("Unexpected event %q", got)
+				}
+			}
+		})
+	}
+
+	// A cleaner without a recorder must not panic
+	NewCleaner(&config.Config{}, fake.NewSimpleClientset(), nil, nil, nil, nil, nil).RecordEvent(ns, corev1.EventTypeNormal, ReasonDeleted, "gone")
+}
@@ -0,0 +1,179 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// checkSelector is the first step listed by explain
+const checkSelector = "selector"
+
+// Explanation is the outcome of evaluating one namespace without acting
+type Explanation struct {
+	Namespace string
+	Phase     string
+	Decision  *stats.Decision
+}
+
+// Explain runs the same evaluation as a cleaner run for one namespace.
+// Every side effect is discarded; only reads such as the identity lookup
+// and the Profile owner are performed.
+func Explain(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	kube kubernetes.Interface,
+	cfg *config.Config,
+	nsName string,
+	referenceTime time.Time,
+) (*Explanation, error) {
+	ns, err := kube.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	phase, selectorResult, err := matchPhase(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	runStats := &stats.Stats{}
+	readOnly := &readOnlyCleaner{cleaner: cleaner}
+	switch phase {
+	case phaseUnlabeled:
+		processUnlabeledNamespace(ctx, readOnly, graph, ns, cfg, gracePeriodEnd(cfg, referenceTime), referenceTime, runStats)
+	case phaseLabeled:
+		processLabeledNamespace(ctx, readOnly, graph, ns, cfg, referenceTime, runStats)
+	default:
+		runStats.NewDecision(ns.Name).Skip("not_selected")
+	}
+
+	decision := runStats.Decisions[0]
+	decision.Checks = append([]stats.Check{{Name: checkSelector, Result: selectorResult}}, decision.Checks...)
+	return &Explanation{Namespace: ns.Name, Phase: phase, Decision: decision}, nil
+}
+
+// matchPhase finds the phase whose selector matches the namespace
+func matchPhase(ns *corev1.Namespace) (string, string, error) {
+	for _, phase := range []struct{ name, selector string }{
+		{phaseUnlabeled, unlabeledSelector},
+		{phaseLabeled, labeledSelector},
+	} {
+		selector, err := labels.Parse(phase.selector)
+		if err != nil {
+			return "", "", err
+		}
+		if selector.Matches(labels.Set(ns.Labels)) {
+			return phase.name, fmt.Sprintf("matches %s phase (%s)", phase.name, phase.selector), nil
+		}
+	}
+	return "", fmt.Sprintf("matches neither %q nor %q; the cleaner ignores this namespace", unlabeledSelector, labeledSelector), nil
+}
+
+// Write prints the checks in order, then the final action
+func (e *Explanation) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Namespace:\t%s\n", e.Namespace)
+	for i, check := range e.Decision.Checks {
+		fmt.Fprintf(tw, "%d. %s:\t%s\n", i+1, check.Name, check.Result)
+	}
+
+	action := e.Decision.Decision + " (" + e.Decision.Reason + ")"
+	if e.Decision.NewDeleteAt != nil {
+		action += ", delete-at " + e.Decision.NewDeleteAt.Format(time.RFC3339)
+	}
+	if e.Decision.Error != "" {
+		action += ": " + strings.TrimSpace(e.Decision.Error)
+	}
+	fmt.Fprintf(tw, "Action:\t%s\n", action)
+	return tw.Flush()
+}
+
+// readOnlyCleaner discards every change a NamespaceCleaner would make.
+// Only the Profile owner, RoleBinding, contributor and activity lookups,
+// and the policy, are passed through.
+type readOnlyCleaner struct {
+	cleaner NamespaceCleaner
+}
+
+func (r *readOnlyCleaner) LabelNamespace(ctx context.Context, nsName, graceDate string) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) RemoveLabel(ctx context.Context, nsName string) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) ExtendDeletion(ctx context.Context, nsName, deleteAt, originalDeleteAt, extendedBy string) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) DeleteNamespace(ctx context.Context, nsName string, now time.Time) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) QuarantineNamespace(ctx context.Context, nsName, purgeDate string) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) RestoreNamespace(ctx context.Context, nsName string) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) ProfileOwner(ctx context.Context, nsName string) (string, error) {
+	return r.cleaner.ProfileOwner(ctx, nsName)
+}
+
+func (r *readOnlyCleaner) Contributors(ctx context.Context, nsName string) ([]Contributor, error) {
+	return r.cleaner.Contributors(ctx, nsName)
+}
+
+func (r *readOnlyCleaner) LastActivity(ctx context.Context, ns *corev1.Namespace, now time.Time) (Activity, error) {
+	return r.cleaner.LastActivity(ctx, ns, now)
+}
+
+func (r *readOnlyCleaner) Policy() *policy.Engine {
+	return r.cleaner.Policy()
+}
+
+func (r *readOnlyCleaner) MarkIdle(ctx context.Context, nsName, graceDate, idleSince string) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) EarliestRoleBindingUser(ctx context.Context, nsName string) (string, error) {
+	return r.cleaner.EarliestRoleBindingUser(ctx, nsName)
+}
+
+func (r *readOnlyCleaner) MarkOwnerless(ctx context.Context, nsName, graceDate string) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) TransferOwnership(ctx context.Context, nsName, previousOwner, newOwner string) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) NotifyOwner(ctx context.Context, ns *corev1.Namespace, notice notifier.Notice) error {
+	return nil
+}
+
+func (r *readOnlyCleaner) RecordEvent(ns *corev1.Namespace, eventType, reason, message string) {}
+
+func (r *readOnlyCleaner) Audit(ctx context.Context, entry audit.Entry) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package cleaner
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func TestExplain(t *testing.T) {
+	referenceTime := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	pastDate := referenceTime.Add(-36 * time.Hour).Format(labelTimeLayout)
+	futureDate := referenceTime.Add(50 * time.Hour).Format(labelTimeLayout)
+	profileLabel := map[string]string{"app.kubeflow.org/part-of": "kubeflow-profile"}
+
+	testCases := []struct {
+		name         string
+		labels       map[string]string
+		owner        string
+		userExists   bool
+		wantDecision string
+		wantOutput   []string
+	}{
+		{
+			name:         "owner gone would be labeled",
+			labels:       profileLabel,
+			owner:        "user@example.com",
+			wantDecision: stats.DecisionLabeled,
+			wantOutput: []string{
+				"1. selector:", "matches unlabeled phase",
+				"2. owner:", "user@example.com (from owner annotation)",
+				"3. domain rules:", `allowed by "example.com"`,
+				"4. identity lookup:", "user@example.com not found in Entra ID",
+				"Action:", "labeled (owner_not_found), delete-at 2023-02-01T00:00:00Z",
+			},
+		},
+		{
+			name:         "expired label would be deleted",
+			labels:       map[string]string{labelKey: pastDate},
+			owner:        "user@sub.example.com",
+			wantDecision: stats.DecisionDeleted,
+			wantOutput: []string{
+				"delete-at:", "2022-12-31T12:00:00Z",
+				"time remaining:", "1d 12h overdue",
+				"Action:", "deleted (grace_period_expired)",
+			},
+		},
+		{
+			name:         "pending label",
+			labels:       map[string]string{labelKey: futureDate},
+			owner:        "user@example.com",
+			wantDecision: stats.DecisionPending,
+			wantOutput:   []string{"time remaining:", "2d 2h left", "pending (grace_period_running)"},
+		},
+		{
+			name:         "foreign domain",
+			labels:       profileLabel,
+			owner:        "user@example.org",
+			wantDecision: stats.DecisionSkipped,
+			wantOutput:   []string{"rejected: no rule in [example.com] matches", "skipped (invalid_domain)"},
+		},
+		{
+			name:         "not selected",
+			labels:       map[string]string{},
+			owner:        "user@example.com",
+			wantDecision: stats.DecisionSkipped,
+			wantOutput:   []string{"the cleaner ignores this namespace", "skipped (not_selected)"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			restore := MockUserExists(tc.userExists)
+			defer restore()
+
+			client := fake.NewSimpleClientset(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Labels:      tc.labels,
+					Annotations: map[string]string{"owner": tc.owner},
+				},
+			})
+			cleaner := &mockCleaner{}
+			cfg := &config.Config{AllowedDomains: []string{"example.com"}, GracePeriod: 30}
+
+			explanation, err := Explain(context.TODO(), cleaner, nil, client, cfg, "test-ns", referenceTime)
+			if err != nil {
+				t.Fatalf("Explain failed: %v", err)
+			}
+			if explanation.Decision.Decision != tc.wantDecision {
+				t.Errorf("Expected %s, got %s", tc.wantDecision, explanation.Decision.Decision)
+			}
+			if len(cleaner.labeled)+len(cleaner.deleted)+len(cleaner.events)+len(cleaner.audited) != 0 {
+				t.Errorf("Explain must not act on the namespace: %+v", cleaner)
+			}
+
+			var out bytes.Buffer
+			if err := explanation.Write(&out); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			for _, want := range tc.wantOutput {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("Expected output to contain %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestExplainMissingNamespace(t *testing.T) {
+	_, err := Explain(context.TODO(), &mockCleaner{}, nil, fake.NewSimpleClientset(), &config.Config{}, "missing", time.Now())
+	if err == nil {
+		t.Error("Expected an error for a missing namespace")
+	}
+}
@@ -0,0 +1,97 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+const checkExpression = "expression"
+
+// evaluateExpressions gathers the facts the policy expressions refer to
+// and returns the first expression that holds, or nil. The owner record
+// and activity are only read when an expression uses them. When reading
+// them fails the namespace is skipped for this run, reported by the
+// second result.
+func evaluateExpressions(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email string,
+	exists bool,
+	today time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) (*policy.Expression, bool) {
+	engine := cleaner.Policy()
+	if !engine.HasExpressions() {
+		return nil, true
+	}
+
+	facts := policy.Facts{
+		Namespace:   ns,
+		Owner:       email,
+		OwnerExists: exists,
+		AgeDays:     int(today.Sub(ns.CreationTimestamp.Time).Hours() / 24),
+	}
+	if exists && engine.Uses(policy.VarOwner) {
+		record, err := clients.UserRecord(ctx, cfg, graph, email)
+		if err != nil {
+			decision.AddCheck(checkExpression, "failed: %v", err)
+			stats.IncSkippedLookupFailed()
+			decision.Fail("lookup_failed", logError(logger, stats, "Error reading owner record of %s: %v", ns.Name, err))
+			cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonLookupFailed,
+				fmt.Sprintf("Skipped: could not read owner %s: %v", email, err))
+			return nil, false
+		}
+		facts.OwnerRecord = record
+	}
+	if engine.Uses(policy.VarIdleDays) {
+		activity, ok := evaluateActivity(ctx, cleaner, ns, today, stats, decision, logger)
+		if !ok {
+			return nil, false
+		}
+		facts.IdleDays = activity.idleDays(today)
+	}
+
+	expression, err := engine.Evaluate(facts)
+	if err != nil {
+		logger.Warn("Error evaluating expressions", logging.KeyError, err)
+	}
+	if expression == nil {
+		decision.AddCheck(checkExpression, "none of %d hold", len(engine.Expressions))
+		return nil, true
+	}
+	decision.Expression = expression.Name
+	decision.AddCheck(checkExpression, "%q holds: %s", expression.Name, expression.Decision)
+	logger.Debug("Expression holds", "expression", expression.Name, "decision", expression.Decision)
+	return expression, true
+}
+
+// skipByExpression leaves a namespace alone this run, as an expression
+// decided
+func skipByExpression(
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	expression *policy.Expression,
+	stats *stats.Stats,
+	decision *stats.Decision,
+) {
+	stats.IncSkippedByPolicy()
+	decision.Skip("expression")
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonSkipped,
+		fmt.Sprintf("Skipped: expression %q holds", expression.Name))
+}
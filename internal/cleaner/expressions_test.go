@@ -0,0 +1,198 @@
+package cleaner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func TestExpressions(t *testing.T) {
+	rules, err := policy.Parse([]byte(`
+expressions:
+  - name: production
+    expression: 'ns.labels[?"tier"].orValue("") == "prod"'
+    decision: skip
+  - name: stale-das
+    expression: 'owner.department == "DAS" && idleDays > 60'
+    decision: label
+  - name: scratch
+    expression: 'ns.name.startsWith("scratch-")'
+    decision: delete
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	originalRecord := clients.UserRecord
+	defer func() { clients.UserRecord = originalRecord }()
+	clients.UserRecord = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (*clients.User, error) {
+		return &clients.User{Department: "DAS", AccountEnabled: true}, nil
+	}
+
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name         string
+		nsName       string
+		tier         string
+		ownerExists  bool
+		idleDays     int
+		labeled      bool
+		wantDecision string
+		wantReason   string
+		wantEvent    string
+	}{
+		{"production skipped", "team-a", "prod", false, 200, false, stats.DecisionSkipped, "expression", ReasonSkipped},
+		{"idle DAS namespace labeled", "team-a", "dev", true, 90, false, stats.DecisionLabeled, "expression", ReasonLabeled},
+		{"used DAS namespace kept", "team-a", "dev", true, 5, false, stats.DecisionSkipped, "owner_exists", ""},
+		{"scratch labeled due at once", "scratch-a", "dev", true, 5, false, stats.DecisionLabeled, "expression", ReasonLabeled},
+		{"labeled kept despite owner", "team-a", "dev", true, 90, true, stats.DecisionPending, "grace_period_running", ""},
+		{"labeled owner found", "team-a", "dev", true, 5, true, stats.DecisionLabelRemoved, "owner_found", ReasonLabelRemoved},
+		{"labeled scratch deleted before delete-at", "scratch-a", "dev", true, 5, true, stats.DecisionDeleted, "expression", ReasonDeleted},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			restore := MockUserExists(tc.ownerExists)
+			defer restore()
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        tc.nsName,
+					Labels:      map[string]string{"tier": tc.tier},
+					Annotations: map[string]string{"owner": "user@example.com"},
+				},
+			}
+			cleaner := &mockCleaner{
+				rules:    rules,
+				activity: map[string]Activity{tc.nsName: {IdleSince: today.Add(-time.Duration(tc.idleDays) * 24 * time.Hour)}},
+			}
+			s := &stats.Stats{}
+			cfg := &config.Config{AllowedDomains: []string{"example.com"}}
+
+			if tc.labeled {
+				ns.Labels[labelKey] = "2023-02-01_00-00-00Z"
+				processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+			} else {
+				processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-02-10_00-00-00Z", today, s)
+			}
+
+			decision := s.Decisions[0]
+			if decision.Decision != tc.wantDecision || decision.Reason != tc.wantReason {
+				t.Errorf("Expected %s (%s), got %s (%s)", tc.wantDecision, tc.wantReason, decision.Decision, decision.Reason)
+			}
+			if tc.wantEvent != "" && !contains(cleaner.events, tc.wantEvent) {
+				t.Errorf("Expected a %s event, got %v", tc.wantEvent, cleaner.events)
+			}
+			if tc.wantReason == "expression" && decision.Expression == "" {
+				t.Error("Expected the expression to be recorded")
+			}
+		})
+	}
+}
+
+func TestExpressionDeleteHeldByRule(t *testing.T) {
+	rules, err := policy.Parse([]byte(`
+rules:
+  - name: sandbox
+    match: {name: "^scratch-"}
+    action: label
+expressions:
+  - name: scratch
+    expression: 'ns.name.startsWith("scratch-")'
+    decision: delete
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	restore := MockUserExists(true)
+	defer restore()
+
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}}
+	newNamespace := func() *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "scratch-a",
+				Labels:      map[string]string{},
+				Annotations: map[string]string{"owner": "user@example.com"},
+			},
+		}
+	}
+
+	cleaner := &mockCleaner{rules: rules}
+	s := &stats.Stats{}
+	processUnlabeledNamespace(context.TODO(), cleaner, nil, newNamespace(), cfg, "2023-02-10_00-00-00Z", today, s)
+	decision := s.Decisions[0]
+	if decision.Decision != stats.DecisionLabeled || decision.Reason != "expression" {
+		t.Errorf("Expected labeled (expression), got %s (%s)", decision.Decision, decision.Reason)
+	}
+	if decision.NewDeleteAt == nil || !decision.NewDeleteAt.Equal(today) {
+		t.Errorf("Expected the namespace to be due at once, got %v", decision.NewDeleteAt)
+	}
+	if len(cleaner.deleted) != 0 {
+		t.Error("An unlabeled namespace should be labeled before an expression deletes it")
+	}
+
+	ns := newNamespace()
+	ns.Labels[labelKey] = "2023-02-01_00-00-00Z"
+	s = &stats.Stats{}
+	processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+	decision = s.Decisions[0]
+	if decision.Decision != stats.DecisionSkipped || decision.Reason != "label_only" {
+		t.Errorf("Expected skipped (label_only), got %s (%s)", decision.Decision, decision.Reason)
+	}
+	if len(cleaner.deleted) != 0 {
+		t.Error("A label-only rule should hold an expression delete")
+	}
+}
+
+func TestProcessNamespacesExpressionDelete(t *testing.T) {
+	rules, err := policy.Parse([]byte(`
+expressions:
+  - name: scratch
+    expression: 'ns.name.startsWith("scratch-")'
+    decision: delete
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	restore := MockUserExists(true)
+	defer restore()
+
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "scratch-a",
+			Labels:      map[string]string{"app.kubeflow.org/part-of": "kubeflow-profile"},
+			Annotations: map[string]string{"owner": "user@example.com"},
+		},
+	})
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}, GracePeriod: 30}
+	cleaner := NewCleaner(cfg, client, nil, nil, nil, nil, rules)
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	// The first run only labels the namespace
+	s := ProcessNamespaces(context.TODO(), cleaner, nil, client, cfg, today)
+	if s.Labeled != 1 || s.Deleted != 0 || len(s.Decisions) != 1 {
+		t.Fatalf("Expected the namespace to be labeled only, got %d labeled, %d deleted, %d decisions",
+			s.Labeled, s.Deleted, len(s.Decisions))
+	}
+	if _, err := client.CoreV1().Namespaces().Get(context.TODO(), "scratch-a", metav1.GetOptions{}); err != nil {
+		t.Fatalf("Expected the namespace to survive the run that labeled it: %v", err)
+	}
+
+	// The next run deletes it
+	s = ProcessNamespaces(context.TODO(), cleaner, nil, client, cfg, today.Add(time.Hour))
+	if s.Deleted != 1 || s.Decisions[0].Reason != "expression" {
+		t.Errorf("Expected the next run to delete the namespace, got %d deleted, %+v", s.Deleted, s.Decisions)
+	}
+}
@@ -0,0 +1,206 @@
+package cleaner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+const (
+	// extendUntilKey is set by owners to push deletion back
+	extendUntilKey = "namespace-cleaner/extend-until"
+	// originalDeleteAtKey keeps the delete-at date before any extension,
+	// which the maximum extension is counted from
+	originalDeleteAtKey = "namespace-cleaner/original-delete-at"
+	// extendedByKey names the field manager that set extend-until
+	extendedByKey = "namespace-cleaner/extended-by"
+
+	checkExtension = "extension"
+)
+
+// extendUntilLayouts are the accepted extend-until formats
+var extendUntilLayouts = []string{"2006-01-02", time.RFC3339, labelTimeLayout}
+
+// ExtendDeletion moves the delete-at label to a later date requested by
+// the owner, keeping the original date and who asked for it
+func (c *Cleaner) ExtendDeletion(ctx context.Context, nsName, deleteAt, originalDeleteAt, extendedBy string) error {
+	if c.dryRun {
+		slog.Info("Would extend deletion", logging.KeyNamespace, nsName, logging.KeyAction, "extend",
+			"delete_at", deleteAt, "extended_by", extendedBy)
+		return nil
+	}
+
+	patch := mustMarshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{labelKey: deleteAt},
+			"annotations": map[string]string{
+				originalDeleteAtKey: originalDeleteAt,
+				extendedByKey:       extendedBy,
+			},
+		},
+	})
+	_, err := c.kubeClient.CoreV1().Namespaces().Patch(
+		ctx, nsName, types.MergePatchType, patch, metav1.PatchOptions{},
+	)
+	return err
+}
+
+// applyExtension honours the owner's extend-until annotation and returns
+// the delete-at date in effect. Extensions that cannot be parsed or go
+// past the maximum are rejected with an event and change nothing.
+func applyExtension(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email string,
+	deletionDate time.Time,
+	today time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) time.Time {
+	value, found := ns.Annotations[extendUntilKey]
+	if !found {
+		return deletionDate
+	}
+
+	reject := func(format string, args ...interface{}) time.Time {
+		msg := fmt.Sprintf(format, args...)
+		decision.AddCheck(checkExtension, "rejected: %s", msg)
+		logger.Info("Rejected deletion extension", "extend_until", value, logging.KeyReason, msg)
+		cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonExtensionRejected,
+			fmt.Sprintf("The %s annotation was ignored: %s", extendUntilKey, msg))
+		return deletionDate
+	}
+
+	extendUntil, ok := parseExtendUntil(value)
+	if !ok {
+		return reject("%q is not a date such as 2006-01-02", value)
+	}
+	if !extendUntil.After(deletionDate) {
+		// Already applied, or earlier than the current date
+		decision.AddCheck(checkExtension, "%s is not after the current delete-at", extendUntil.Format(time.RFC3339))
+		return deletionDate
+	}
+	if cfg.MaxExtension <= 0 {
+		return reject("extensions are turned off")
+	}
+
+	original := deletionDate
+	if raw, found := ns.Annotations[originalDeleteAtKey]; found {
+		if parsed, err := time.ParseInLocation(labelTimeLayout, raw, time.UTC); err == nil {
+			original = parsed
+		}
+	}
+	limit := original.Add(time.Duration(cfg.MaxExtension) * 24 * time.Hour)
+	if extendUntil.After(limit) {
+		return reject("%s is past the maximum extension of %d days (%s)",
+			extendUntil.Format(time.RFC3339), cfg.MaxExtension, limit.Format(time.RFC3339))
+	}
+
+	extendedBy := extensionManager(ns)
+	newLabel := extendUntil.Format(labelTimeLayout)
+	if err := cleaner.ExtendDeletion(ctx, ns.Name, newLabel, original.Format(labelTimeLayout), extendedBy); err != nil {
+		logError(logger, stats, "Error extending deletion of %s: %v", ns.Name, err)
+		return deletionDate
+	}
+
+	decision.AddCheck(checkExtension, "extended to %s by %s; %s", extendUntil.Format(time.RFC3339), extendedBy,
+		remaining(extendUntil.Sub(today)))
+	logger.Info("Extended deletion", "delete_at", extendUntil, "extended_by", extendedBy)
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonExtended,
+		fmt.Sprintf("Deletion was pushed back to %s at the request of %s", newLabel, extendedBy))
+	auditExtension(ctx, cleaner, cfg, ns.Name, email, newLabel, extendedBy, stats, logger)
+
+	// An extension past today lifts a quarantine already in place
+	if _, quarantined := ns.Labels[quarantineLabelKey]; quarantined && extendUntil.After(today) {
+		if err := cleaner.RestoreNamespace(ctx, ns.Name); err != nil {
+			logError(logger, stats, "Error restoring quarantined ns %s: %v", ns.Name, err)
+		} else {
+			stats.IncRestored()
+			cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonRestored,
+				"Deletion was extended; the quarantine was reverted")
+		}
+	}
+	return extendUntil
+}
+
+// auditExtension adds an accepted extension to the audit trail
+func auditExtension(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	cfg *config.Config,
+	nsName, email, deleteAt, extendedBy string,
+	stats *stats.Stats,
+	logger *slog.Logger,
+) {
+	err := cleaner.Audit(ctx, audit.Entry{
+		Action:      audit.ActionExtend,
+		Namespace:   nsName,
+		Owner:       email,
+		Reason:      "owner_extension",
+		DeleteAt:    deleteAt,
+		RequestedBy: extendedBy,
+		Evidence:    lookupEvidence(cfg, email, false),
+	})
+	if err != nil {
+		logError(logger, stats, "Error auditing extend of %s: %v", nsName, err)
+	}
+}
+
+// parseExtendUntil accepts a plain date or a full timestamp
+func parseExtendUntil(value string) (time.Time, bool) {
+	for _, layout := range extendUntilLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// extensionManager reads managedFields to find the field manager that last
+// set extend-until. The API server does not keep user names there, so this
+// names the client, such as kubectl-annotate.
+func extensionManager(ns *corev1.Namespace) string {
+	manager := "unknown"
+	var latest time.Time
+	for _, entry := range ns.ManagedFields {
+		if entry.FieldsV1 == nil || !setsAnnotation(entry.FieldsV1.Raw, extendUntilKey) {
+			continue
+		}
+		var at time.Time
+		if entry.Time != nil {
+			at = entry.Time.Time
+		}
+		if manager == "unknown" || !at.Before(latest) {
+			manager, latest = entry.Manager, at
+		}
+	}
+	return manager
+}
+
+// setsAnnotation reports whether a FieldsV1 set owns an annotation
+func setsAnnotation(raw []byte, key string) bool {
+	var fields struct {
+		Metadata struct {
+			Annotations map[string]json.RawMessage `json:"f:annotations"`
+		} `json:"f:metadata"`
+	}
+	if json.Unmarshal(raw, &fields) != nil {
+		return false
+	}
+	_, found := fields.Metadata.Annotations["f:"+key]
+	return found
+}
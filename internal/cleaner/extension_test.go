@@ -0,0 +1,192 @@
+package cleaner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func TestExtendDeletion(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Labels: map[string]string{labelKey: "2023-01-01_00-00-00Z"}},
+	})
+
+	dryRun := NewCleaner(&config.Config{DryRun: true}, client, nil, nil, nil, nil, nil)
+	if err := dryRun.ExtendDeletion(context.TODO(), "test-ns", "2023-02-01_00-00-00Z", "2023-01-01_00-00-00Z", "kubectl-annotate"); err != nil {
+		t.Fatalf("ExtendDeletion failed: %v", err)
+	}
+	ns, _ := client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if ns.Labels[labelKey] != "2023-01-01_00-00-00Z" {
+		t.Error("Dry run should not change the label")
+	}
+
+	cleaner := NewCleaner(&config.Config{}, client, nil, nil, nil, nil, nil)
+	if err := cleaner.ExtendDeletion(context.TODO(), "test-ns", "2023-02-01_00-00-00Z", "2023-01-01_00-00-00Z", "kubectl-annotate"); err != nil {
+		t.Fatalf("ExtendDeletion failed: %v", err)
+	}
+	ns, _ = client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if ns.Labels[labelKey] != "2023-02-01_00-00-00Z" {
+		t.Errorf("Expected the new delete-at label, got %q", ns.Labels[labelKey])
+	}
+	if ns.Annotations[originalDeleteAtKey] != "2023-01-01_00-00-00Z" || ns.Annotations[extendedByKey] != "kubectl-annotate" {
+		t.Errorf("Unexpected annotations %v", ns.Annotations)
+	}
+}
+
+func TestRelabelAfterExtension(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-ns",
+			Labels:      map[string]string{labelKey: "2022-06-01_00-00-00Z"},
+			Annotations: map[string]string{"owner": "user@example.com", extendUntilKey: "2022-07-01"},
+		},
+	})
+	cleaner := NewCleaner(&config.Config{}, client, nil, nil, nil, nil, nil)
+	ctx := context.TODO()
+	if err := cleaner.ExtendDeletion(ctx, "test-ns", "2022-07-01_00-00-00Z", "2022-06-01_00-00-00Z", "kubectl-annotate"); err != nil {
+		t.Fatalf("ExtendDeletion failed: %v", err)
+	}
+	if err := cleaner.RemoveLabel(ctx, "test-ns"); err != nil {
+		t.Fatalf("RemoveLabel failed: %v", err)
+	}
+	ns, _ := client.CoreV1().Namespaces().Get(ctx, "test-ns", metav1.GetOptions{})
+	if _, found := ns.Annotations[originalDeleteAtKey]; found {
+		t.Error("Expected the original delete-at to be cleared with the label")
+	}
+	if _, found := ns.Annotations[extendedByKey]; found {
+		t.Error("Expected extended-by to be cleared with the label")
+	}
+	if _, found := ns.Annotations[extendUntilKey]; found {
+		t.Error("Expected extend-until to be cleared with the label")
+	}
+
+	// Labeled again months later, the owner sets a new extension, which
+	// counts from the new label
+	if err := cleaner.LabelNamespace(ctx, "test-ns", "2023-01-05_00-00-00Z"); err != nil {
+		t.Fatalf("LabelNamespace failed: %v", err)
+	}
+	ns, _ = client.CoreV1().Namespaces().Get(ctx, "test-ns", metav1.GetOptions{})
+	if _, found := ns.Annotations[extendUntilKey]; found {
+		t.Fatal("Expected the new label to start without an extension")
+	}
+	ns.Annotations[extendUntilKey] = "2023-02-01"
+
+	restore := MockUserExists(false)
+	defer restore()
+	mock := &mockCleaner{}
+	s := &stats.Stats{}
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}, MaxExtension: 90}
+	processLabeledNamespace(ctx, mock, nil, ns, cfg, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), s)
+	if got := s.Decisions[0].Decision; got != stats.DecisionPending || len(mock.extended) != 1 {
+		t.Errorf("Expected the extension to be accepted, got %s (%v)", got, mock.events)
+	}
+}
+
+func TestProcessLabeledNamespaceExtension(t *testing.T) {
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	expired := "2023-01-05_00-00-00Z"
+
+	testCases := []struct {
+		name         string
+		extendUntil  string
+		annotations  map[string]string
+		quarantined  bool
+		maxExtension int
+		wantDecision string
+		wantEvent    string
+		wantExtended bool
+	}{
+		{"valid extension", "2023-02-01", nil, false, 90, stats.DecisionPending, ReasonExtended, true},
+		{"timestamp extension", "2023-02-01T12:00:00Z", nil, false, 90, stats.DecisionPending, ReasonExtended, true},
+		{"past the maximum", "2023-06-01", nil, false, 90, stats.DecisionDeleted, ReasonExtensionRejected, false},
+		{"counted from the original date", "2023-02-01",
+			map[string]string{originalDeleteAtKey: "2022-11-01_00-00-00Z"}, false, 90, stats.DecisionDeleted, ReasonExtensionRejected, false},
+		{"invalid date", "next month", nil, false, 90, stats.DecisionDeleted, ReasonExtensionRejected, false},
+		{"extensions turned off", "2023-02-01", nil, false, 0, stats.DecisionDeleted, ReasonExtensionRejected, false},
+		{"not after delete-at", "2023-01-01", nil, false, 90, stats.DecisionDeleted, "", false},
+		{"lifts quarantine", "2023-02-01", nil, true, 90, stats.DecisionPending, ReasonRestored, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			restore := MockUserExists(false)
+			defer restore()
+
+			annotations := map[string]string{"owner": "user@example.com", extendUntilKey: tc.extendUntil}
+			for key, value := range tc.annotations {
+				annotations[key] = value
+			}
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Labels:      map[string]string{labelKey: expired},
+					Annotations: annotations,
+					ManagedFields: []metav1.ManagedFieldsEntry{{
+						Manager:  "kubectl-annotate",
+						FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:` + extendUntilKey + `":{}}}}`)},
+					}},
+				},
+			}
+			if tc.quarantined {
+				ns.Labels[quarantineLabelKey] = "2023-01-20_00-00-00Z"
+			}
+
+			cleaner := &mockCleaner{}
+			s := &stats.Stats{}
+			cfg := &config.Config{AllowedDomains: []string{"example.com"}, MaxExtension: tc.maxExtension}
+			processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+
+			if got := s.Decisions[0].Decision; got != tc.wantDecision {
+				t.Errorf("Expected %s, got %s", tc.wantDecision, got)
+			}
+			if tc.wantEvent != "" && !contains(cleaner.events, tc.wantEvent) {
+				t.Errorf("Expected a %s event, got %v", tc.wantEvent, cleaner.events)
+			}
+			if (len(cleaner.extended) == 1) != tc.wantExtended {
+				t.Errorf("Unexpected extensions %v", cleaner.extended)
+			}
+			if tc.wantExtended {
+				if cleaner.extended[0] != "2023-02-01_00-00-00Z by kubectl-annotate" &&
+					cleaner.extended[0] != "2023-02-01_12-00-00Z by kubectl-annotate" {
+					t.Errorf("Unexpected extension %s", cleaner.extended[0])
+				}
+				entry := cleaner.audited[0]
+				if entry.Action != audit.ActionExtend || entry.RequestedBy != "kubectl-annotate" {
+					t.Errorf("Unexpected audit entry %+v", entry)
+				}
+			}
+			if tc.quarantined && len(cleaner.restored) != 1 {
+				t.Error("Expected the quarantine to be reverted")
+			}
+		})
+	}
+}
+
+func TestExtensionManager(t *testing.T) {
+	earlier := metav1.NewTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	later := metav1.NewTime(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+	fields := func(key string) *metav1.FieldsV1 {
+		return &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:` + key + `":{}}}}`)}
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{
+		{Manager: "kubectl-annotate", Time: &later, FieldsV1: fields(extendUntilKey)},
+		{Manager: "kubectl-edit", Time: &earlier, FieldsV1: fields(extendUntilKey)},
+		{Manager: "kube-controller-manager", Time: &later, FieldsV1: fields("owner")},
+	}}}
+	if got := extensionManager(ns); got != "kubectl-annotate" {
+		t.Errorf("Expected kubectl-annotate, got %s", got)
+	}
+
+	if got := extensionManager(&corev1.Namespace{}); got != "unknown" {
+		t.Errorf("Expected unknown without managedFields, got %s", got)
+	}
+}
@@ -0,0 +1,314 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/internal/owner"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// ownerlessKey marks namespaces labeled for deletion because no owner
+// could be found for them
+const ownerlessKey = "namespace-cleaner/ownerless"
+
+// inferredSources describes the owner sources in checks and reports
+var inferredSources = map[string]string{
+	config.OrphanSourceProfile:       "Profile spec",
+	config.OrphanSourceRoleBinding:   "earliest RoleBinding",
+	config.OrphanSourceManagedFields: "managedFields",
+}
+
+// EarliestRoleBindingUser returns the user of the oldest RoleBinding in a
+// namespace naming one: its Kubeflow user annotation, or else its first
+// User subject. Kubeflow creates the owner's binding with the namespace.
+// It returns an empty string when no RoleBinding names a user.
+func (c *Cleaner) EarliestRoleBindingUser(ctx context.Context, nsName string) (string, error) {
+	bindings, err := c.kubeClient.RbacV1().RoleBindings(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	items := bindings.Items
+	sort.SliceStable(items, func(i, j int) bool {
+		ti, tj := items[i].CreationTimestamp, items[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return items[i].Name < items[j].Name
+	})
+	for _, rb := range items {
+		if user := rb.Annotations[contributorUserKey]; user != "" {
+			return user, nil
+		}
+		for _, subject := range rb.Subjects {
+			if subject.Kind == rbacv1.UserKind && strings.Contains(subject.Name, "@") {
+				return subject.Name, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// MarkOwnerless labels a namespace for deletion because no owner could be
+// found for it
+func (c *Cleaner) MarkOwnerless(ctx context.Context, nsName, graceDate string) error {
+	if c.dryRun {
+		slog.Info("Would label ownerless namespace", logging.KeyNamespace, nsName, logging.KeyAction, "label",
+			"delete_at", graceDate)
+		return nil
+	}
+
+	patch := mustMarshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      map[string]string{labelKey: graceDate},
+			"annotations": map[string]string{ownerlessKey: "true"},
+		},
+	})
+	_, err := c.kubeClient.CoreV1().Namespaces().Patch(
+		ctx, nsName, types.MergePatchType, patch, metav1.PatchOptions{},
+	)
+	return err
+}
+
+// managedFieldsOwner returns the field manager of a namespace's earliest
+// managedFields entry when it is an email address. Managers are usually
+// client names; clients that name them after the user, such as with
+// kubectl --field-manager, record the creator there.
+func managedFieldsOwner(ns *corev1.Namespace) string {
+	var earliest *metav1.ManagedFieldsEntry
+	for i, entry := range ns.ManagedFields {
+		if entry.Time == nil {
+			continue
+		}
+		if earliest == nil || entry.Time.Before(earliest.Time) {
+			earliest = &ns.ManagedFields[i]
+		}
+	}
+	if earliest == nil || strings.Count(earliest.Manager, "@") != 1 {
+		return ""
+	}
+	if address, err := mail.ParseAddress(earliest.Manager); err != nil || address.Address != earliest.Manager {
+		return ""
+	}
+	return earliest.Manager
+}
+
+// resolveOwner finds a namespace's owners and records where they came
+// from. Namespaces without an owner annotation, or a Profile owner in
+// profile deletion mode, have one inferred through cfg.OrphanOwnerSources.
+// The second result reports whether an owner was found. When the owners
+// cannot be parsed or a lookup fails the namespace is skipped for this
+// run, reported by the third result.
+func resolveOwner(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) ([]string, bool, bool) {
+	owners, err := namespaceOwners(ctx, cleaner, ns, cfg)
+	if err != nil {
+		decision.AddCheck(checkOwner, "invalid: %v", err)
+		stats.IncSkippedInvalidOwner()
+		decision.Skip("invalid_owner")
+		cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonSkipped,
+			fmt.Sprintf("Skipped: the owner annotation is invalid: %v", err))
+		return nil, false, false
+	}
+
+	source := ownerSource(ns)
+	if len(owners) == 0 {
+		email, inferred, ok := inferOwner(ctx, cleaner, ns, cfg, stats, decision, logger)
+		if !ok {
+			return nil, false, false
+		}
+		if email == "" {
+			if len(cfg.OrphanOwnerSources) > 0 {
+				decision.AddCheck(checkOwner, "missing: no owner annotation, none found in %s",
+					strings.Join(cfg.OrphanOwnerSources, ", "))
+			} else {
+				decision.AddCheck(checkOwner, "missing: no owner annotation")
+			}
+			return nil, false, true
+		}
+		stats.IncOwnersInferred()
+		owners, source = []string{email}, fmt.Sprintf("%s, inferred", inferredSources[inferred])
+	}
+
+	decision.AddCheck(checkOwner, "%s (from %s)", strings.Join(owners, ", "), source)
+	decision.Owner = owners[0]
+	decision.OwnerSource = source
+	return owners, true, true
+}
+
+// inferOwner looks for the owner of a namespace through
+// cfg.OrphanOwnerSources, in order, and returns the first found along with
+// its source. When a lookup fails the namespace is skipped for this run,
+// reported by the third result.
+func inferOwner(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) (string, string, bool) {
+	for _, source := range cfg.OrphanOwnerSources {
+		var email string
+		var err error
+		switch source {
+		case config.OrphanSourceProfile:
+			email, err = cleaner.ProfileOwner(ctx, ns.Name)
+		case config.OrphanSourceRoleBinding:
+			email, err = cleaner.EarliestRoleBindingUser(ctx, ns.Name)
+		case config.OrphanSourceManagedFields:
+			email = managedFieldsOwner(ns)
+		}
+		if err != nil {
+			decision.AddCheck(checkOwner, "missing: reading the %s failed: %v", inferredSources[source], err)
+			stats.IncSkippedLookupFailed()
+			decision.Fail("lookup_failed", logError(logger, stats, "Error inferring owner of %s from the %s: %v",
+				ns.Name, inferredSources[source], err))
+			return "", "", false
+		}
+		if email == "" {
+			continue
+		}
+		if email, err = owner.Normalize(email); err != nil {
+			logger.Debug("Ignoring inferred owner", "source", source, logging.KeyError, err)
+			continue
+		}
+		email = owner.MapAlias(email, cfg.OwnerDomainAliases)
+		logger.Debug("Inferred owner", logging.KeyOwner, email, "source", source)
+		return email, source, true
+	}
+	return "", "", true
+}
+
+// skipOwnerless leaves a namespace without an owner alone, as is done
+// when no ownerless grace period is configured
+func skipOwnerless(cleaner NamespaceCleaner, ns *corev1.Namespace, stats *stats.Stats, decision *stats.Decision) {
+	stats.IncSkippedMissingOwner()
+	decision.Skip("missing_owner")
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonSkipped, "Skipped: the namespace has no owner annotation")
+}
+
+// labelOwnerless labels a namespace no owner could be found for, to be
+// deleted after cfg.OrphanGracePeriod days, and tells the admins
+func labelOwnerless(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	today time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) {
+	graceDate := today.Add(time.Duration(cfg.OrphanGracePeriod) * 24 * time.Hour).Format(labelTimeLayout)
+	if err := cleaner.MarkOwnerless(ctx, ns.Name, graceDate); err != nil {
+		decision.Fail("ownerless", logError(logger, stats, "Error labeling ownerless ns %s: %v", ns.Name, err))
+		return
+	}
+	stats.AddLabeled(ns.Name)
+	stats.IncLabeledOwnerless()
+	auditAction(ctx, cleaner, cfg, audit.ActionLabel, "ownerless", ns.Name, "", graceDate,
+		ownerlessEvidence(cfg), stats, logger)
+	deleteAt, _ := time.ParseInLocation(labelTimeLayout, graceDate, time.UTC)
+	stats.AddPending(ns.Name, deleteAt)
+	decision.Labeled("ownerless", deleteAt)
+	cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonLabeled,
+		fmt.Sprintf("No owner was found for the namespace; it will be deleted after %s unless an owner annotation is added",
+			graceDate))
+
+	notifyOwner(ctx, cleaner, graph, ns, cfg, notifier.Notice{
+		Event:     notifier.EventOwnerless,
+		Namespace: ns.Name,
+		DeleteAt:  deleteAt,
+		DaysLeft:  cfg.OrphanGracePeriod,
+	})
+}
+
+// expireOwnerless deletes a namespace labeled by labelOwnerless once its
+// grace period is over, unless an owner turned up in the meantime or its
+// policy rule holds the deletion
+func expireOwnerless(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	rule *policy.Rule,
+	today time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) {
+	labelValue := ns.Labels[labelKey]
+	deletionDate, err := time.ParseInLocation(labelTimeLayout, labelValue, time.UTC)
+	if err != nil {
+		decision.AddCheck(checkDeleteAt, "invalid: %q is not a date", labelValue)
+		logger.Warn("Invalid delete-at label", "label", labelValue)
+		stats.IncInvalidLabel()
+		decision.Skip("invalid_label")
+		cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonInvalidLabel,
+			fmt.Sprintf("Skipped: the delete-at label %q is not a valid date", labelValue))
+		return
+	}
+	decision.PreviousDeleteAt = &deletionDate
+	decision.AddCheck(checkDeleteAt, "%s", deletionDate.Format(time.RFC3339))
+	decision.AddCheck(checkRemaining, "%s", remaining(deletionDate.Sub(today)))
+	if !today.After(deletionDate) {
+		stats.AddPending(ns.Name, deletionDate)
+		decision.Pending("ownerless", deletionDate)
+		return
+	}
+
+	if cfg.QuarantineEnabled && !quarantineExpired(ctx, cleaner, ns, cfg, today, stats, decision, logger) {
+		return
+	}
+	if deletionHeld(cleaner, ns, rule, stats, decision) {
+		return
+	}
+	if err := cleaner.DeleteNamespace(ctx, ns.Name, today); err != nil {
+		deleteFailed(cleaner, ns, err, "ownerless", deletionDate, stats, decision, logger)
+		return
+	}
+	stats.AddDeleted(ns.Name)
+	decision.Deleted("ownerless")
+	auditAction(ctx, cleaner, cfg, audit.ActionDelete, "ownerless", ns.Name, "", labelValue,
+		ownerlessEvidence(cfg), stats, logger)
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonDeleted,
+		"No owner was found for the namespace during the grace period; the namespace was deleted")
+}
+
+// ownerlessEvidence describes the owner search behind an audited action on
+// an ownerless namespace
+func ownerlessEvidence(cfg *config.Config) audit.Evidence {
+	return audit.Evidence{
+		Source:    "owner sources",
+		Lookup:    strings.Join(append([]string{"owner annotation"}, cfg.OrphanOwnerSources...), ","),
+		Found:     false,
+		CheckedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,261 @@
+package cleaner
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func TestEarliestRoleBindingUser(t *testing.T) {
+	at := func(month time.Month) metav1.Time {
+		return metav1.NewTime(time.Date(2022, month, 1, 0, 0, 0, 0, time.UTC))
+	}
+	binding := func(name string, created metav1.Time, annotations map[string]string, subjects ...rbacv1.Subject) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", CreationTimestamp: created, Annotations: annotations},
+			Subjects:   subjects,
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		binding("contributor", at(6), map[string]string{contributorUserKey: "contributor@example.com"}),
+		binding("default-editor", at(1), nil, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "default-editor"}),
+		binding("namespaceAdmin", at(2), nil,
+			rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "admins@example.com"},
+			rbacv1.Subject{Kind: rbacv1.UserKind, Name: "owner@example.com"}),
+	)
+	cleaner := NewCleaner(&config.Config{}, client, nil, nil, nil, nil, nil)
+
+	user, err := cleaner.EarliestRoleBindingUser(context.TODO(), "test-ns")
+	if err != nil {
+		t.Fatalf("EarliestRoleBindingUser failed: %v", err)
+	}
+	if user != "owner@example.com" {
+		t.Errorf("Expected the user of the oldest binding naming one, got %q", user)
+	}
+
+	if user, err := cleaner.EarliestRoleBindingUser(context.TODO(), "other-ns"); err != nil || user != "" {
+		t.Errorf("Expected no user without bindings, got %q, %v", user, err)
+	}
+}
+
+func TestMarkOwnerless(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}})
+	cleaner := NewCleaner(&config.Config{}, client, nil, nil, nil, nil, nil)
+
+	if err := cleaner.MarkOwnerless(context.TODO(), "test-ns", "2023-04-10_00-00-00Z"); err != nil {
+		t.Fatalf("MarkOwnerless failed: %v", err)
+	}
+	ns, _ := client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if ns.Labels[labelKey] != "2023-04-10_00-00-00Z" || ns.Annotations[ownerlessKey] != "true" {
+		t.Errorf("Unexpected metadata %v %v", ns.Labels, ns.Annotations)
+	}
+
+	if err := cleaner.RemoveLabel(context.TODO(), "test-ns"); err != nil {
+		t.Fatalf("RemoveLabel failed: %v", err)
+	}
+	ns, _ = client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if _, found := ns.Annotations[ownerlessKey]; found {
+		t.Error("Expected RemoveLabel to drop the ownerless annotation")
+	}
+}
+
+func TestManagedFieldsOwner(t *testing.T) {
+	entry := func(manager string, month time.Month) metav1.ManagedFieldsEntry {
+		at := metav1.NewTime(time.Date(2022, month, 1, 0, 0, 0, 0, time.UTC))
+		return metav1.ManagedFieldsEntry{Manager: manager, Operation: metav1.ManagedFieldsOperationUpdate, Time: &at}
+	}
+
+	testCases := []struct {
+		name    string
+		entries []metav1.ManagedFieldsEntry
+		want    string
+	}{
+		{"creator named manager", []metav1.ManagedFieldsEntry{entry("kfam", 3), entry("user@example.com", 1)}, "user@example.com"},
+		{"client manager", []metav1.ManagedFieldsEntry{entry("kubectl-create", 1), entry("user@example.com", 3)}, ""},
+		{"malformed manager", []metav1.ManagedFieldsEntry{entry("a@b@example.com", 1)}, ""},
+		{"no entries", nil, ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", ManagedFields: tc.entries}}
+			if got := managedFieldsOwner(ns); got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestOwnerlessPolicy(t *testing.T) {
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		sources      []string
+		gracePeriod  int
+		profileOwner string
+		bindingUser  string
+		labeled      string
+		ownerless    bool
+		wantDecision string
+		wantReason   string
+		wantSource   string
+	}{
+		{"left alone by default", nil, 0, "", "", "", false, stats.DecisionSkipped, "missing_owner", ""},
+		{"owner from profile", []string{"profile", "rolebinding"}, 0, "Profile@Example.com", "binding@example.com", "", false,
+			stats.DecisionLabeled, "owner_not_found", "Profile spec, inferred"},
+		{"owner from rolebinding", []string{"profile", "rolebinding"}, 0, "", "binding@example.com", "", false,
+			stats.DecisionLabeled, "owner_not_found", "earliest RoleBinding, inferred"},
+		{"nothing found is skipped", []string{"profile"}, 0, "", "", "", false, stats.DecisionSkipped, "missing_owner", ""},
+		{"nothing found is labeled", []string{"profile"}, 90, "", "", "", false, stats.DecisionLabeled, "ownerless", ""},
+		{"ownerless pending", nil, 90, "", "", "2023-04-10_00-00-00Z", true, stats.DecisionPending, "ownerless", ""},
+		{"ownerless expired", nil, 90, "", "", "2023-01-05_00-00-00Z", true, stats.DecisionDeleted, "ownerless", ""},
+		{"ownerless grace period turned off", nil, 0, "", "", "2023-01-05_00-00-00Z", true, stats.DecisionSkipped, "missing_owner", ""},
+		{"labeled without the ownerless mark", nil, 90, "", "", "2023-01-05_00-00-00Z", false, stats.DecisionSkipped, "missing_owner", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			restore := MockUserExists(false)
+			defer restore()
+
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Labels: map[string]string{}, Annotations: map[string]string{}}}
+			if tc.ownerless {
+				ns.Annotations[ownerlessKey] = "true"
+			}
+			cleaner := &mockCleaner{
+				owners:       map[string]string{"test-ns": tc.profileOwner},
+				bindingUsers: map[string]string{"test-ns": tc.bindingUser},
+			}
+			s := &stats.Stats{}
+			cfg := &config.Config{
+				AllowedDomains:     []string{"example.com"},
+				OrphanOwnerSources: tc.sources,
+				OrphanGracePeriod:  tc.gracePeriod,
+			}
+			if tc.labeled != "" {
+				ns.Labels[labelKey] = tc.labeled
+				processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+			} else {
+				processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-02-10_00-00-00Z", today, s)
+			}
+
+			decision := s.Decisions[0]
+			if decision.Decision != tc.wantDecision || decision.Reason != tc.wantReason {
+				t.Errorf("Expected %s (%s), got %s (%s)", tc.wantDecision, tc.wantReason, decision.Decision, decision.Reason)
+			}
+			if decision.OwnerSource != tc.wantSource {
+				t.Errorf("Expected owner source %q, got %q", tc.wantSource, decision.OwnerSource)
+			}
+			if tc.wantSource != "" && (s.OwnersInferred != 1 || !strings.HasSuffix(decision.Owner, "@example.com")) {
+				t.Errorf("Expected a normalized inferred owner, got %q (%d inferred)", decision.Owner, s.OwnersInferred)
+			}
+			if tc.wantReason == "missing_owner" && s.SkippedMissingOwner != 1 {
+				t.Error("Expected a missing owner skip")
+			}
+		})
+	}
+}
+
+func TestOwnerlessPolicyRules(t *testing.T) {
+	rules, err := policy.Parse([]byte(`
+rules:
+  - name: system
+    match: {name: "^kube-"}
+    action: ignore
+  - name: sandbox
+    match: {labels: {tier: sandbox}}
+    action: label
+  - name: contractors
+    match: {ownerDomains: [contractors.example.com]}
+    action: ignore
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		nsName       string
+		tier         string
+		labeled      bool
+		wantDecision string
+		wantReason   string
+	}{
+		{"ignored before labeling", "kube-team", "", false, stats.DecisionSkipped, "policy_ignored"},
+		{"ignored once labeled", "kube-team", "", true, stats.DecisionSkipped, "policy_ignored"},
+		{"label-only rule labels", "team-a", "sandbox", false, stats.DecisionLabeled, "ownerless"},
+		{"label-only rule never deletes", "team-a", "sandbox", true, stats.DecisionSkipped, "label_only"},
+		{"owner rules do not match", "team-a", "", true, stats.DecisionDeleted, "ownerless"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        tc.nsName,
+				Labels:      map[string]string{"tier": tc.tier},
+				Annotations: map[string]string{},
+			}}
+			cleaner := &mockCleaner{rules: rules}
+			s := &stats.Stats{}
+			cfg := &config.Config{AllowedDomains: []string{"example.com"}, OrphanGracePeriod: 90}
+
+			if tc.labeled {
+				ns.Labels[labelKey] = "2023-01-05_00-00-00Z"
+				ns.Annotations[ownerlessKey] = "true"
+				processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+			} else {
+				processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-02-10_00-00-00Z", today, s)
+			}
+
+			decision := s.Decisions[0]
+			if decision.Decision != tc.wantDecision || decision.Reason != tc.wantReason {
+				t.Errorf("Expected %s (%s), got %s (%s)", tc.wantDecision, tc.wantReason, decision.Decision, decision.Reason)
+			}
+			if tc.wantDecision != stats.DecisionDeleted && len(cleaner.deleted) != 0 {
+				t.Errorf("Expected no deletion, got %v", cleaner.deleted)
+			}
+			if tc.wantReason == "policy_ignored" && len(cleaner.ownerless) != 0 {
+				t.Errorf("Expected no ownerless label, got %v", cleaner.ownerless)
+			}
+		})
+	}
+}
+
+func TestLabelOwnerless(t *testing.T) {
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}}
+	cleaner := &mockCleaner{}
+	s := &stats.Stats{}
+	cfg := &config.Config{OrphanOwnerSources: []string{"rolebinding"}, OrphanGracePeriod: 90}
+
+	processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-02-10_00-00-00Z", today, s)
+
+	if len(cleaner.ownerless) != 1 || cleaner.ownerless[0] != "test-ns until 2023-04-10_00-00-00Z" {
+		t.Errorf("Expected the ownerless grace period, got %v", cleaner.ownerless)
+	}
+	if s.LabeledOwnerless != 1 || s.Labeled != 1 || !s.PendingDeletion["test-ns"].Equal(today.AddDate(0, 0, 90)) {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	if len(cleaner.notices) != 1 || cleaner.notices[0].Event != notifier.EventOwnerless || cleaner.notices[0].DaysLeft != 90 {
+		t.Errorf("Expected an ownerless notice for the admins, got %+v", cleaner.notices)
+	}
+	if len(cleaner.audited) != 1 || cleaner.audited[0].Reason != "ownerless" || cleaner.audited[0].Evidence.Lookup != "owner annotation,rolebinding" {
+		t.Errorf("Expected the sources tried in the audit trail, got %+v", cleaner.audited)
+	}
+	if check := s.Decisions[0].Checks[0]; !strings.Contains(check.Result, "none found in rolebinding") {
+		t.Errorf("Expected the sources tried in the owner check, got %q", check.Result)
+	}
+}
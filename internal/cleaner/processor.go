@@ -2,19 +2,54 @@ package cleaner
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
 	"time"
 
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
+	"github.com/StatCan/namespace-cleaner/internal/admission"
+	"github.com/StatCan/namespace-cleaner/internal/audit"
 	"github.com/StatCan/namespace-cleaner/internal/clients"
 	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/internal/owner"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/internal/tracing"
 	"github.com/StatCan/namespace-cleaner/pkg/stats"
 )
 
+// Phases reported in log lines
+const (
+	phaseUnlabeled = "unlabeled"
+	phaseLabeled   = "labeled"
+)
+
+// Selectors of the namespaces each phase evaluates
+const (
+	unlabeledSelector = "app.kubeflow.org/part-of=kubeflow-profile,!" + labelKey
+	labeledSelector   = labelKey
+)
+
+// Steps of a namespace's evaluation, as listed by explain
+const (
+	checkOwner     = "owner"
+	checkDomain    = "domain rules"
+	checkLookup    = "identity lookup"
+	checkDeleteAt  = "delete-at"
+	checkRemaining = "time remaining"
+)
+
 // ProcessNamespaces executes namespace cleaning workflow
 func ProcessNamespaces(
 	ctx context.Context,
@@ -24,12 +59,15 @@ func ProcessNamespaces(
 	cfg *config.Config,
 	referenceTime time.Time,
 ) *stats.Stats {
+	ctx, span := tracing.Start(ctx, "ProcessNamespaces", attribute.Bool("dry_run", cfg.DryRun))
+	defer span.End()
+
 	stats := &stats.Stats{}
 
-	graceDate := referenceTime.Add(time.Duration(cfg.GracePeriod) * 24 * time.Hour).Format(labelTimeLayout)
+	graceDate := gracePeriodEnd(cfg, referenceTime)
 
 	// Phase 1: Process unlabeled namespaces
-	processPhase1(ctx, cleaner, graph, kube, cfg, graceDate, stats)
+	processPhase1(ctx, cleaner, graph, kube, cfg, graceDate, referenceTime, stats)
 
 	// Phase 2: Process labeled namespaces
 	processPhase2(ctx, cleaner, graph, kube, cfg, referenceTime, stats)
@@ -37,6 +75,11 @@ func ProcessNamespaces(
 	return stats
 }
 
+// gracePeriodEnd is the delete-at label value for namespaces labeled now
+func gracePeriodEnd(cfg *config.Config, referenceTime time.Time) string {
+	return referenceTime.Add(time.Duration(cfg.GracePeriod) * 24 * time.Hour).Format(labelTimeLayout)
+}
+
 func processPhase1(
 	ctx context.Context,
 	cleaner NamespaceCleaner,
@@ -44,19 +87,26 @@ func processPhase1(
 	kube kubernetes.Interface,
 	cfg *config.Config,
 	graceDate string,
+	referenceTime time.Time,
 	stats *stats.Stats,
 ) {
+	ctx, span := tracing.Start(ctx, "phase."+phaseUnlabeled)
+	defer span.End()
+
 	nsList, err := kube.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
-		LabelSelector: "app.kubeflow.org/part-of=kubeflow-profile,!" + labelKey,
+		LabelSelector: unlabeledSelector,
 	})
 	if err != nil {
-		log.Printf("Error listing namespaces: %v", err)
+		logError(slog.With(logging.KeyPhase, phaseUnlabeled), stats, "Error listing namespaces: %v", err)
+		tracing.End(span, err)
 		return
 	}
+	span.SetAttributes(attribute.Int("namespaces", len(nsList.Items)))
+	slog.Debug("Listed unlabeled namespaces", logging.KeyPhase, phaseUnlabeled, "count", len(nsList.Items))
 
 	for _, ns := range nsList.Items {
 		stats.IncTotal()
-		processUnlabeledNamespace(ctx, cleaner, graph, &ns, cfg, graceDate, stats)
+		processUnlabeledNamespace(ctx, cleaner, graph, &ns, cfg, graceDate, referenceTime, stats)
 	}
 }
 
@@ -69,15 +119,30 @@ func processPhase2(
 	referenceTime time.Time,
 	stats *stats.Stats,
 ) {
+	ctx, span := tracing.Start(ctx, "phase."+phaseLabeled)
+	defer span.End()
+
 	labeledNs, err := kube.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
-		LabelSelector: labelKey,
+		LabelSelector: labeledSelector,
 	})
 	if err != nil {
-		log.Printf("Error listing labeled namespaces: %v", err)
+		logError(slog.With(logging.KeyPhase, phaseLabeled), stats, "Error listing labeled namespaces: %v", err)
+		tracing.End(span, err)
 		return
 	}
+	span.SetAttributes(attribute.Int("namespaces", len(labeledNs.Items)))
+	slog.Debug("Listed labeled namespaces", logging.KeyPhase, phaseLabeled, "count", len(labeledNs.Items))
 
+	// Namespaces labeled in phase 1 are left to later runs, so their owners
+	// are notified before anything else happens to them
+	labeledThisRun := map[string]bool{}
+	for _, name := range stats.LabeledNamespaces {
+		labeledThisRun[name] = true
+	}
 	for _, ns := range labeledNs.Items {
+		if labeledThisRun[ns.Name] {
+			continue
+		}
 		stats.IncTotal()
 		processLabeledNamespace(ctx, cleaner, graph, &ns, cfg, referenceTime, stats)
 	}
@@ -90,32 +155,114 @@ func processUnlabeledNamespace(
 	ns *corev1.Namespace,
 	cfg *config.Config,
 	graceDate string,
+	today time.Time,
 	stats *stats.Stats,
 ) {
-	email, found := ns.Annotations["owner"]
+	ctx, span := tracing.Start(ctx, "evaluateNamespace",
+		attribute.String(logging.KeyNamespace, ns.Name), attribute.String(logging.KeyPhase, phaseUnlabeled))
+	decision := stats.NewDecision(ns.Name)
+	logger := slog.With(logging.KeyNamespace, ns.Name, logging.KeyPhase, phaseUnlabeled)
+	defer func() {
+		logDecision(logger, decision)
+		endDecisionSpan(span, decision)
+	}()
+
+	owners, found, ok := resolveOwner(ctx, cleaner, ns, cfg, stats, decision, logger)
+	if !ok {
+		return
+	}
 	if !found {
-		stats.IncSkippedMissingOwner()
+		// Rules matching on name and labels still apply without an owner
+		rule, cfg := applyRule(cleaner, ns, cfg, "", decision, logger)
+		if ignoredByRule(cleaner, ns, rule, stats, decision) {
+			return
+		}
+		if cfg.OrphanGracePeriod > 0 {
+			labelOwnerless(ctx, cleaner, graph, ns, cfg, today, stats, decision, logger)
+			return
+		}
+		skipOwnerless(cleaner, ns, stats, decision)
 		return
 	}
+	email := owners[0]
+	logger = logger.With(logging.KeyOwner, email)
+	logger.Debug("Found owner")
 
-	if !clients.ValidDomain(email, cfg.AllowedDomains) {
-		stats.IncSkippedInvalidDomain()
+	rule, cfg := applyRule(cleaner, ns, cfg, email, decision, logger)
+	if ignoredByRule(cleaner, ns, rule, stats, decision) {
 		return
 	}
+	if rule != nil && rule.GracePeriod != nil {
+		graceDate = gracePeriodEnd(cfg, today)
+	}
 
-	if clients.UserExists(ctx, cfg, graph, email) {
+	email, exists, ok := lookUpOwners(ctx, cleaner, graph, ns, cfg, owners, stats, decision, logger)
+	if !ok {
+		return
+	}
+
+	// An expression that holds decides instead of the owner lookup
+	expression, ok := evaluateExpressions(ctx, cleaner, graph, ns, cfg, email, exists, today, stats, decision, logger)
+	if !ok {
+		return
+	}
+	reason, why := "owner_not_found", fmt.Sprintf("Owner %s was not found in Entra ID", email)
+	switch {
+	case expression != nil && expression.Decision == policy.DecisionSkip:
+		skipByExpression(cleaner, ns, expression, stats, decision)
+		return
+	case expression != nil:
+		// A namespace an expression deletes is labeled due at once, so
+		// its owner is told before the next run deletes it
+		reason, why = "expression", fmt.Sprintf("Expression %q holds", expression.Name)
+		if expression.Decision == policy.DecisionDelete {
+			graceDate = today.Format(labelTimeLayout)
+		}
+	case exists:
+		if cfg.IdleDays > 0 {
+			labelIdle(ctx, cleaner, graph, ns, cfg, email, graceDate, today, stats, decision, logger)
+			return
+		}
 		stats.IncSkippedExistingUser()
+		decision.Skip("owner_exists")
 		return
+	case cfg.ContributorsAsOwners:
+		contributor, ok := contributorExists(ctx, cleaner, graph, ns, cfg, email, stats, decision, logger)
+		if !ok {
+			return
+		}
+		if contributor != "" {
+			stats.IncSkippedExistingUser()
+			decision.Skip("contributor_exists")
+			return
+		}
 	}
 
 	if err := cleaner.LabelNamespace(ctx, ns.Name, graceDate); err != nil {
-		log.Printf("Error labeling %s: %v", ns.Name, err)
-	} else {
-		stats.IncLabeled()
+		decision.Fail(reason, logError(logger, stats, "Error labeling %s: %v", ns.Name, err))
+		return
 	}
-	if err := cleaner.RemoveLabel(ctx, ns.Name); err != nil {
-		log.Printf("Error removing label from %s: %v", ns.Name, err)
+	stats.AddLabeled(ns.Name)
+	auditAction(ctx, cleaner, cfg, audit.ActionLabel, reason, ns.Name, email, graceDate,
+		lookupEvidence(cfg, email, exists), stats, logger)
+	deleteAt, _ := time.ParseInLocation(labelTimeLayout, graceDate, time.UTC)
+	stats.AddPending(ns.Name, deleteAt)
+	decision.Labeled(reason, deleteAt)
+	cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonLabeled,
+		fmt.Sprintf("%s; the namespace will be deleted after %s", why, graceDate))
+
+	notice := notifier.Notice{
+		Event:     notifier.EventLabeled,
+		Namespace: ns.Name,
+		Owner:     email,
+		DeleteAt:  deleteAt,
+	}
+	if expression != nil {
+		notice.Event = notifier.EventExpression
+		notice.Expression = expression.Name
+		notice.DaysLeft = int(math.Ceil(deleteAt.Sub(today).Hours() / 24))
 	}
+	notifyOwner(ctx, cleaner, graph, ns, cfg, notice)
 }
 
 func processLabeledNamespace(
@@ -127,39 +274,531 @@ func processLabeledNamespace(
 	today time.Time,
 	stats *stats.Stats,
 ) {
-	email, found := ns.Annotations["owner"]
+	ctx, span := tracing.Start(ctx, "evaluateNamespace",
+		attribute.String(logging.KeyNamespace, ns.Name), attribute.String(logging.KeyPhase, phaseLabeled))
+	decision := stats.NewDecision(ns.Name)
+	logger := slog.With(logging.KeyNamespace, ns.Name, logging.KeyPhase, phaseLabeled)
+	defer func() {
+		logDecision(logger, decision)
+		endDecisionSpan(span, decision)
+	}()
+
+	owners, found, ok := resolveOwner(ctx, cleaner, ns, cfg, stats, decision, logger)
+	if !ok {
+		return
+	}
 	if !found {
-		stats.IncSkippedMissingOwner()
+		// Namespaces labeled before the ownerless grace period was turned
+		// off are left alone, like any other without an owner
+		rule, cfg := applyRule(cleaner, ns, cfg, "", decision, logger)
+		if ignoredByRule(cleaner, ns, rule, stats, decision) {
+			return
+		}
+		if _, ownerless := ns.Annotations[ownerlessKey]; ownerless && cfg.OrphanGracePeriod > 0 {
+			expireOwnerless(ctx, cleaner, ns, cfg, rule, today, stats, decision, logger)
+			return
+		}
+		skipOwnerless(cleaner, ns, stats, decision)
 		return
 	}
+	email := owners[0]
+	logger = logger.With(logging.KeyOwner, email)
+	logger.Debug("Found owner")
 
 	labelValue := ns.Labels[labelKey]
 	deletionDate, err := time.ParseInLocation(labelTimeLayout, labelValue, time.UTC)
 	if err != nil {
-		log.Printf("Invalid delete-at label in %s: %q", ns.Name, labelValue)
+		decision.AddCheck(checkDeleteAt, "invalid: %q is not a date", labelValue)
+		logger.Warn("Invalid delete-at label", "label", labelValue)
 		stats.IncInvalidLabel()
+		decision.Skip("invalid_label")
+		cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonInvalidLabel,
+			fmt.Sprintf("Skipped: the delete-at label %q is not a valid date", labelValue))
 		return
 	}
+	decision.PreviousDeleteAt = &deletionDate
+	decision.AddCheck(checkDeleteAt, "%s", deletionDate.Format(time.RFC3339))
+	decision.AddCheck(checkRemaining, "%s", remaining(deletionDate.Sub(today)))
+	logger.Debug("Read delete-at label", "delete_at", deletionDate, "expired", today.After(deletionDate))
 
-	if !clients.ValidDomain(email, cfg.AllowedDomains) {
-		stats.IncSkippedInvalidDomain()
+	rule, cfg := applyRule(cleaner, ns, cfg, email, decision, logger)
+	if ignoredByRule(cleaner, ns, rule, stats, decision) {
 		return
 	}
 
-	if clients.UserExists(ctx, cfg, graph, email) {
+	email, exists, ok := lookUpOwners(ctx, cleaner, graph, ns, cfg, owners, stats, decision, logger)
+	if !ok {
+		return
+	}
+
+	// An expression that holds decides instead of the owner lookup; one
+	// deciding to label keeps the namespace on its way to deletion, and
+	// one deciding to delete skips what is left of the grace period
+	expression, ok := evaluateExpressions(ctx, cleaner, graph, ns, cfg, email, exists, today, stats, decision, logger)
+	if !ok {
+		return
+	}
+	if expression != nil && expression.Decision == policy.DecisionSkip {
+		skipByExpression(cleaner, ns, expression, stats, decision)
+		return
+	}
+	deleteNow := expression != nil && expression.Decision == policy.DecisionDelete
+
+	// A namespace labeled for inactivity is kept once it is used again. Any
+	// other is kept by its owner or, as co-owner, an active contributor.
+	reason, keptBy, lookedUp := "owner_found", fmt.Sprintf("Owner %s was found again", email), email
+	keep := exists && expression == nil
+	_, idle := ns.Annotations[idleSinceKey]
+	idle = idle && keep && cfg.IdleDays > 0
+	_, quarantined := ns.Labels[quarantineLabelKey]
+	if idle && quarantined {
+		// Stopping the workloads raises events and restarts of its own,
+		// which are not use; an extension lifts the quarantine instead
+		decision.AddCheck(checkActivity, "not read while quarantined")
+		keep = false
+	} else if idle {
+		activity, ok := evaluateActivity(ctx, cleaner, ns, today, stats, decision, logger)
+		if !ok {
+			return
+		}
+		keep = activity.idleDays(today) <= cfg.IdleDays
+		reason, keptBy = "used_again", fmt.Sprintf("The namespace was used again (%s)", activity.Source)
+	} else if !exists && expression == nil && cfg.ContributorsAsOwners {
+		contributor, ok := contributorExists(ctx, cleaner, graph, ns, cfg, email, stats, decision, logger)
+		if !ok {
+			return
+		}
+		if contributor != "" {
+			keep, lookedUp = true, contributor
+			reason, keptBy = "contributor_found", fmt.Sprintf("Contributor %s is still active", contributor)
+		}
+	}
+	if keep {
+		if quarantined {
+			if err := cleaner.RestoreNamespace(ctx, ns.Name); err != nil {
+				decision.Fail(reason, logError(logger, stats, "Error restoring quarantined ns %s: %v", ns.Name, err))
+				return
+			}
+			stats.IncRestored()
+			cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonRestored, keptBy+"; the quarantine was reverted")
+		}
 		if err := cleaner.RemoveLabel(ctx, ns.Name); err != nil {
-			log.Printf("Error removing label: %v", err)
+			decision.Fail(reason, logError(logger, stats, "Error removing label from %s: %v", ns.Name, err))
 		} else {
-			stats.IncLabelRemoved()
+			stats.AddLabelRemoved(ns.Name)
+			decision.LabelRemoved(reason)
+			auditAction(ctx, cleaner, cfg, audit.ActionUnlabel, reason, ns.Name, email, labelValue,
+				lookupEvidence(cfg, lookedUp, true), stats, logger)
+			cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonLabelRemoved, keptBy+"; the delete-at label was removed")
+		}
+		return
+	}
+
+	if !deleteNow {
+		deletionDate = applyExtension(ctx, cleaner, ns, cfg, email, deletionDate, today, stats, decision, logger)
+		if !today.After(deletionDate) {
+			stats.AddPending(ns.Name, deletionDate)
+			decision.Pending("grace_period_running", deletionDate)
+			sendReminder(ctx, cleaner, graph, ns, cfg, email, deletionDate, today)
+			return
+		}
+		if !exists && cfg.ActiveDays > 0 && !quarantined && recentlyUsed(ctx, cleaner, ns, cfg, today, stats, decision, logger) {
+			return
+		}
+	}
+	if !exists && cfg.TransferEnabled && transferOwnership(ctx, cleaner, graph, ns, cfg, email, deletionDate, stats, decision, logger) {
+		return
+	}
+	if cfg.QuarantineEnabled && !quarantineExpired(ctx, cleaner, ns, cfg, today, stats, decision, logger) {
+		return
+	}
+	if deletionHeld(cleaner, ns, rule, stats, decision) {
+		return
+	}
+	reason = "grace_period_expired"
+	if deleteNow {
+		reason = "expression"
+	}
+	if err := cleaner.DeleteNamespace(ctx, ns.Name, today); err != nil {
+		deleteFailed(cleaner, ns, err, reason, deletionDate, stats, decision, logger)
+		return
+	}
+	stats.AddDeleted(ns.Name)
+	decision.Deleted(reason)
+	auditAction(ctx, cleaner, cfg, audit.ActionDelete, reason, ns.Name, email, labelValue,
+		lookupEvidence(cfg, email, exists), stats, logger)
+	why := fmt.Sprintf("Owner %s was not found in Entra ID after the grace period", email)
+	switch {
+	case deleteNow:
+		why = fmt.Sprintf("Expression %q holds", expression.Name)
+	case idle:
+		why = "The namespace was not used during the grace period"
+	case expression != nil:
+		why = fmt.Sprintf("Expression %q held after the grace period", expression.Name)
+	}
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonDeleted, why+"; the namespace was deleted")
+
+	notifyOwner(ctx, cleaner, graph, ns, cfg, notifier.Notice{
+		Event:     notifier.EventDeleted,
+		Namespace: ns.Name,
+		Owner:     email,
+		DeleteAt:  deletionDate,
+	})
+}
+
+// sendReminder notifies the owner once the time left before deletion drops
+// below a configured reminder threshold. Only the closest threshold is used,
+// so an owner labeled late does not receive several reminders at once.
+func sendReminder(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email string,
+	deletionDate time.Time,
+	today time.Time,
+) {
+	daysLeft := int(math.Ceil(deletionDate.Sub(today).Hours() / 24))
+
+	reminder := -1
+	for _, days := range cfg.NotifyReminderDays {
+		if days >= daysLeft && (reminder == -1 || days < reminder) {
+			reminder = days
 		}
+	}
+	if reminder == -1 {
 		return
 	}
 
-	if today.After(deletionDate) {
-		if err := cleaner.DeleteNamespace(ctx, ns.Name, cfg.TestMode); err != nil {
-			log.Printf("Error deleting ns %s: %v", ns.Name, err)
+	notifyOwner(ctx, cleaner, graph, ns, cfg, notifier.Notice{
+		Event:     notifier.EventReminder,
+		Namespace: ns.Name,
+		Owner:     email,
+		DeleteAt:  deletionDate,
+		DaysLeft:  daysLeft,
+		Reminder:  reminder,
+	})
+}
+
+// notifyOwner sends a notice, looking up the owner's manager first when
+// managers are copied on that event
+func notifyOwner(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	notice notifier.Notice,
+) {
+	notice.TemplateDir = cfg.NotifyTemplateDir
+	if cfg.NotifyManager && containsString(cfg.NotifyEvents, string(notice.Event)) {
+		notice.Manager = clients.UserManager(ctx, cfg, graph, notice.Owner)
+	}
+	if err := cleaner.NotifyOwner(ctx, ns, notice); err != nil {
+		slog.Warn("Error sending notice", logging.KeyNamespace, ns.Name, logging.KeyOwner, notice.Owner,
+			logging.KeyAction, "notify", "event", notice.Event, logging.KeyError, err)
+	}
+}
+
+// lookupStrategies describes how an owner was found in checks
+var lookupStrategies = map[string]string{
+	clients.LookupUPN:    "by UPN",
+	clients.LookupFilter: "by mail, otherMails or proxyAddresses",
+}
+
+// ownerExists looks the owner up in Entra ID and returns the strategy
+// that found them, or "" when none did. When the lookup fails the
+// namespace is skipped for this run, reported by the second result.
+func ownerExists(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email string,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) (string, bool) {
+	strategy, err := clients.FindUser(ctx, cfg, graph, email)
+	if err != nil {
+		decision.AddCheck(checkLookup, "failed: %v", err)
+		stats.IncSkippedLookupFailed()
+		decision.Fail("lookup_failed", logError(logger, stats, "Error looking up owner of %s: %v", ns.Name, err))
+		cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonLookupFailed,
+			fmt.Sprintf("Skipped: could not look up owner %s: %v", email, err))
+		return "", false
+	}
+	logger.Debug("Looked up owner in Entra ID", "exists", strategy != "", "strategy", strategy)
+
+	source := "Entra ID"
+	if cfg.TestMode {
+		source = "TEST_USERS"
+	}
+	switch {
+	case strategy == "":
+		decision.AddCheck(checkLookup, "%s not found in %s", email, source)
+	case lookupStrategies[strategy] != "":
+		decision.AddCheck(checkLookup, "%s found in %s %s", email, source, lookupStrategies[strategy])
+	default:
+		decision.AddCheck(checkLookup, "%s found in %s", email, source)
+	}
+	return strategy, true
+}
+
+// domainVerbs describes the outcome of each domain action in checks
+var domainVerbs = map[string]string{
+	config.DomainActionAllow:  "allowed",
+	config.DomainActionDeny:   "denied",
+	config.DomainActionKeep:   "kept",
+	config.DomainActionExpire: "expired",
+}
+
+// checkOwnerDomain matches the owner's domain against the domain rules
+// and records the rule that matched
+func checkOwnerDomain(email string, cfg *config.Config, decision *stats.Decision) clients.DomainMatch {
+	match, ok := clients.MatchOwnerDomain(email, cfg)
+	if !ok {
+		decision.AddCheck(checkDomain, "%s rejected: no rule in [%s] matches", email, strings.Join(cfg.AllowedDomains, ", "))
+		return match
+	}
+	decision.AddCheck(checkDomain, "%s %s by %q (%s)", email, domainVerbs[match.Action], match.Pattern, match.Source)
+	return match
+}
+
+// lookUpOwners looks the owners in an allowed domain up in Entra ID, in
+// order, and returns the first found, or else the first in an allowed
+// domain. Owners under a keep rule count as found and those under an
+// expire rule as gone, without a lookup. The second result reports
+// whether the owner returned exists. When no owner is in an allowed
+// domain or a lookup fails, the namespace is skipped, reported by the
+// third result.
+func lookUpOwners(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	owners []string,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) (string, bool, bool) {
+	var allowed []string
+	matches := map[string]clients.DomainMatch{}
+	for _, email := range owners {
+		match := checkOwnerDomain(email, cfg, decision)
+		if match.Allowed() {
+			allowed = append(allowed, email)
+			matches[email] = match
+		} else if decision.DomainRule == "" && match.Action != "" {
+			decision.DomainRule = match.String()
+		}
+	}
+	if len(allowed) == 0 {
+		stats.IncSkippedInvalidDomain()
+		decision.Skip("invalid_domain")
+		cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonSkipped,
+			fmt.Sprintf("Skipped: owner %s is not in an allowed domain", strings.Join(owners, ", ")))
+		return "", false, false
+	}
+
+	for _, email := range allowed {
+		var exists bool
+		switch matches[email].Action {
+		case config.DomainActionKeep:
+			decision.AddCheck(checkLookup, "%s not looked up, counted as found", email)
+			exists = true
+		case config.DomainActionExpire:
+			decision.AddCheck(checkLookup, "%s not looked up, counted as gone", email)
+		default:
+			strategy, ok := ownerExists(ctx, cleaner, graph, ns, cfg, email, stats, decision, logger)
+			if !ok {
+				return "", false, false
+			}
+			exists = strategy != ""
+			if exists {
+				decision.LookupStrategy = strategy
+			}
+		}
+		if exists {
+			decision.Owner = email
+			decision.DomainRule = matches[email].String()
+			return email, true, true
+		}
+	}
+	decision.Owner = allowed[0]
+	decision.DomainRule = matches[allowed[0]].String()
+	return allowed[0], false, true
+}
+
+// remaining describes the time left before deletion in days and hours
+func remaining(d time.Duration) string {
+	suffix := "left"
+	if d < 0 {
+		d, suffix = -d, "overdue"
+	}
+	d = d.Round(time.Hour)
+	return fmt.Sprintf("%dd %dh %s", int(d.Hours())/24, int(d.Hours())%24, suffix)
+}
+
+// auditAction adds a completed action to the audit trail, along with the
+// lookup it was based on
+func auditAction(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	cfg *config.Config,
+	action, reason, nsName, email, deleteAt string,
+	evidence audit.Evidence,
+	stats *stats.Stats,
+	logger *slog.Logger,
+) {
+	err := cleaner.Audit(ctx, audit.Entry{
+		Action:    action,
+		Namespace: nsName,
+		Owner:     email,
+		Reason:    reason,
+		DeleteAt:  deleteAt,
+		Evidence:  evidence,
+	})
+	if err != nil {
+		logError(logger, stats, "Error auditing %s of %s: %v", action, nsName, err)
+	}
+}
+
+// lookupEvidence describes the owner lookup behind an audited action
+func lookupEvidence(cfg *config.Config, email string, found bool) audit.Evidence {
+	source := "graph"
+	if cfg.TestMode {
+		source = "test-mode"
+	}
+	return audit.Evidence{
+		Source:    source,
+		Lookup:    email,
+		Found:     found,
+		CheckedAt: time.Now().UTC(),
+	}
+}
+
+// logError logs a failure and keeps it for the run report. It returns the
+// message so it can also be attached to the namespace's decision.
+func logError(logger *slog.Logger, stats *stats.Stats, format string, args ...interface{}) string {
+	msg := fmt.Sprintf(format, args...)
+	logger.Error(msg)
+	stats.AddError(msg)
+	return msg
+}
+
+// logDecision traces the final decision for a namespace at debug level
+func logDecision(logger *slog.Logger, decision *stats.Decision) {
+	logger.Debug("Decided namespace outcome",
+		logging.KeyAction, decision.Decision,
+		logging.KeyReason, decision.Reason,
+	)
+}
+
+// endDecisionSpan tags a namespace's span with its decision and ends it
+func endDecisionSpan(span trace.Span, decision *stats.Decision) {
+	span.SetAttributes(
+		attribute.String(logging.KeyOwner, decision.Owner),
+		attribute.String(logging.KeyAction, decision.Decision),
+		attribute.String(logging.KeyReason, decision.Reason),
+	)
+	if decision.Decision == stats.DecisionFailed {
+		span.SetStatus(codes.Error, decision.Error)
+	}
+	span.End()
+}
+
+// containsString reports whether a slice holds a value
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// quarantineExpired quarantines a namespace on its first pass through and
+// reports whether the second grace period has since run out
+func quarantineExpired(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	today time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) bool {
+	labelValue, quarantined := ns.Labels[quarantineLabelKey]
+	if !quarantined {
+		purgeTime := today.Add(time.Duration(cfg.QuarantinePeriod) * 24 * time.Hour)
+		purgeDate := purgeTime.Format(labelTimeLayout)
+		if err := cleaner.QuarantineNamespace(ctx, ns.Name, purgeDate); err != nil {
+			decision.Fail("grace_period_expired", logError(logger, stats, "Error quarantining ns %s: %v", ns.Name, err))
 		} else {
-			stats.IncDeleted()
+			stats.IncQuarantined()
+			stats.AddPending(ns.Name, purgeTime)
+			decision.Quarantined(purgeTime)
+			cleaner.RecordEvent(ns, corev1.EventTypeWarning, ReasonQuarantined,
+				fmt.Sprintf("Workloads were stopped; the namespace will be deleted after %s", purgeDate))
+		}
+		return false
+	}
+
+	purgeDate, err := time.ParseInLocation(labelTimeLayout, labelValue, time.UTC)
+	if err != nil {
+		logger.Warn("Invalid quarantine-until label", "label", labelValue)
+		stats.IncInvalidLabel()
+		decision.Skip("invalid_label")
+		return false
+	}
+	logger.Debug("Read quarantine-until label", "purge_at", purgeDate, "expired", today.After(purgeDate))
+	if !today.After(purgeDate) {
+		stats.AddPending(ns.Name, purgeDate)
+		decision.Pending("quarantine_running", purgeDate)
+		return false
+	}
+	return true
+}
+
+// namespaceOwners reads the owners listed in the owner annotation,
+// falling back to the owning Profile's spec.owner.name in profile deletion
+// mode. An empty annotation counts as missing; an owner that cannot be
+// parsed is returned as an error.
+func namespaceOwners(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+) ([]string, error) {
+	value, found := ns.Annotations["owner"]
+	if !found && cfg.DeletionMode == config.DeletionModeProfile {
+		email, err := cleaner.ProfileOwner(ctx, ns.Name)
+		if err != nil {
+			slog.Warn("Error looking up profile owner", logging.KeyNamespace, ns.Name, logging.KeyError, err)
+			return nil, nil
+		}
+		value = email
+	}
+
+	owners, err := owner.Parse(value, cfg.OwnerDomainAliases)
+	if errors.Is(err, owner.ErrEmpty) {
+		return nil, nil
+	}
+	return owners, err
+}
+
+// ownerSource names where namespaceOwners found the owners
+func ownerSource(ns *corev1.Namespace) string {
+	if _, found := ns.Annotations["owner"]; found {
+		if creator := ns.Annotations[admission.CreationSourceAnnotation]; creator != "" {
+			return "owner annotation, filled in at creation for " + creator
 		}
+		return "owner annotation"
 	}
+	return "Profile"
 }
@@ -1,7 +1,12 @@
 package cleaner
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,19 +14,39 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 
+	"github.com/StatCan/namespace-cleaner/internal/audit"
 	"github.com/StatCan/namespace-cleaner/internal/clients"
 	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
 	"github.com/StatCan/namespace-cleaner/pkg/stats"
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
-// MockUserExists creates a mock for the UserExists function
+// MockUserExists creates a mock for the UserExists and FindUser functions
 func MockUserExists(result bool) func() {
-	original := clients.UserExists
-	clients.UserExists = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) bool {
-		return result
+	return mockFindUser(func(email string) (string, error) {
+		if result {
+			return clients.LookupUPN, nil
+		}
+		return "", nil
+	})
+}
+
+// mockFindUser makes FindUser, and UserExists with it, answer with find
+func mockFindUser(find func(email string) (string, error)) func() {
+	originalExists, originalFind := clients.UserExists, clients.FindUser
+	clients.FindUser = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (string, error) {
+		return find(email)
+	}
+	clients.UserExists = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (bool, error) {
+		strategy, err := find(email)
+		return strategy != "", err
 	}
-	return func() { clients.UserExists = original }
+	return func() { clients.UserExists, clients.FindUser = originalExists, originalFind }
 }
 
 func TestProcessUnlabeledNamespace(t *testing.T) {
@@ -52,6 +77,7 @@ func TestProcessUnlabeledNamespace(t *testing.T) {
 		ns,
 		cfg,
 		"2023-01-01",
+		time.Now(),
 		stats,
 	)
 
@@ -62,6 +88,12 @@ func TestProcessUnlabeledNamespace(t *testing.T) {
 	if stats.Labeled != 1 {
 		t.Error("Stats should show 1 labeled namespace")
 	}
+	if len(cleaner.labelsRemoved) != 0 {
+		t.Error("Label should not be removed right after labeling")
+	}
+	if len(cleaner.notices) != 1 || cleaner.notices[0].Event != notifier.EventLabeled {
+		t.Errorf("Expected a labeled notice, got %v", cleaner.notices)
+	}
 }
 
 func TestProcessLabeledNamespace(t *testing.T) {
@@ -200,6 +232,527 @@ func TestProcessNamespaces(t *testing.T) {
 	if contains(cleaner.deleted, "future-labeled") {
 		t.Error("'future-labeled' namespace should not be deleted")
 	}
+	if _, ok := stats.PendingDeletion["future-labeled"]; !ok || len(stats.PendingDeletion) != 2 {
+		t.Errorf("Expected 'unlabeled' and 'future-labeled' pending deletion, got %v", stats.PendingDeletion)
+	}
+
+	// Every namespace gets a decision for the run report
+	decisions := map[string]string{}
+	for _, d := range stats.Decisions {
+		decisions[d.Namespace] = d.Decision
+	}
+	for ns, want := range map[string]string{"unlabeled": "labeled", "expired-labeled": "deleted", "future-labeled": "pending"} {
+		if decisions[ns] != want {
+			t.Errorf("Expected %s decision for %s, got %q", want, ns, decisions[ns])
+		}
+	}
+}
+
+func TestProcessLabeledNamespaceQuarantine(t *testing.T) {
+	referenceTime := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	pastDate := referenceTime.Add(-24 * time.Hour).Format(labelTimeLayout)
+	futureDate := referenceTime.Add(24 * time.Hour).Format(labelTimeLayout)
+
+	testCases := []struct {
+		name            string
+		quarantineUntil string
+		userExists      bool
+		wantQuarantined bool
+		wantDeleted     bool
+		wantRestored    bool
+	}{
+		{"first expiry quarantines", "", false, true, false, false},
+		{"quarantine still running", futureDate, false, false, false, false},
+		{"quarantine expired deletes", pastDate, false, false, true, false},
+		{"returning owner restores", futureDate, true, false, false, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Annotations: map[string]string{"owner": "user@example.com"},
+					Labels:      map[string]string{labelKey: pastDate},
+				},
+			}
+			if tc.quarantineUntil != "" {
+				ns.Labels[quarantineLabelKey] = tc.quarantineUntil
+			}
+
+			restore := MockUserExists(tc.userExists)
+			defer restore()
+
+			cleaner := &mockCleaner{}
+			stats := &stats.Stats{}
+			cfg := &config.Config{
+				AllowedDomains:    []string{"example.com"},
+				QuarantineEnabled: true,
+				QuarantinePeriod:  14,
+			}
+
+			processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, referenceTime, stats)
+
+			if got := contains(cleaner.quarantined, "test-ns"); got != tc.wantQuarantined {
+				t.Errorf("quarantined = %v, want %v", got, tc.wantQuarantined)
+			}
+			if got := contains(cleaner.deleted, "test-ns"); got != tc.wantDeleted {
+				t.Errorf("deleted = %v, want %v", got, tc.wantDeleted)
+			}
+			if got := contains(cleaner.restored, "test-ns"); got != tc.wantRestored {
+				t.Errorf("restored = %v, want %v", got, tc.wantRestored)
+			}
+			if tc.wantRestored && !contains(cleaner.labelsRemoved, "test-ns") {
+				t.Error("delete-at label should be removed for a returning owner")
+			}
+		})
+	}
+}
+
+func TestProcessUnlabeledNamespaceProfileOwner(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-ns"},
+	}
+
+	restore := MockUserExists(false)
+	defer restore()
+
+	testCases := []struct {
+		mode        string
+		wantLabeled bool
+	}{
+		{config.DeletionModeNamespace, false},
+		{config.DeletionModeProfile, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			cleaner := &mockCleaner{owners: map[string]string{"test-ns": "user@example.com"}}
+			stats := &stats.Stats{}
+			cfg := &config.Config{
+				AllowedDomains: []string{"example.com"},
+				DeletionMode:   tc.mode,
+			}
+
+			processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-01-01", time.Now(), stats)
+
+			if got := contains(cleaner.labeled, "test-ns"); got != tc.wantLabeled {
+				t.Errorf("labeled = %v, want %v", got, tc.wantLabeled)
+			}
+			if !tc.wantLabeled && stats.SkippedMissingOwner != 1 {
+				t.Error("Namespace without owner annotation should be skipped")
+			}
+		})
+	}
+}
+
+func TestProcessUnlabeledNamespaceOwnerList(t *testing.T) {
+	restore := mockFindUser(func(email string) (string, error) {
+		if strings.EqualFold(email, "here@example.com") {
+			return clients.LookupUPN, nil
+		}
+		return "", nil
+	})
+	defer restore()
+
+	testCases := []struct {
+		name       string
+		owner      string
+		wantReason string
+		wantOwner  string
+	}{
+		{"second owner exists", "gone@example.com, Here@Example.COM", "owner_exists", "Here@example.com"},
+		{"no owner exists", "gone@example.com;left@example.com", "owner_not_found", "gone@example.com"},
+		{"disallowed owner ignored", "here@other.com mailto:here@example.com", "owner_exists", "here@example.com"},
+		{"aliased domain", "here@old.example.org", "owner_exists", "here@example.com"},
+		{"only disallowed owners", "here@other.com", "invalid_domain", "here@other.com"},
+		{"malformed owner", "here@example.com, gone@@example.com", "invalid_owner", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Annotations: map[string]string{"owner": tc.owner}}}
+			cleaner := &mockCleaner{}
+			s := &stats.Stats{}
+			cfg := &config.Config{
+				AllowedDomains:     []string{"example.com"},
+				OwnerDomainAliases: map[string]string{"old.example.org": "example.com"},
+			}
+
+			processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-01-01", time.Now(), s)
+
+			decision := s.Decisions[0]
+			if decision.Reason != tc.wantReason || decision.Owner != tc.wantOwner {
+				t.Errorf("Expected %s for %q, got %s for %q", tc.wantReason, tc.wantOwner, decision.Reason, decision.Owner)
+			}
+			if tc.wantReason == "invalid_owner" && (s.SkippedInvalidOwner != 1 || len(cleaner.labeled) != 0) {
+				t.Errorf("Expected an invalid owner skip, got %+v", s)
+			}
+		})
+	}
+}
+
+func TestProcessUnlabeledNamespaceGuestOwner(t *testing.T) {
+	restore := mockFindUser(func(email string) (string, error) {
+		return clients.LookupFilter, nil
+	})
+	defer restore()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Annotations: map[string]string{"owner": "guest@example.com"}}}
+	s := &stats.Stats{}
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}}
+
+	processUnlabeledNamespace(context.TODO(), &mockCleaner{}, nil, ns, cfg, "2023-01-01", time.Now(), s)
+
+	decision := s.Decisions[0]
+	if decision.Reason != "owner_exists" || decision.LookupStrategy != clients.LookupFilter {
+		t.Errorf("Expected the guest found by filter, got %s (%q)", decision.Reason, decision.LookupStrategy)
+	}
+	if check := decision.Checks[len(decision.Checks)-1]; !strings.Contains(check.Result, "proxyAddresses") {
+		t.Errorf("Expected the lookup strategy in the check, got %q", check.Result)
+	}
+}
+
+func TestProcessUnlabeledNamespaceDomainRules(t *testing.T) {
+	restore := MockUserExists(true)
+	defer restore()
+
+	testCases := []struct {
+		name       string
+		owner      string
+		match      string
+		wantReason string
+		wantRule   string
+	}{
+		{"allowed", "user@example.com", config.DomainMatchSuffix, "owner_exists", "example.com (allow, ALLOWED_DOMAINS)"},
+		{"denied", "user@external.example.com", config.DomainMatchSuffix, "invalid_domain", "external.example.com (deny, DENIED_DOMAINS)"},
+		{"glob", "user@team.cloud.example.org", config.DomainMatchSuffix, "owner_exists", "*.cloud.example.org (allow, ALLOWED_DOMAINS)"},
+		{"kept", "user@svc.example.com", config.DomainMatchSuffix, "owner_exists", "svc.example.com (keep, DOMAIN_ACTIONS)"},
+		{"expired", "user@old.example.com", config.DomainMatchSuffix, "owner_not_found", "old.example.com (expire, DOMAIN_ACTIONS)"},
+		{"exact", "user@team.example.com", config.DomainMatchExact, "invalid_domain", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Annotations: map[string]string{"owner": tc.owner}}}
+			s := &stats.Stats{}
+			cfg := &config.Config{
+				AllowedDomains: []string{"example.com", "*.cloud.example.org"},
+				DeniedDomains:  []string{"external.example.com"},
+				DomainActions:  map[string]string{"svc.example.com": config.DomainActionKeep, "old.example.com": config.DomainActionExpire},
+				DomainMatch:    tc.match,
+			}
+
+			processUnlabeledNamespace(context.TODO(), &mockCleaner{}, nil, ns, cfg, "2023-01-01", time.Now(), s)
+
+			decision := s.Decisions[0]
+			if decision.Reason != tc.wantReason || decision.DomainRule != tc.wantRule {
+				t.Errorf("Expected %s by %q, got %s by %q", tc.wantReason, tc.wantRule, decision.Reason, decision.DomainRule)
+			}
+		})
+	}
+}
+
+func TestProcessLabeledNamespaceReminders(t *testing.T) {
+	referenceTime := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		daysLeft     int
+		wantReminder int
+	}{
+		{"outside all thresholds", 10, -1},
+		{"within 7 day threshold", 5, 7},
+		{"within 1 day threshold", 1, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deleteAt := referenceTime.Add(time.Duration(tc.daysLeft) * 24 * time.Hour)
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Annotations: map[string]string{"owner": "user@example.com"},
+					Labels:      map[string]string{labelKey: deleteAt.Format(labelTimeLayout)},
+				},
+			}
+
+			restore := MockUserExists(false)
+			defer restore()
+
+			cleaner := &mockCleaner{}
+			cfg := &config.Config{
+				AllowedDomains:     []string{"example.com"},
+				NotifyReminderDays: []int{7, 1},
+			}
+
+			processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, referenceTime, &stats.Stats{})
+
+			if tc.wantReminder == -1 {
+				if len(cleaner.notices) != 0 {
+					t.Errorf("Expected no notice, got %v", cleaner.notices)
+				}
+				return
+			}
+			if len(cleaner.notices) != 1 {
+				t.Fatalf("Expected 1 notice, got %v", cleaner.notices)
+			}
+			notice := cleaner.notices[0]
+			if notice.Event != notifier.EventReminder || notice.Reminder != tc.wantReminder || notice.DaysLeft != tc.daysLeft {
+				t.Errorf("Unexpected notice %+v", notice)
+			}
+		})
+	}
+}
+
+func TestProcessLabeledNamespaceDeletedNotice(t *testing.T) {
+	referenceTime := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-ns",
+			Annotations: map[string]string{"owner": "user@example.com"},
+			Labels:      map[string]string{labelKey: referenceTime.Add(-time.Hour).Format(labelTimeLayout)},
+		},
+	}
+
+	restore := MockUserExists(false)
+	defer restore()
+	origManager := clients.UserManager
+	clients.UserManager = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) string {
+		return "manager@example.com"
+	}
+	defer func() { clients.UserManager = origManager }()
+
+	cleaner := &mockCleaner{}
+	cfg := &config.Config{
+		AllowedDomains: []string{"example.com"},
+		NotifyEvents:   []string{"deleted"},
+		NotifyManager:  true,
+	}
+
+	processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, referenceTime, &stats.Stats{})
+
+	if len(cleaner.notices) != 1 || cleaner.notices[0].Event != notifier.EventDeleted {
+		t.Fatalf("Expected a deleted notice, got %v", cleaner.notices)
+	}
+	if cleaner.notices[0].Manager != "manager@example.com" {
+		t.Errorf("Expected manager to be copied, got %q", cleaner.notices[0].Manager)
+	}
+}
+
+func TestProcessNamespaceEvents(t *testing.T) {
+	referenceTime := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	pastDate := referenceTime.Add(-24 * time.Hour).Format(labelTimeLayout)
+
+	testCases := []struct {
+		name       string
+		owner      string
+		label      string
+		userExists bool
+		want       string
+	}{
+		{"labeled", "user@example.com", "", false, ReasonLabeled},
+		{"missing owner", "", "", false, ReasonSkipped},
+		{"invalid domain", "user@example.org", "", false, ReasonSkipped},
+		{"label removed", "user@example.com", pastDate, true, ReasonLabelRemoved},
+		{"invalid label", "user@example.com", "soon", false, ReasonInvalidLabel},
+		{"deleted", "user@example.com", pastDate, false, ReasonDeleted},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-ns", Labels: map[string]string{}},
+			}
+			if tc.owner != "" {
+				ns.Annotations = map[string]string{"owner": tc.owner}
+			}
+
+			restore := MockUserExists(tc.userExists)
+			defer restore()
+
+			cleaner := &mockCleaner{}
+			cfg := &config.Config{AllowedDomains: []string{"example.com"}}
+
+			if tc.label == "" {
+				processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-01-31", time.Now(), &stats.Stats{})
+			} else {
+				ns.Labels[labelKey] = tc.label
+				processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, referenceTime, &stats.Stats{})
+			}
+
+			if len(cleaner.events) != 1 || cleaner.events[0] != tc.want {
+				t.Errorf("Expected a %s event, got %v", tc.want, cleaner.events)
+			}
+		})
+	}
+}
+
+func TestProcessNamespaceAudit(t *testing.T) {
+	referenceTime := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	pastDate := referenceTime.Add(-24 * time.Hour).Format(labelTimeLayout)
+
+	testCases := []struct {
+		name       string
+		label      string
+		userExists bool
+		wantAction string
+	}{
+		{"label", "", false, audit.ActionLabel},
+		{"unlabel", pastDate, true, audit.ActionUnlabel},
+		{"delete", pastDate, false, audit.ActionDelete},
+		{"skip is not audited", "", true, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Annotations: map[string]string{"owner": "user@example.com"},
+					Labels:      map[string]string{},
+				},
+			}
+
+			restore := MockUserExists(tc.userExists)
+			defer restore()
+
+			cleaner := &mockCleaner{}
+			cfg := &config.Config{AllowedDomains: []string{"example.com"}, TestMode: true}
+
+			if tc.label == "" {
+				processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, "2023-01-31_00-00-00Z", time.Now(), &stats.Stats{})
+			} else {
+				ns.Labels[labelKey] = tc.label
+				processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, referenceTime, &stats.Stats{})
+			}
+
+			if tc.wantAction == "" {
+				if len(cleaner.audited) != 0 {
+					t.Errorf("Expected no audit entry, got %+v", cleaner.audited)
+				}
+				return
+			}
+			if len(cleaner.audited) != 1 {
+				t.Fatalf("Expected one audit entry, got %+v", cleaner.audited)
+			}
+			entry := cleaner.audited[0]
+			if entry.Action != tc.wantAction || entry.Owner != "user@example.com" {
+				t.Errorf("Unexpected entry %+v", entry)
+			}
+			if entry.Evidence.Source != "test-mode" || entry.Evidence.Found != tc.userExists {
+				t.Errorf("Unexpected evidence %+v", entry.Evidence)
+			}
+		})
+	}
+}
+
+func TestProcessNamespacesSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(original)
+
+	restore := MockUserExists(false)
+	defer restore()
+
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-ns",
+			Annotations: map[string]string{"owner": "user@example.com"},
+			Labels:      map[string]string{"app.kubeflow.org/part-of": "kubeflow-profile"},
+		},
+	})
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}, GracePeriod: 30}
+	ProcessNamespaces(context.TODO(), &mockCleaner{}, nil, client, cfg, time.Now())
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	want := "evaluateNamespace,phase.unlabeled,phase.labeled,ProcessNamespaces"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("Expected spans %s, got %s", want, got)
+	}
+
+	evaluated := recorder.Ended()[0]
+	for _, attr := range evaluated.Attributes() {
+		if string(attr.Key) == "action" && attr.Value.AsString() != stats.DecisionLabeled {
+			t.Errorf("Expected the labeled decision on the span, got %s", attr.Value.AsString())
+		}
+	}
+	if evaluated.Parent().SpanID() != recorder.Ended()[1].SpanContext().SpanID() {
+		t.Error("Namespace span should be a child of its phase")
+	}
+}
+
+func TestProcessNamespaceLookupFailure(t *testing.T) {
+	restore := mockFindUser(func(email string) (string, error) {
+		return "", errors.New("throttled")
+	})
+	defer restore()
+
+	referenceTime := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-ns",
+			Annotations: map[string]string{"owner": "user@example.com"},
+			Labels:      map[string]string{labelKey: referenceTime.Add(-24 * time.Hour).Format(labelTimeLayout)},
+		},
+	}
+	cleaner := &mockCleaner{}
+	stats := &stats.Stats{}
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}}
+
+	processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, referenceTime, stats)
+
+	if len(cleaner.deleted) != 0 {
+		t.Error("Namespace should not be deleted when the owner lookup fails")
+	}
+	if stats.SkippedLookupFailed != 1 || len(stats.Errors) != 1 {
+		t.Errorf("Expected one failed lookup, got %d (%v)", stats.SkippedLookupFailed, stats.Errors)
+	}
+	if len(cleaner.events) != 1 || cleaner.events[0] != ReasonLookupFailed {
+		t.Errorf("Expected a %s event, got %v", ReasonLookupFailed, cleaner.events)
+	}
+}
+
+func TestProcessNamespaceDebugLog(t *testing.T) {
+	var buf bytes.Buffer
+	original := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(original)
+
+	restore := MockUserExists(true)
+	defer restore()
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-ns",
+			Annotations: map[string]string{"owner": "user@example.com"},
+		},
+	}
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}}
+	processUnlabeledNamespace(context.TODO(), &mockCleaner{}, nil, ns, cfg, "2023-01-31", time.Now(), &stats.Stats{})
+
+	// The last line traces the decision with the shared fields
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		t.Fatalf("Invalid log line: %v", err)
+	}
+	want := map[string]string{
+		"namespace": "test-ns",
+		"owner":     "user@example.com",
+		"phase":     phaseUnlabeled,
+		"action":    "skipped",
+		"reason":    "owner_exists",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("Expected %s=%s, got %v", key, value, entry[key])
+		}
+	}
 }
 
 // Helper function to check if a string is in a slice
@@ -217,6 +770,20 @@ type mockCleaner struct {
 	labeled       []string
 	deleted       []string
 	labelsRemoved []string
+	quarantined   []string
+	restored      []string
+	owners        map[string]string
+	notices       []notifier.Notice
+	events        []string
+	audited       []audit.Entry
+	extended      []string
+	contributors  map[string][]Contributor
+	transferred   []string
+	activity      map[string]Activity
+	rules         *policy.Engine
+	bindingUsers  map[string]string
+	ownerless     []string
+	deleteErr     error
 }
 
 func (m *mockCleaner) LabelNamespace(ctx context.Context, nsName, graceDate string) error {
@@ -229,7 +796,77 @@ func (m *mockCleaner) RemoveLabel(ctx context.Context, nsName string) error {
 	return nil
 }
 
-func (m *mockCleaner) DeleteNamespace(ctx context.Context, nsName string, testMode bool) error {
+func (m *mockCleaner) ExtendDeletion(ctx context.Context, nsName, deleteAt, originalDeleteAt, extendedBy string) error {
+	m.extended = append(m.extended, deleteAt+" by "+extendedBy)
+	return nil
+}
+
+func (m *mockCleaner) DeleteNamespace(ctx context.Context, nsName string, now time.Time) error {
+	if m.deleteErr != nil {
+		return m.deleteErr
+	}
 	m.deleted = append(m.deleted, nsName)
 	return nil
 }
+
+func (m *mockCleaner) QuarantineNamespace(ctx context.Context, nsName, purgeDate string) error {
+	m.quarantined = append(m.quarantined, nsName)
+	return nil
+}
+
+func (m *mockCleaner) RestoreNamespace(ctx context.Context, nsName string) error {
+	m.restored = append(m.restored, nsName)
+	return nil
+}
+
+func (m *mockCleaner) ProfileOwner(ctx context.Context, nsName string) (string, error) {
+	return m.owners[nsName], nil
+}
+
+func (m *mockCleaner) Contributors(ctx context.Context, nsName string) ([]Contributor, error) {
+	return m.contributors[nsName], nil
+}
+
+func (m *mockCleaner) LastActivity(ctx context.Context, ns *corev1.Namespace, now time.Time) (Activity, error) {
+	if activity, found := m.activity[ns.Name]; found {
+		return activity, nil
+	}
+	return Activity{IdleSince: ns.CreationTimestamp.Time, Source: "namespace created"}, nil
+}
+
+func (m *mockCleaner) MarkIdle(ctx context.Context, nsName, graceDate, idleSince string) error {
+	m.labeled = append(m.labeled, nsName)
+	return nil
+}
+
+func (m *mockCleaner) EarliestRoleBindingUser(ctx context.Context, nsName string) (string, error) {
+	return m.bindingUsers[nsName], nil
+}
+
+func (m *mockCleaner) MarkOwnerless(ctx context.Context, nsName, graceDate string) error {
+	m.ownerless = append(m.ownerless, nsName+" until "+graceDate)
+	return nil
+}
+
+func (m *mockCleaner) Policy() *policy.Engine {
+	return m.rules
+}
+
+func (m *mockCleaner) TransferOwnership(ctx context.Context, nsName, previousOwner, newOwner string) error {
+	m.transferred = append(m.transferred, nsName+" to "+newOwner)
+	return nil
+}
+
+func (m *mockCleaner) NotifyOwner(ctx context.Context, ns *corev1.Namespace, notice notifier.Notice) error {
+	m.notices = append(m.notices, notice)
+	return nil
+}
+
+func (m *mockCleaner) RecordEvent(ns *corev1.Namespace, eventType, reason, message string) {
+	m.events = append(m.events, reason)
+}
+
+func (m *mockCleaner) Audit(ctx context.Context, entry audit.Entry) error {
+	m.audited = append(m.audited, entry)
+	return nil
+}
@@ -0,0 +1,77 @@
+package cleaner
+
+import (
+	"context"
+	"log/slog"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+)
+
+var profileGVR = schema.GroupVersionResource{
+	Group:    "kubeflow.org",
+	Version:  "v1",
+	Resource: "profiles",
+}
+
+// ProfileOwner returns spec.owner.name of the Profile owning a namespace,
+// or an empty string when there is no such Profile
+func (c *Cleaner) ProfileOwner(ctx context.Context, nsName string) (string, error) {
+	profile, err := c.findProfile(ctx, nsName)
+	if err != nil || profile == nil {
+		return "", err
+	}
+
+	owner, _, err := unstructured.NestedString(profile.Object, "spec", "owner", "name")
+	return owner, err
+}
+
+// findProfile looks up the Profile owning a namespace. The namespace's
+// owner references are checked first, then the Kubeflow convention of a
+// Profile named after its namespace. A nil Profile means none was found.
+func (c *Cleaner) findProfile(ctx context.Context, nsName string) (*unstructured.Unstructured, error) {
+	if c.dynamicClient == nil {
+		return nil, nil
+	}
+
+	profileName := nsName
+	ns, err := c.kubeClient.CoreV1().Namespaces().Get(ctx, nsName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if ns != nil {
+		for _, ref := range ns.OwnerReferences {
+			if ref.Kind == "Profile" && ref.APIVersion == profileGVR.GroupVersion().String() {
+				profileName = ref.Name
+				break
+			}
+		}
+	}
+
+	profile, err := c.dynamicClient.Resource(profileGVR).Get(ctx, profileName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return profile, nil
+}
+
+// deleteProfile deletes a Profile and lets the profile controller
+// remove the namespace it owns
+func (c *Cleaner) deleteProfile(ctx context.Context, nsName, profileName string) error {
+	if c.dryRun {
+		slog.Info("Would delete owning profile", logging.KeyNamespace, nsName, logging.KeyAction, "delete", "profile", profileName)
+		return nil
+	}
+
+	propagation := c.propagation
+	return c.dynamicClient.Resource(profileGVR).Delete(ctx, profileName, metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+}
@@ -0,0 +1,95 @@
+package cleaner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func newProfile(name, owner string) *unstructured.Unstructured {
+	profile := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"owner": map[string]interface{}{"kind": "User", "name": owner},
+		},
+	}}
+	profile.SetAPIVersion("kubeflow.org/v1")
+	profile.SetKind("Profile")
+	profile.SetName(name)
+	return profile
+}
+
+func TestProfileOwner(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-ns",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "kubeflow.org/v1", Kind: "Profile", Name: "test-profile"},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(ns)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newProfile("test-profile", "user@example.com"))
+	cleaner := NewCleaner(&config.Config{DeletionMode: config.DeletionModeProfile}, client, dynamicClient, nil, nil, nil, nil)
+
+	owner, err := cleaner.ProfileOwner(context.TODO(), "test-ns")
+	if err != nil {
+		t.Fatalf("ProfileOwner failed: %v", err)
+	}
+	if owner != "user@example.com" {
+		t.Errorf("Expected owner user@example.com, got %q", owner)
+	}
+
+	owner, err = cleaner.ProfileOwner(context.TODO(), "other-ns")
+	if err != nil || owner != "" {
+		t.Errorf("Expected no owner for namespace without Profile, got %q (%v)", owner, err)
+	}
+}
+
+func TestDeleteNamespaceProfileMode(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "orphan-ns"}},
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newProfile("test-ns", "user@example.com"))
+	cleaner := NewCleaner(&config.Config{DeletionMode: config.DeletionModeProfile}, client, dynamicClient, nil, nil, nil, nil)
+
+	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", time.Now()); err != nil {
+		t.Fatalf("DeleteNamespace failed: %v", err)
+	}
+	if _, err := dynamicClient.Resource(profileGVR).Get(context.TODO(), "test-ns", metav1.GetOptions{}); err == nil {
+		t.Error("Profile should be deleted")
+	}
+	if _, err := client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{}); err != nil {
+		t.Error("Namespace should be left to the profile controller")
+	}
+
+	// Without a Profile the namespace itself is deleted
+	if err := cleaner.DeleteNamespace(context.TODO(), "orphan-ns", time.Now()); err != nil {
+		t.Fatalf("DeleteNamespace failed: %v", err)
+	}
+	if _, err := client.CoreV1().Namespaces().Get(context.TODO(), "orphan-ns", metav1.GetOptions{}); err == nil {
+		t.Error("Namespace without Profile should be deleted")
+	}
+}
+
+func TestDeleteNamespaceProfileModeDryRun(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newProfile("test-ns", "user@example.com"))
+	cleaner := NewCleaner(&config.Config{DryRun: true, DeletionMode: config.DeletionModeProfile}, client, dynamicClient, nil, nil, nil, nil)
+
+	if err := cleaner.DeleteNamespace(context.TODO(), "test-ns", time.Now()); err != nil {
+		t.Fatalf("DeleteNamespace failed: %v", err)
+	}
+	if _, err := dynamicClient.Resource(profileGVR).Get(context.TODO(), "test-ns", metav1.GetOptions{}); err != nil {
+		t.Error("Profile should not be deleted in dry-run mode")
+	}
+}
@@ -0,0 +1,399 @@
+package cleaner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+)
+
+const (
+	quarantineLabelKey = "namespace-cleaner/quarantine-until"
+	quarantineName     = "namespace-cleaner-quarantine"
+
+	originalReplicasKey = "namespace-cleaner/original-replicas"
+	originalSuspendKey  = "namespace-cleaner/original-suspend"
+	notebookStoppedKey  = "namespace-cleaner/stopped-notebook"
+
+	// kubeflowStoppedKey is the annotation the Kubeflow notebook controller
+	// watches to scale a Notebook down to zero
+	kubeflowStoppedKey = "kubeflow-resource-stopped"
+)
+
+var notebookGVR = schema.GroupVersionResource{
+	Group:    "kubeflow.org",
+	Version:  "v1",
+	Resource: "notebooks",
+}
+
+// QuarantineNamespace stops all workloads in a namespace and blocks new ones.
+// Original values are stored in annotations so RestoreNamespace can undo it.
+func (c *Cleaner) QuarantineNamespace(ctx context.Context, nsName, purgeDate string) error {
+	if c.dryRun {
+		slog.Info("Would quarantine namespace", logging.KeyNamespace, nsName, logging.KeyAction, "quarantine", "purge_at", purgeDate)
+		return nil
+	}
+
+	steps := []func(context.Context, string) error{
+		c.scaleDownDeployments,
+		c.scaleDownStatefulSets,
+		c.suspendCronJobs,
+		c.stopNotebooks,
+		c.createQuarantinePolicy,
+		c.createQuarantineQuota,
+	}
+	for _, step := range steps {
+		if err := step(ctx, nsName); err != nil {
+			return err
+		}
+	}
+
+	patch := []byte(`{"metadata":{"labels":{"` + quarantineLabelKey + `":"` + purgeDate + `"}}}`)
+	_, err := c.kubeClient.CoreV1().Namespaces().Patch(
+		ctx, nsName, types.MergePatchType, patch, metav1.PatchOptions{},
+	)
+	return err
+}
+
+// RestoreNamespace reverts everything done by QuarantineNamespace
+func (c *Cleaner) RestoreNamespace(ctx context.Context, nsName string) error {
+	if c.dryRun {
+		slog.Info("Would restore quarantined namespace", logging.KeyNamespace, nsName, logging.KeyAction, "restore")
+		return nil
+	}
+
+	steps := []func(context.Context, string) error{
+		c.restoreDeployments,
+		c.restoreStatefulSets,
+		c.resumeCronJobs,
+		c.startNotebooks,
+		c.deleteQuarantinePolicy,
+		c.deleteQuarantineQuota,
+	}
+	for _, step := range steps {
+		if err := step(ctx, nsName); err != nil {
+			return err
+		}
+	}
+
+	patch := []byte(`{"metadata":{"labels":{"` + quarantineLabelKey + `":null}}}`)
+	_, err := c.kubeClient.CoreV1().Namespaces().Patch(
+		ctx, nsName, types.MergePatchType, patch, metav1.PatchOptions{},
+	)
+	return err
+}
+
+func (c *Cleaner) scaleDownDeployments(ctx context.Context, nsName string) error {
+	deployments, err := c.kubeClient.AppsV1().Deployments(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deployments.Items {
+		if _, done := d.Annotations[originalReplicasKey]; done {
+			continue
+		}
+		patch := scaleDownPatch(d.Spec.Replicas)
+		if _, err := c.kubeClient.AppsV1().Deployments(nsName).Patch(
+			ctx, d.Name, types.MergePatchType, patch, metav1.PatchOptions{},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) scaleDownStatefulSets(ctx context.Context, nsName string) error {
+	statefulSets, err := c.kubeClient.AppsV1().StatefulSets(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statefulSets.Items {
+		if _, done := s.Annotations[originalReplicasKey]; done {
+			continue
+		}
+		patch := scaleDownPatch(s.Spec.Replicas)
+		if _, err := c.kubeClient.AppsV1().StatefulSets(nsName).Patch(
+			ctx, s.Name, types.MergePatchType, patch, metav1.PatchOptions{},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) suspendCronJobs(ctx context.Context, nsName string) error {
+	cronJobs, err := c.kubeClient.BatchV1().CronJobs(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, cj := range cronJobs.Items {
+		if _, done := cj.Annotations[originalSuspendKey]; done {
+			continue
+		}
+		suspended := cj.Spec.Suspend != nil && *cj.Spec.Suspend
+		patch := mustMarshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{originalSuspendKey: strconv.FormatBool(suspended)},
+			},
+			"spec": map[string]interface{}{"suspend": true},
+		})
+		if _, err := c.kubeClient.BatchV1().CronJobs(nsName).Patch(
+			ctx, cj.Name, types.MergePatchType, patch, metav1.PatchOptions{},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) stopNotebooks(ctx context.Context, nsName string) error {
+	if c.dynamicClient == nil {
+		return nil
+	}
+
+	notebooks, err := c.dynamicClient.Resource(notebookGVR).Namespace(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// Kubeflow is not installed on this cluster
+			return nil
+		}
+		return err
+	}
+
+	for _, nb := range notebooks.Items {
+		if _, stopped := nb.GetAnnotations()[kubeflowStoppedKey]; stopped {
+			continue
+		}
+		patch := mustMarshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{
+					kubeflowStoppedKey: time.Now().UTC().Format(time.RFC3339),
+					notebookStoppedKey: "true",
+				},
+			},
+		})
+		if _, err := c.dynamicClient.Resource(notebookGVR).Namespace(nsName).Patch(
+			ctx, nb.GetName(), types.MergePatchType, patch, metav1.PatchOptions{},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) createQuarantinePolicy(ctx context.Context, nsName string) error {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: quarantineName, Namespace: nsName},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+				networkingv1.PolicyTypeEgress,
+			},
+		},
+	}
+	_, err := c.kubeClient.NetworkingV1().NetworkPolicies(nsName).Create(ctx, policy, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Cleaner) createQuarantineQuota(ctx context.Context, nsName string) error {
+	zero := resource.MustParse("0")
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: quarantineName, Namespace: nsName},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{
+				corev1.ResourcePods:            zero,
+				corev1.ResourceRequestsCPU:     zero,
+				corev1.ResourceRequestsMemory:  zero,
+				corev1.ResourceRequestsStorage: zero,
+			},
+		},
+	}
+	_, err := c.kubeClient.CoreV1().ResourceQuotas(nsName).Create(ctx, quota, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Cleaner) restoreDeployments(ctx context.Context, nsName string) error {
+	deployments, err := c.kubeClient.AppsV1().Deployments(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deployments.Items {
+		original, found := d.Annotations[originalReplicasKey]
+		if !found {
+			continue
+		}
+		patch, err := restorePatch(original)
+		if err != nil {
+			slog.Warn("Skipping restore of deployment", logging.KeyNamespace, nsName, "deployment", d.Name, logging.KeyError, err)
+			continue
+		}
+		if _, err := c.kubeClient.AppsV1().Deployments(nsName).Patch(
+			ctx, d.Name, types.MergePatchType, patch, metav1.PatchOptions{},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) restoreStatefulSets(ctx context.Context, nsName string) error {
+	statefulSets, err := c.kubeClient.AppsV1().StatefulSets(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statefulSets.Items {
+		original, found := s.Annotations[originalReplicasKey]
+		if !found {
+			continue
+		}
+		patch, err := restorePatch(original)
+		if err != nil {
+			slog.Warn("Skipping restore of statefulset", logging.KeyNamespace, nsName, "statefulset", s.Name, logging.KeyError, err)
+			continue
+		}
+		if _, err := c.kubeClient.AppsV1().StatefulSets(nsName).Patch(
+			ctx, s.Name, types.MergePatchType, patch, metav1.PatchOptions{},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) resumeCronJobs(ctx context.Context, nsName string) error {
+	cronJobs, err := c.kubeClient.BatchV1().CronJobs(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, cj := range cronJobs.Items {
+		original, found := cj.Annotations[originalSuspendKey]
+		if !found {
+			continue
+		}
+		suspended, _ := strconv.ParseBool(original)
+		patch := mustMarshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{originalSuspendKey: nil},
+			},
+			"spec": map[string]interface{}{"suspend": suspended},
+		})
+		if _, err := c.kubeClient.BatchV1().CronJobs(nsName).Patch(
+			ctx, cj.Name, types.MergePatchType, patch, metav1.PatchOptions{},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) startNotebooks(ctx context.Context, nsName string) error {
+	if c.dynamicClient == nil {
+		return nil
+	}
+
+	notebooks, err := c.dynamicClient.Resource(notebookGVR).Namespace(nsName).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, nb := range notebooks.Items {
+		if _, ours := nb.GetAnnotations()[notebookStoppedKey]; !ours {
+			continue
+		}
+		patch := mustMarshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]interface{}{
+					kubeflowStoppedKey: nil,
+					notebookStoppedKey: nil,
+				},
+			},
+		})
+		if _, err := c.dynamicClient.Resource(notebookGVR).Namespace(nsName).Patch(
+			ctx, nb.GetName(), types.MergePatchType, patch, metav1.PatchOptions{},
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cleaner) deleteQuarantinePolicy(ctx context.Context, nsName string) error {
+	err := c.kubeClient.NetworkingV1().NetworkPolicies(nsName).Delete(ctx, quarantineName, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Cleaner) deleteQuarantineQuota(ctx context.Context, nsName string) error {
+	err := c.kubeClient.CoreV1().ResourceQuotas(nsName).Delete(ctx, quarantineName, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// scaleDownPatch scales a workload to zero and remembers its replica count
+func scaleDownPatch(replicas *int32) []byte {
+	original := int32(1)
+	if replicas != nil {
+		original = *replicas
+	}
+	return mustMarshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{originalReplicasKey: strconv.Itoa(int(original))},
+		},
+		"spec": map[string]interface{}{"replicas": 0},
+	})
+}
+
+// restorePatch scales a workload back to its recorded replica count
+func restorePatch(original string) ([]byte, error) {
+	replicas, err := strconv.Atoi(original)
+	if err != nil || replicas < 0 {
+		return nil, fmt.Errorf("invalid replica count %q", original)
+	}
+	return mustMarshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{originalReplicasKey: nil},
+		},
+		"spec": map[string]interface{}{"replicas": replicas},
+	}), nil
+}
+
+// mustMarshal encodes a patch built from plain maps, which cannot fail
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
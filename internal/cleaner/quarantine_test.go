@@ -0,0 +1,158 @@
+package cleaner
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func newNotebook(namespace, name string) *unstructured.Unstructured {
+	nb := &unstructured.Unstructured{}
+	nb.SetAPIVersion("kubeflow.org/v1")
+	nb.SetKind("Notebook")
+	nb.SetNamespace(namespace)
+	nb.SetName(name)
+	return nb
+}
+
+func TestQuarantineAndRestore(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "test-ns"},
+			Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(3)},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "test-ns"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: int32Ptr(2)},
+		},
+		&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "test-ns"},
+		},
+	)
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{notebookGVR: "NotebookList"},
+		newNotebook("test-ns", "jupyter"),
+	)
+	cleaner := NewCleaner(&config.Config{}, client, dynamicClient, nil, nil, nil, nil)
+
+	if err := cleaner.QuarantineNamespace(ctx, "test-ns", "2023-01-15_00-00-00Z"); err != nil {
+		t.Fatalf("QuarantineNamespace failed: %v", err)
+	}
+
+	deploy, _ := client.AppsV1().Deployments("test-ns").Get(ctx, "web", metav1.GetOptions{})
+	if *deploy.Spec.Replicas != 0 || deploy.Annotations[originalReplicasKey] != "3" {
+		t.Errorf("Deployment not scaled down: replicas=%d annotations=%v", *deploy.Spec.Replicas, deploy.Annotations)
+	}
+	sts, _ := client.AppsV1().StatefulSets("test-ns").Get(ctx, "db", metav1.GetOptions{})
+	if *sts.Spec.Replicas != 0 || sts.Annotations[originalReplicasKey] != "2" {
+		t.Errorf("StatefulSet not scaled down: replicas=%d annotations=%v", *sts.Spec.Replicas, sts.Annotations)
+	}
+	cj, _ := client.BatchV1().CronJobs("test-ns").Get(ctx, "nightly", metav1.GetOptions{})
+	if cj.Spec.Suspend == nil || !*cj.Spec.Suspend {
+		t.Error("CronJob should be suspended")
+	}
+	nb, _ := dynamicClient.Resource(notebookGVR).Namespace("test-ns").Get(ctx, "jupyter", metav1.GetOptions{})
+	if _, stopped := nb.GetAnnotations()[kubeflowStoppedKey]; !stopped {
+		t.Error("Notebook should be stopped")
+	}
+	if _, err := client.NetworkingV1().NetworkPolicies("test-ns").Get(ctx, quarantineName, metav1.GetOptions{}); err != nil {
+		t.Errorf("Deny-all NetworkPolicy missing: %v", err)
+	}
+	if _, err := client.CoreV1().ResourceQuotas("test-ns").Get(ctx, quarantineName, metav1.GetOptions{}); err != nil {
+		t.Errorf("Zero ResourceQuota missing: %v", err)
+	}
+	ns, _ := client.CoreV1().Namespaces().Get(ctx, "test-ns", metav1.GetOptions{})
+	if ns.Labels[quarantineLabelKey] != "2023-01-15_00-00-00Z" {
+		t.Errorf("Quarantine label not applied: %v", ns.Labels)
+	}
+
+	if err := cleaner.RestoreNamespace(ctx, "test-ns"); err != nil {
+		t.Fatalf("RestoreNamespace failed: %v", err)
+	}
+
+	deploy, _ = client.AppsV1().Deployments("test-ns").Get(ctx, "web", metav1.GetOptions{})
+	if *deploy.Spec.Replicas != 3 {
+		t.Errorf("Deployment replicas not restored, got %d", *deploy.Spec.Replicas)
+	}
+	if _, found := deploy.Annotations[originalReplicasKey]; found {
+		t.Error("Deployment restore annotation should be removed")
+	}
+	sts, _ = client.AppsV1().StatefulSets("test-ns").Get(ctx, "db", metav1.GetOptions{})
+	if *sts.Spec.Replicas != 2 {
+		t.Errorf("StatefulSet replicas not restored, got %d", *sts.Spec.Replicas)
+	}
+	cj, _ = client.BatchV1().CronJobs("test-ns").Get(ctx, "nightly", metav1.GetOptions{})
+	if cj.Spec.Suspend != nil && *cj.Spec.Suspend {
+		t.Error("CronJob should be resumed")
+	}
+	nb, _ = dynamicClient.Resource(notebookGVR).Namespace("test-ns").Get(ctx, "jupyter", metav1.GetOptions{})
+	if _, stopped := nb.GetAnnotations()[kubeflowStoppedKey]; stopped {
+		t.Error("Notebook should be started again")
+	}
+	if _, err := client.NetworkingV1().NetworkPolicies("test-ns").Get(ctx, quarantineName, metav1.GetOptions{}); err == nil {
+		t.Error("Deny-all NetworkPolicy should be removed")
+	}
+	if _, err := client.CoreV1().ResourceQuotas("test-ns").Get(ctx, quarantineName, metav1.GetOptions{}); err == nil {
+		t.Error("Zero ResourceQuota should be removed")
+	}
+	ns, _ = client.CoreV1().Namespaces().Get(ctx, "test-ns", metav1.GetOptions{})
+	if _, found := ns.Labels[quarantineLabelKey]; found {
+		t.Error("Quarantine label should be removed")
+	}
+}
+
+func TestQuarantineKeepsUserStoppedNotebooks(t *testing.T) {
+	ctx := context.TODO()
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}})
+	nb := newNotebook("test-ns", "jupyter")
+	nb.SetAnnotations(map[string]string{kubeflowStoppedKey: "2022-12-01T00:00:00Z"})
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{notebookGVR: "NotebookList"},
+		nb,
+	)
+	cleaner := NewCleaner(&config.Config{}, client, dynamicClient, nil, nil, nil, nil)
+
+	if err := cleaner.QuarantineNamespace(ctx, "test-ns", "2023-01-15_00-00-00Z"); err != nil {
+		t.Fatalf("QuarantineNamespace failed: %v", err)
+	}
+	if err := cleaner.RestoreNamespace(ctx, "test-ns"); err != nil {
+		t.Fatalf("RestoreNamespace failed: %v", err)
+	}
+
+	got, _ := dynamicClient.Resource(notebookGVR).Namespace("test-ns").Get(ctx, "jupyter", metav1.GetOptions{})
+	if got.GetAnnotations()[kubeflowStoppedKey] != "2022-12-01T00:00:00Z" {
+		t.Error("Notebook stopped by its owner should stay stopped after restore")
+	}
+}
+
+func TestQuarantineDryRun(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	cleaner := NewCleaner(&config.Config{DryRun: true}, client, nil, nil, nil, nil, nil)
+
+	if err := cleaner.QuarantineNamespace(context.TODO(), "test-ns", "2023-01-15_00-00-00Z"); err != nil {
+		t.Fatalf("QuarantineNamespace failed: %v", err)
+	}
+	if err := cleaner.RestoreNamespace(context.TODO(), "test-ns"); err != nil {
+		t.Fatalf("RestoreNamespace failed: %v", err)
+	}
+	if len(client.Actions()) != 0 {
+		t.Errorf("Expected no API calls in dry-run mode, got %d", len(client.Actions()))
+	}
+}
@@ -0,0 +1,99 @@
+package cleaner
+
+import (
+	"fmt"
+	"log/slog"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+const checkPolicy = "policy"
+
+// Policy returns the rules and expressions namespaces are evaluated
+// under, or nil when no policy file is configured
+func (c *Cleaner) Policy() *policy.Engine {
+	return c.rules
+}
+
+// applyRule looks up the policy rule for a namespace and returns it with
+// the configuration the namespace is evaluated under. Without a matching
+// rule that is cfg itself.
+func applyRule(
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email string,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) (*policy.Rule, *config.Config) {
+	rule := cleaner.Policy().Match(ns.Name, ns.Labels, email)
+	if rule == nil {
+		return nil, cfg
+	}
+	decision.Rule = rule.Name
+	decision.AddCheck(checkPolicy, "rule %q: %s", rule.Name, rule)
+	logger.Debug("Matched policy rule", "rule", rule.Name)
+	return rule, rule.Apply(cfg)
+}
+
+// ignoredByRule skips a namespace whose rule leaves it alone
+func ignoredByRule(
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	rule *policy.Rule,
+	stats *stats.Stats,
+	decision *stats.Decision,
+) bool {
+	if rule == nil || rule.Action != policy.ActionIgnore {
+		return false
+	}
+	stats.IncSkippedByPolicy()
+	decision.Skip("policy_ignored")
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonSkipped,
+		fmt.Sprintf("Skipped: policy rule %q ignores the namespace", rule.Name))
+	return true
+}
+
+// deletionHeld reports whether a rule keeps an expired namespace: rules
+// that only label never delete, and rules with a deletion limit stop once
+// it is reached for the run
+func deletionHeld(
+	cleaner NamespaceCleaner,
+	ns *corev1.Namespace,
+	rule *policy.Rule,
+	stats *stats.Stats,
+	decision *stats.Decision,
+) bool {
+	if rule == nil {
+		return false
+	}
+	if rule.Action == policy.ActionLabel {
+		stats.IncSkippedByPolicy()
+		decision.Skip("label_only")
+		return true
+	}
+	if rule.MaxDeletions == 0 || ruleDeletions(stats, rule.Name) < rule.MaxDeletions {
+		return false
+	}
+
+	stats.IncSkippedByPolicy()
+	decision.Skip("deletion_limit")
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonDeferred,
+		fmt.Sprintf("Deletion deferred: policy rule %q already deleted %d namespace(s) this run", rule.Name, rule.MaxDeletions))
+	return true
+}
+
+// ruleDeletions counts the namespaces deleted under a rule so far this run
+func ruleDeletions(runStats *stats.Stats, rule string) int {
+	count := 0
+	for _, d := range runStats.Decisions {
+		if d.Rule == rule && d.Decision == stats.DecisionDeleted {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,122 @@
+package cleaner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/policy"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func TestPolicyRules(t *testing.T) {
+	rules, err := policy.Parse([]byte(`
+rules:
+  - name: system
+    match: {name: "^kube-"}
+    action: ignore
+  - name: contractors
+    match: {ownerDomains: [contractors.example.com]}
+    gracePeriod: 7
+    maxDeletions: 1
+  - name: sandbox
+    match: {labels: {tier: sandbox}}
+    action: label
+  - name: employees
+    gracePeriod: 90
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		nsName       string
+		owner        string
+		tier         string
+		labeled      bool
+		wantDecision string
+		wantReason   string
+		wantDeleteAt time.Time
+	}{
+		{"contractor labeled for 7 days", "team-a", "user@contractors.example.com", "", false,
+			stats.DecisionLabeled, "owner_not_found", today.Add(7 * 24 * time.Hour)},
+		{"employee labeled for 90 days", "team-a", "user@example.com", "", false,
+			stats.DecisionLabeled, "owner_not_found", today.Add(90 * 24 * time.Hour)},
+		{"system namespace ignored", "kube-team", "user@example.com", "", false, stats.DecisionSkipped, "policy_ignored", time.Time{}},
+		{"expired contractor deleted", "team-a", "user@contractors.example.com", "", true, stats.DecisionDeleted, "grace_period_expired", time.Time{}},
+		{"expired sandbox kept labeled", "team-a", "user@example.com", "sandbox", true, stats.DecisionSkipped, "label_only", time.Time{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			restore := MockUserExists(false)
+			defer restore()
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        tc.nsName,
+					Labels:      map[string]string{"tier": tc.tier},
+					Annotations: map[string]string{"owner": tc.owner},
+				},
+			}
+			cleaner := &mockCleaner{rules: rules}
+			s := &stats.Stats{}
+			cfg := &config.Config{AllowedDomains: []string{"example.com"}, GracePeriod: 30}
+
+			if tc.labeled {
+				ns.Labels[labelKey] = "2023-01-05_00-00-00Z"
+				processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+			} else {
+				processUnlabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, gracePeriodEnd(cfg, today), today, s)
+			}
+
+			decision := s.Decisions[0]
+			if decision.Decision != tc.wantDecision || decision.Reason != tc.wantReason {
+				t.Errorf("Expected %s (%s), got %s (%s)", tc.wantDecision, tc.wantReason, decision.Decision, decision.Reason)
+			}
+			if !tc.wantDeleteAt.IsZero() && !decision.NewDeleteAt.Equal(tc.wantDeleteAt) {
+				t.Errorf("Expected delete-at %s, got %s", tc.wantDeleteAt, decision.NewDeleteAt)
+			}
+			if decision.Rule == "" {
+				t.Error("Expected the matched rule to be recorded")
+			}
+		})
+	}
+}
+
+func TestPolicyDeletionLimit(t *testing.T) {
+	restore := MockUserExists(false)
+	defer restore()
+
+	rules, err := policy.Parse([]byte(`rules: [{name: contractors, maxDeletions: 1}]`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	cleaner := &mockCleaner{rules: rules}
+	s := &stats.Stats{}
+	cfg := &config.Config{AllowedDomains: []string{"example.com"}}
+
+	for _, name := range []string{"ns-a", "ns-b"} {
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Labels:      map[string]string{labelKey: "2023-01-05_00-00-00Z"},
+				Annotations: map[string]string{"owner": "user@example.com"},
+			},
+		}
+		processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), s)
+	}
+
+	if len(cleaner.deleted) != 1 || s.Decisions[1].Reason != "deletion_limit" || s.SkippedByPolicy != 1 {
+		t.Errorf("Expected one deletion and one deferral, got %v %+v", cleaner.deleted, s.Decisions[1])
+	}
+	if !contains(cleaner.events, ReasonDeferred) {
+		t.Errorf("Expected a %s event, got %v", ReasonDeferred, cleaner.events)
+	}
+}
@@ -0,0 +1,205 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+const (
+	// transferredFromKey keeps the owner a namespace was taken over from
+	transferredFromKey = "namespace-cleaner/transferred-from"
+
+	checkSuccessor = "successor"
+)
+
+// TransferOwnership hands a namespace to a new owner: the owner annotation
+// and the owning Profile are updated, and the pending deletion is dropped
+func (c *Cleaner) TransferOwnership(ctx context.Context, nsName, previousOwner, newOwner string) error {
+	if c.dryRun {
+		slog.Info("Would transfer ownership", logging.KeyNamespace, nsName, logging.KeyOwner, previousOwner,
+			logging.KeyAction, "transfer", "new_owner", newOwner)
+		return nil
+	}
+
+	// The profile controller copies the Profile owner onto the namespace,
+	// so it is updated first
+	profile, err := c.findProfile(ctx, nsName)
+	if err != nil {
+		return err
+	}
+	if profile != nil {
+		patch := mustMarshal(map[string]interface{}{
+			"spec": map[string]interface{}{
+				"owner": map[string]string{"name": newOwner},
+			},
+		})
+		_, err := c.dynamicClient.Resource(profileGVR).Patch(
+			ctx, profile.GetName(), types.MergePatchType, patch, metav1.PatchOptions{},
+		)
+		if err != nil {
+			return fmt.Errorf("updating profile %s: %w", profile.GetName(), err)
+		}
+	}
+
+	patch := mustMarshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{labelKey: nil},
+			"annotations": map[string]interface{}{
+				"owner":             newOwner,
+				transferredFromKey:  previousOwner,
+				extendUntilKey:      nil,
+				originalDeleteAtKey: nil,
+				extendedByKey:       nil,
+			},
+		},
+	})
+	_, err = c.kubeClient.CoreV1().Namespaces().Patch(
+		ctx, nsName, types.MergePatchType, patch, metav1.PatchOptions{},
+	)
+	return err
+}
+
+// transferOwnership hands an expired namespace to the first successor
+// found through cfg.TransferSources. It reports whether the namespace was
+// transferred; when it was not, deletion goes ahead.
+func transferOwnership(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email string,
+	deletionDate time.Time,
+	stats *stats.Stats,
+	decision *stats.Decision,
+	logger *slog.Logger,
+) bool {
+	successor, source := findSuccessor(ctx, cleaner, graph, ns, cfg, email, logger)
+	if successor == "" {
+		decision.AddCheck(checkSuccessor, "none found through [%s]", strings.Join(cfg.TransferSources, ", "))
+		return false
+	}
+	decision.AddCheck(checkSuccessor, "%s (from %s)", successor, source)
+
+	if _, quarantined := ns.Labels[quarantineLabelKey]; quarantined {
+		if err := cleaner.RestoreNamespace(ctx, ns.Name); err != nil {
+			decision.Fail("owner_transferred", logError(logger, stats, "Error restoring quarantined ns %s: %v", ns.Name, err))
+			return true
+		}
+		stats.IncRestored()
+		cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonRestored,
+			fmt.Sprintf("Ownership passed to %s; the quarantine was reverted", successor))
+	}
+
+	if err := cleaner.TransferOwnership(ctx, ns.Name, email, successor); err != nil {
+		decision.Fail("owner_transferred", logError(logger, stats, "Error transferring ns %s to %s: %v", ns.Name, successor, err))
+		return true
+	}
+	stats.IncTransferred()
+	decision.Transferred(successor)
+	logger.Info("Transferred ownership", "new_owner", successor, "source", source)
+	cleaner.RecordEvent(ns, corev1.EventTypeNormal, ReasonTransferred,
+		fmt.Sprintf("Owner %s was not found in Entra ID; ownership passed to %s (%s)", email, successor, source))
+
+	err := cleaner.Audit(ctx, audit.Entry{
+		Action:    audit.ActionTransfer,
+		Namespace: ns.Name,
+		Owner:     email,
+		Reason:    "owner_transferred",
+		DeleteAt:  deletionDate.Format(labelTimeLayout),
+		Evidence:  lookupEvidence(cfg, successor, true),
+		NewOwner:  successor,
+	})
+	if err != nil {
+		logError(logger, stats, "Error auditing transfer of %s: %v", ns.Name, err)
+	}
+
+	notifyOwner(ctx, cleaner, graph, ns, cfg, notifier.Notice{
+		Event:         notifier.EventTransferred,
+		Namespace:     ns.Name,
+		Owner:         successor,
+		PreviousOwner: email,
+		DeleteAt:      deletionDate,
+	})
+	return true
+}
+
+// findSuccessor returns the first usable successor and where it came from.
+// A successor must differ from the owner, be in an allowed domain and
+// exist in Entra ID.
+func findSuccessor(
+	ctx context.Context,
+	cleaner NamespaceCleaner,
+	graph *msgraphsdk.GraphServiceClient,
+	ns *corev1.Namespace,
+	cfg *config.Config,
+	email string,
+	logger *slog.Logger,
+) (string, string) {
+	usable := func(candidate string) bool {
+		active, err := activeUser(ctx, cfg, graph, candidate, email)
+		if err != nil {
+			logger.Warn("Error looking up successor", "candidate", candidate, logging.KeyError, err)
+		}
+		return active
+	}
+
+	for _, source := range cfg.TransferSources {
+		switch source {
+		case config.TransferSourceManager:
+			if manager := clients.UserManager(ctx, cfg, graph, email); usable(manager) {
+				return manager, "manager"
+			}
+		case config.TransferSourceContributor:
+			contributor, err := activeContributor(ctx, cleaner, graph, ns.Name, cfg, email)
+			if err != nil {
+				logger.Warn("Error looking up contributors", logging.KeyError, err)
+				continue
+			}
+			if contributor != nil {
+				return contributor.Email, fmt.Sprintf("%s contributor, %s", contributor.Role, contributor.Source)
+			}
+		case config.TransferSourceSteward:
+			if domain, steward := domainSteward(email, cfg.TransferStewards); usable(steward) {
+				return steward, "steward of " + domain
+			}
+		}
+	}
+	return "", ""
+}
+
+// domainSteward returns the steward of the most specific domain an email
+// falls under, along with that domain
+func domainSteward(email string, stewards map[string]string) (string, string) {
+	domains := make([]string, 0, len(stewards))
+	for domain := range stewards {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool { return len(domains[i]) > len(domains[j]) })
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return "", ""
+	}
+	domain, ok := clients.MatchDomain(email[:at]+strings.ToLower(email[at:]), domains)
+	if !ok {
+		return "", ""
+	}
+	return domain, stewards[domain]
+}
@@ -0,0 +1,183 @@
+package cleaner
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/audit"
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func TestTransferOwnership(t *testing.T) {
+	client := fake.NewSimpleClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "test-ns",
+			Labels: map[string]string{labelKey: "2023-01-01_00-00-00Z"},
+			Annotations: map[string]string{
+				"owner":             "old@example.com",
+				extendUntilKey:      "2023-02-01",
+				originalDeleteAtKey: "2022-12-01_00-00-00Z",
+			},
+		},
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newProfile("test-ns", "old@example.com"))
+
+	dryRun := NewCleaner(&config.Config{DryRun: true}, client, dynamicClient, nil, nil, nil, nil)
+	if err := dryRun.TransferOwnership(context.TODO(), "test-ns", "old@example.com", "new@example.com"); err != nil {
+		t.Fatalf("TransferOwnership failed: %v", err)
+	}
+	ns, _ := client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if ns.Annotations["owner"] != "old@example.com" {
+		t.Error("Dry run should not change the owner")
+	}
+
+	cleaner := NewCleaner(&config.Config{}, client, dynamicClient, nil, nil, nil, nil)
+	if err := cleaner.TransferOwnership(context.TODO(), "test-ns", "old@example.com", "new@example.com"); err != nil {
+		t.Fatalf("TransferOwnership failed: %v", err)
+	}
+
+	ns, _ = client.CoreV1().Namespaces().Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	if ns.Annotations["owner"] != "new@example.com" || ns.Annotations[transferredFromKey] != "old@example.com" {
+		t.Errorf("Unexpected annotations %v", ns.Annotations)
+	}
+	if _, found := ns.Labels[labelKey]; found {
+		t.Error("Expected the delete-at label to be removed")
+	}
+	for _, key := range []string{extendUntilKey, originalDeleteAtKey} {
+		if _, found := ns.Annotations[key]; found {
+			t.Errorf("Expected %s to be removed", key)
+		}
+	}
+
+	profile, _ := dynamicClient.Resource(profileGVR).Get(context.TODO(), "test-ns", metav1.GetOptions{})
+	owner, _, _ := unstructured.NestedString(profile.Object, "spec", "owner", "name")
+	if owner != "new@example.com" {
+		t.Errorf("Expected the Profile owner to be updated, got %q", owner)
+	}
+}
+
+func TestProcessLabeledNamespaceTransfer(t *testing.T) {
+	today := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		sources      []string
+		manager      string
+		contributors []Contributor
+		stewards     map[string]string
+		quarantined  bool
+		wantOwner    string
+	}{
+		{"manager", []string{config.TransferSourceManager}, "boss@example.com", nil, nil, false, "boss@example.com"},
+		{"contributor with an allowed role",
+			[]string{config.TransferSourceContributor}, "",
+			[]Contributor{
+				{Email: "viewer@example.com", Role: "view", Source: "RoleBinding/viewer"},
+				{Email: "editor@example.com", Role: "edit", Source: "RoleBinding/editor"},
+			}, nil, false, "editor@example.com"},
+		{"steward of a parent domain",
+			[]string{config.TransferSourceSteward}, "", nil,
+			map[string]string{"example.com": "steward@example.com", "other.com": "x@other.com"}, false, "steward@example.com"},
+		{"sources tried in order",
+			[]string{config.TransferSourceManager, config.TransferSourceSteward}, "gone@example.com", nil,
+			map[string]string{"example.com": "steward@example.com"}, false, "steward@example.com"},
+		{"manager outside the allowed domains", []string{config.TransferSourceManager}, "boss@elsewhere.com", nil, nil, false, ""},
+		{"no successor", []string{config.TransferSourceManager, config.TransferSourceContributor}, "", nil, nil, false, ""},
+		{"lifts quarantine", []string{config.TransferSourceManager}, "boss@example.com", nil, nil, true, "boss@example.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := clients.UserManager
+			clients.UserManager = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) string {
+				return tc.manager
+			}
+			defer func() { clients.UserManager = original }()
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "test-ns",
+					Labels:      map[string]string{labelKey: "2023-01-05_00-00-00Z"},
+					Annotations: map[string]string{"owner": "old@example.com"},
+				},
+			}
+			if tc.quarantined {
+				ns.Labels[quarantineLabelKey] = "2023-01-20_00-00-00Z"
+			}
+
+			cleaner := &mockCleaner{contributors: map[string][]Contributor{"test-ns": tc.contributors}}
+			s := &stats.Stats{}
+			cfg := &config.Config{
+				TestMode:         true,
+				TestUsers:        []string{"boss@example.com", "boss@elsewhere.com", "viewer@example.com", "editor@example.com", "steward@example.com"},
+				AllowedDomains:   []string{"example.com"},
+				TransferEnabled:  true,
+				TransferSources:  tc.sources,
+				TransferStewards: tc.stewards,
+				ContributorRoles: []string{"admin", "edit"},
+			}
+			processLabeledNamespace(context.TODO(), cleaner, nil, ns, cfg, today, s)
+
+			if tc.wantOwner == "" {
+				if len(cleaner.transferred) != 0 || len(cleaner.deleted) != 1 {
+					t.Errorf("Expected deletion without a successor, got transfers %v", cleaner.transferred)
+				}
+				return
+			}
+
+			decision := s.Decisions[0]
+			if decision.Decision != stats.DecisionTransferred || decision.NewOwner != tc.wantOwner {
+				t.Errorf("Expected a transfer to %s, got %+v", tc.wantOwner, decision)
+			}
+			if len(cleaner.deleted) != 0 || s.Transferred != 1 {
+				t.Errorf("Expected no deletion, got %v", cleaner.deleted)
+			}
+			if !contains(cleaner.events, ReasonTransferred) {
+				t.Errorf("Expected a %s event, got %v", ReasonTransferred, cleaner.events)
+			}
+			entry := cleaner.audited[0]
+			if entry.Action != audit.ActionTransfer || entry.NewOwner != tc.wantOwner || entry.Owner != "old@example.com" {
+				t.Errorf("Unexpected audit entry %+v", entry)
+			}
+			notice := cleaner.notices[0]
+			if notice.Event != notifier.EventTransferred || notice.Owner != tc.wantOwner || notice.PreviousOwner != "old@example.com" {
+				t.Errorf("Unexpected notice %+v", notice)
+			}
+			if tc.quarantined && len(cleaner.restored) != 1 {
+				t.Error("Expected the quarantine to be reverted")
+			}
+		})
+	}
+}
+
+func TestDomainSteward(t *testing.T) {
+	stewards := map[string]string{"example.com": "a@example.com", "sub.example.com": "b@example.com"}
+
+	testCases := []struct {
+		email      string
+		wantDomain string
+	}{
+		{"user@sub.example.com", "sub.example.com"},
+		{"user@EXAMPLE.com", "example.com"},
+		{"user@other.com", ""},
+		{"not-an-email", ""},
+	}
+
+	for _, tc := range testCases {
+		if domain, _ := domainSteward(tc.email, stewards); domain != tc.wantDomain {
+			t.Errorf("%s: expected %q, got %q", tc.email, tc.wantDomain, domain)
+		}
+	}
+}
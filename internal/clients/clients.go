@@ -2,23 +2,36 @@ package clients
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	msauth "github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	abstractions "github.com/microsoft/kiota-abstractions-go"
 	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
+	msgraphcore "github.com/microsoftgraph/msgraph-sdk-go-core"
+	msgraphauth "github.com/microsoftgraph/msgraph-sdk-go-core/authentication"
+	"github.com/microsoftgraph/msgraph-sdk-go/models"
 	odataerrors "github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
+	graphusers "github.com/microsoftgraph/msgraph-sdk-go/users"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
 	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/metrics"
+	"github.com/StatCan/namespace-cleaner/internal/tracing"
 )
 
 // Make client creation functions mockable
 var (
-	NewGraphClient = newGraphClient
-	NewKubeClient  = newKubeClient
+	NewGraphClient   = newGraphClient
+	NewKubeClient    = newKubeClient
+	NewDynamicClient = newDynamicClient
 )
 
 // UserExists is a function variable to check if a user exists in Azure AD.
@@ -30,11 +43,44 @@ var (
 // without introducing interfaces or rewriting the call sites.
 //
 // Example test override:
-//     clients.UserExists = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) bool {
-//         return true // or false depending on the test case
+//     clients.UserExists = func(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (bool, error) {
+//         return true, nil // or false depending on the test case
 //     }
 var UserExists = defaultUserExists
 
+// FindUser looks a user up in Azure AD and returns the strategy that
+// found them, one of the Lookup* values, or "" when none did. Like
+// UserExists it is a function variable so tests can replace it.
+var FindUser = defaultFindUser
+
+// Strategies FindUser finds users with
+const (
+	// LookupUPN reads the user whose user principal name is the email
+	LookupUPN = "upn"
+	// LookupFilter searches the mail, otherMails and proxyAddresses of
+	// users, for B2B guests whose UPN is not their email
+	LookupFilter = "filter"
+	// LookupTestUsers matches TEST_USERS in test mode
+	LookupTestUsers = "test-users"
+)
+
+// UserManager looks up the email of a user's manager in Azure AD. Like
+// UserExists it is a function variable so tests can replace it.
+var UserManager = defaultUserManager
+
+// UserRecord reads a user's directory record from Azure AD, or nil when
+// the user does not exist. Like UserExists it can be replaced in tests.
+var UserRecord = defaultUserRecord
+
+// User is the part of a directory record policy expressions can use
+type User struct {
+	DisplayName    string
+	Mail           string
+	Department     string
+	JobTitle       string
+	AccountEnabled bool
+}
+
 func newGraphClient(cfg *config.Config) *msgraphsdk.GraphServiceClient {
 	if cfg.TestMode {
 		return &msgraphsdk.GraphServiceClient{}
@@ -47,62 +93,232 @@ func newGraphClient(cfg *config.Config) *msgraphsdk.GraphServiceClient {
 		nil,
 	)
 	if err != nil {
-		log.Fatalf("Graph auth failed: %v", err)
+		logging.Fatal("Graph auth failed", logging.KeyError, err)
 	}
 
-	client, err := msgraphsdk.NewGraphServiceClientWithCredentials(
+	auth, err := msgraphauth.NewAzureIdentityAuthenticationProviderWithScopes(
 		cred,
 		[]string{"https://graph.microsoft.com/.default"},
 	)
 	if err != nil {
-		log.Fatalf("Graph client creation failed: %v", err)
+		logging.Fatal("Graph client creation failed", logging.KeyError, err)
+	}
+
+	// Trace Graph requests and pass the trace context on to Graph
+	options := msgraphsdk.GetDefaultClientOptions()
+	httpClient := msgraphcore.GetDefaultClient(&options)
+	httpClient.Transport = tracing.Transport(httpClient.Transport)
+
+	adapter, err := msgraphsdk.NewGraphRequestAdapterWithParseNodeFactoryAndSerializationWriterFactoryAndHttpClient(
+		auth, nil, nil, httpClient,
+	)
+	if err != nil {
+		logging.Fatal("Graph client creation failed", logging.KeyError, err)
 	}
-	return client
+	return msgraphsdk.NewGraphServiceClient(adapter)
 }
 
 func newKubeClient() kubernetes.Interface {
-	if cfg, err := rest.InClusterConfig(); err == nil {
-		kubeClient, err := kubernetes.NewForConfig(cfg)
-		if err != nil {
-			log.Fatalf("Failed to create Kubernetes client: %v", err)
-		}
-		return kubeClient
+	kubeClient, err := kubernetes.NewForConfig(restConfig())
+	if err != nil {
+		logging.Fatal("Failed to create Kubernetes client", logging.KeyError, err)
+	}
+	return kubeClient
+}
+
+func newDynamicClient() dynamic.Interface {
+	dynamicClient, err := dynamic.NewForConfig(restConfig())
+	if err != nil {
+		logging.Fatal("Failed to create dynamic Kubernetes client", logging.KeyError, err)
 	}
+	return dynamicClient
+}
 
-	if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
-		kubeClient, err := kubernetes.NewForConfig(&rest.Config{
+// restConfig resolves the in-cluster or out-of-cluster API server config.
+// Every API request is traced.
+func restConfig() *rest.Config {
+	var cfg *rest.Config
+	if inCluster, err := rest.InClusterConfig(); err == nil {
+		cfg = inCluster
+	} else if kubeconfig := os.Getenv("KUBECONFIG"); kubeconfig != "" {
+		cfg = &rest.Config{
 			Host: "http://localhost:8080",
-		})
-		if err != nil {
-			log.Fatalf("Failed to create out-of-cluster Kubernetes client: %v", err)
 		}
-		return kubeClient
+	} else {
+		logging.Fatal("No valid Kubernetes config found")
+		return nil
 	}
 
-	log.Fatal("No valid Kubernetes config found")
-	return nil
+	cfg.Wrap(tracing.Transport)
+	return cfg
+}
+
+// defaultUserExists checks if a user exists in Azure AD. Errors other than
+// "not found" are returned so a failed lookup is never taken as a missing user.
+func defaultUserExists(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (bool, error) {
+	strategy, err := FindUser(ctx, cfg, client, email)
+	return strategy != "", err
 }
 
-// defaultUserExists checks if a user exists in Azure AD
-func defaultUserExists(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) bool {
+// defaultFindUser reads the user by UPN and, when that finds no one,
+// searches their email addresses
+func defaultFindUser(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (string, error) {
 	if cfg.TestMode {
 		for _, u := range cfg.TestUsers {
-			if u == email {
-				return true
+			if strings.EqualFold(strings.TrimSpace(u), email) {
+				return LookupTestUsers, nil
 			}
 		}
-		return false
+		return "", nil
+	}
+
+	ctx, span := tracing.Start(ctx, "graph.UserExists", attribute.String("owner", email))
+	_, strategy, err := resolveUser(ctx, client, email, []string{"id"})
+	if err != nil {
+		tracing.End(span, err)
+		return "", fmt.Errorf("checking user %s: %w", email, err)
+	}
+	span.SetAttributes(attribute.Bool("exists", strategy != ""), attribute.String("lookup.strategy", strategy))
+	tracing.End(span, nil)
+	return strategy, nil
+}
+
+// defaultUserManager returns the manager's email, or "" when there is none
+func defaultUserManager(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) string {
+	if cfg.TestMode {
+		return ""
+	}
+
+	ctx, span := tracing.Start(ctx, "graph.UserManager", attribute.String("owner", email))
+	// Guests are not found by UPN, so the manager is read by the id of
+	// the user the email resolves to
+	user, _, err := resolveUser(ctx, client, email, []string{"id"})
+	if err != nil || user == nil || user.GetId() == nil {
+		tracing.End(span, err)
+		if err != nil {
+			slog.Warn("Error looking up manager", logging.KeyOwner, email, logging.KeyError, err)
+		}
+		return ""
 	}
 
-	_, err := client.Users().ByUserId(email).Get(ctx, nil)
+	start := time.Now()
+	manager, err := client.Users().ByUserId(*user.GetId()).Manager().Get(ctx, nil)
 	if err != nil {
 		if isNotFoundError(err) {
-			return false
+			metrics.ObserveGraph("manager", start, nil)
+			tracing.End(span, nil)
+		} else {
+			metrics.ObserveGraph("manager", start, err)
+			tracing.End(span, err)
+			slog.Warn("Error looking up manager", logging.KeyOwner, email, logging.KeyError, err)
 		}
-		log.Printf("Error checking user %s: %v", email, err)
-		return false
+		return ""
 	}
-	return true
+	metrics.ObserveGraph("manager", start, nil)
+	tracing.End(span, nil)
+
+	if user, ok := manager.(models.Userable); ok {
+		if mail := user.GetMail(); mail != nil {
+			return *mail
+		}
+	}
+	return ""
+}
+
+// defaultUserRecord reads a user's record. In test mode users in
+// TestUsers have an enabled account and nothing else.
+func defaultUserRecord(ctx context.Context, cfg *config.Config, client *msgraphsdk.GraphServiceClient, email string) (*User, error) {
+	if cfg.TestMode {
+		for _, u := range cfg.TestUsers {
+			if strings.EqualFold(strings.TrimSpace(u), email) {
+				return &User{Mail: email, AccountEnabled: true}, nil
+			}
+		}
+		return nil, nil
+	}
+
+	ctx, span := tracing.Start(ctx, "graph.UserRecord", attribute.String("owner", email))
+	user, strategy, err := resolveUser(ctx, client, email, []string{"displayName", "mail", "department", "jobTitle", "accountEnabled"})
+	if err != nil {
+		tracing.End(span, err)
+		return nil, fmt.Errorf("reading user %s: %w", email, err)
+	}
+	span.SetAttributes(attribute.String("lookup.strategy", strategy))
+	tracing.End(span, nil)
+	if user == nil {
+		return nil, nil
+	}
+
+	record := &User{}
+	if v := user.GetDisplayName(); v != nil {
+		record.DisplayName = *v
+	}
+	if v := user.GetMail(); v != nil {
+		record.Mail = *v
+	}
+	if v := user.GetDepartment(); v != nil {
+		record.Department = *v
+	}
+	if v := user.GetJobTitle(); v != nil {
+		record.JobTitle = *v
+	}
+	if v := user.GetAccountEnabled(); v != nil {
+		record.AccountEnabled = *v
+	}
+	return record, nil
+}
+
+// resolveUser reads a user by UPN. B2B guests have a UPN like
+// first_dept.gc.ca#EXT#@tenant.onmicrosoft.com, so when that finds no one
+// the user is searched by mail, otherMails and proxyAddresses instead. It
+// returns the user and the strategy that found them, or nil and "" when
+// neither did.
+func resolveUser(ctx context.Context, client *msgraphsdk.GraphServiceClient, email string, fields []string) (models.Userable, string, error) {
+	start := time.Now()
+	user, err := client.Users().ByUserId(email).Get(ctx, &graphusers.UserItemRequestBuilderGetRequestConfiguration{
+		QueryParameters: &graphusers.UserItemRequestBuilderGetQueryParameters{Select: fields},
+	})
+	if err == nil {
+		metrics.ObserveGraph("user", start, nil)
+		return user, LookupUPN, nil
+	}
+	if !isNotFoundError(err) {
+		metrics.ObserveGraph("user", start, err)
+		return nil, "", err
+	}
+	metrics.ObserveGraph("user", start, nil)
+
+	// Filtering on otherMails and proxyAddresses is an advanced query,
+	// which needs eventual consistency and a count
+	headers := abstractions.NewRequestHeaders()
+	headers.Add("ConsistencyLevel", "eventual")
+	filter := emailFilter(email)
+	count, top := true, int32(1)
+	start = time.Now()
+	users, err := client.Users().Get(ctx, &graphusers.UsersRequestBuilderGetRequestConfiguration{
+		Headers: headers,
+		QueryParameters: &graphusers.UsersRequestBuilderGetQueryParameters{
+			Filter: &filter,
+			Select: fields,
+			Count:  &count,
+			Top:    &top,
+		},
+	})
+	metrics.ObserveGraph("user_filter", start, err)
+	if err != nil {
+		return nil, "", err
+	}
+	if found := users.GetValue(); len(found) > 0 {
+		return found[0], LookupFilter, nil
+	}
+	return nil, "", nil
+}
+
+// emailFilter matches users holding an email in mail, otherMails or
+// proxyAddresses, where it appears as smtp:email
+func emailFilter(email string) string {
+	quoted := strings.ReplaceAll(email, "'", "''")
+	return fmt.Sprintf("mail eq '%[1]s' or otherMails/any(m:m eq '%[1]s') or proxyAddresses/any(p:p eq 'smtp:%[1]s')", quoted)
 }
 
 // isNotFoundError checks if an error is a "not found" error
@@ -116,19 +332,3 @@ func isNotFoundError(err error) bool {
 	}
 	return strings.Contains(err.Error(), "does not exist")
 }
-
-// ValidDomain checks if an email domain is allowed
-func ValidDomain(email string, domains []string) bool {
-	parts := strings.Split(email, "@")
-	if len(parts) != 2 {
-		return false
-	}
-
-	domain := parts[1]
-	for _, allowed := range domains {
-		if domain == allowed || strings.HasSuffix(domain, "."+allowed) {
-			return true
-		}
-	}
-	return false
-}
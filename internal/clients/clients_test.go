@@ -1,10 +1,16 @@
 package clients
 
 import (
+	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/microsoft/kiota-abstractions-go/authentication"
+	msgraphsdk "github.com/microsoftgraph/msgraph-sdk-go"
 	"github.com/microsoftgraph/msgraph-sdk-go/models/odataerrors"
 )
 
@@ -19,6 +25,12 @@ func TestValidDomain(t *testing.T) {
 		{"user@example.org", []string{"example.com"}, false},
 		{"invalid-email", []string{"example.com"}, false},
 		{"user@example.com", []string{"example.org", "example.com"}, true},
+		{"User@StatCan.GC.CA", []string{"statcan.gc.ca"}, true},
+		{"user@cloud.statcan.gc.ca", []string{"StatCan.gc.ca"}, true},
+		{"user@bücher.example", []string{"xn--bcher-kva.example"}, true},
+		{"user@xn--bcher-kva.example", []string{"bücher.example"}, true},
+		{"a@b@example.com", []string{"example.com"}, false},
+		{"user@notexample.com", []string{"example.com"}, false},
 	}
 
 	for _, tc := range testCases {
@@ -30,6 +42,16 @@ func TestValidDomain(t *testing.T) {
 	}
 }
 
+func TestMatchDomain(t *testing.T) {
+	domains := []string{"example.org", "example.com"}
+	if rule, ok := MatchDomain("user@sub.example.com", domains); !ok || rule != "example.com" {
+		t.Errorf("Expected example.com to match, got %q (%v)", rule, ok)
+	}
+	if rule, ok := MatchDomain("user@example.net", domains); ok || rule != "" {
+		t.Errorf("Expected no match, got %q", rule)
+	}
+}
+
 func TestUserExistsTestMode(t *testing.T) {
 	cfg := &config.Config{
 		TestMode:  true,
@@ -37,12 +59,12 @@ func TestUserExistsTestMode(t *testing.T) {
 	}
 
 	// Test existing user
-	if !UserExists(nil, cfg, nil, "test@example.com") {
+	if exists, err := UserExists(nil, cfg, nil, "test@example.com"); err != nil || !exists {
 		t.Error("User should exist in test mode")
 	}
 
 	// Test non-existing user
-	if UserExists(nil, cfg, nil, "missing@example.com") {
+	if exists, err := UserExists(nil, cfg, nil, "missing@example.com"); err != nil || exists {
 		t.Error("User should not exist in test mode")
 	}
 }
@@ -73,3 +95,141 @@ func TestIsNotFoundError(t *testing.T) {
 func ptr(s string) *string {
 	return &s
 }
+
+func TestUserManagerTestMode(t *testing.T) {
+	cfg := &config.Config{TestMode: true}
+
+	if got := UserManager(nil, cfg, nil, "test@example.com"); got != "" {
+		t.Errorf("Expected no manager in test mode, got %q", got)
+	}
+}
+
+func TestUserRecordTestMode(t *testing.T) {
+	cfg := &config.Config{
+		TestMode:  true,
+		TestUsers: []string{"test@example.com"},
+	}
+
+	if record, err := UserRecord(nil, cfg, nil, "test@example.com"); err != nil || record == nil || !record.AccountEnabled {
+		t.Errorf("Expected an enabled record in test mode, got %+v, %v", record, err)
+	}
+	if record, err := UserRecord(nil, cfg, nil, "missing@example.com"); err != nil || record != nil {
+		t.Errorf("Expected no record for a missing user, got %+v, %v", record, err)
+	}
+}
+
+// newTestGraph serves the users of a tenant with a B2B guest: a member
+// found by UPN, and a guest only found by their email addresses
+func newTestGraph(t *testing.T) *msgraphsdk.GraphServiceClient {
+	notFound := `{"error":{"code":"Request_ResourceNotFound","message":"Resource does not exist."}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/users/1/manager":
+			w.Write([]byte(`{"@odata.type":"#microsoft.graph.user","id":"10","mail":"boss@statcan.gc.ca"}`))
+		case r.URL.Path == "/users/2/manager":
+			w.Write([]byte(`{"@odata.type":"#microsoft.graph.user","id":"20","mail":"sponsor@statcan.gc.ca"}`))
+		case r.URL.Path == "/users/member@statcan.gc.ca":
+			w.Write([]byte(`{"id":"1","mail":"member@statcan.gc.ca","accountEnabled":true}`))
+		case r.URL.Path == "/users/broken@statcan.gc.ca":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":{"code":"InternalServerError","message":"Something went wrong."}}`))
+		case strings.HasPrefix(r.URL.Path, "/users/"):
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(notFound))
+		case r.URL.Path == "/users":
+			filter := r.URL.Query().Get("$filter")
+			if r.Header.Get("ConsistencyLevel") != "eventual" || r.URL.Query().Get("$count") != "true" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"code":"Request_UnsupportedQuery","message":"Advanced query needed."}}`))
+			} else if strings.Contains(filter, "smtp:guest@dept.gc.ca") && strings.Contains(filter, "otherMails/any") {
+				w.Write([]byte(`{"value":[{"id":"2","mail":"guest@dept.gc.ca","department":"Partner","accountEnabled":true}]}`))
+			} else {
+				w.Write([]byte(`{"value":[]}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(notFound))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	adapter, err := msgraphsdk.NewGraphRequestAdapter(&authentication.AnonymousAuthenticationProvider{})
+	if err != nil {
+		t.Fatalf("Creating the request adapter failed: %v", err)
+	}
+	adapter.SetBaseUrl(server.URL)
+	return msgraphsdk.NewGraphServiceClient(adapter)
+}
+
+func TestFindUser(t *testing.T) {
+	client := newTestGraph(t)
+	cfg := &config.Config{}
+
+	testCases := []struct {
+		email        string
+		wantStrategy string
+		wantErr      bool
+	}{
+		{"member@statcan.gc.ca", LookupUPN, false},
+		{"guest@dept.gc.ca", LookupFilter, false},
+		{"gone@statcan.gc.ca", "", false},
+		{"broken@statcan.gc.ca", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.email, func(t *testing.T) {
+			strategy, err := FindUser(context.TODO(), cfg, client, tc.email)
+			if strategy != tc.wantStrategy || (err != nil) != tc.wantErr {
+				t.Errorf("Expected %q (error %v), got %q, %v", tc.wantStrategy, tc.wantErr, strategy, err)
+			}
+			if exists, err := UserExists(context.TODO(), cfg, client, tc.email); exists != (tc.wantStrategy != "") || (err != nil) != tc.wantErr {
+				t.Errorf("Unexpected UserExists result %v, %v", exists, err)
+			}
+		})
+	}
+}
+
+func TestUserManagerGuest(t *testing.T) {
+	client := newTestGraph(t)
+	cfg := &config.Config{}
+
+	testCases := []struct {
+		email string
+		want  string
+	}{
+		{"member@statcan.gc.ca", "boss@statcan.gc.ca"},
+		{"guest@dept.gc.ca", "sponsor@statcan.gc.ca"},
+		{"gone@statcan.gc.ca", ""},
+		{"broken@statcan.gc.ca", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.email, func(t *testing.T) {
+			if got := UserManager(context.TODO(), cfg, client, tc.email); got != tc.want {
+				t.Errorf("Expected manager %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestUserRecordGuest(t *testing.T) {
+	record, err := UserRecord(context.TODO(), &config.Config{}, newTestGraph(t), "guest@dept.gc.ca")
+	if err != nil || record == nil || record.Department != "Partner" || !record.AccountEnabled {
+		t.Errorf("Expected the guest's record, got %+v, %v", record, err)
+	}
+}
+
+func TestFindUserTestMode(t *testing.T) {
+	cfg := &config.Config{TestMode: true, TestUsers: []string{"Test@example.com"}}
+	if strategy, err := FindUser(nil, cfg, nil, "test@example.com"); err != nil || strategy != LookupTestUsers {
+		t.Errorf("Expected a test user, got %q, %v", strategy, err)
+	}
+}
+
+func TestEmailFilter(t *testing.T) {
+	want := "mail eq 'o''neil@dept.gc.ca' or otherMails/any(m:m eq 'o''neil@dept.gc.ca') or proxyAddresses/any(p:p eq 'smtp:o''neil@dept.gc.ca')"
+	if got := emailFilter("o'neil@dept.gc.ca"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,137 @@
+package clients
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/owner"
+)
+
+// DomainMatch is the owner domain rule an email falls under
+type DomainMatch struct {
+	// Pattern is the rule as configured
+	Pattern string
+	// Action is one of the config.DomainAction* values
+	Action string
+	// Source names the setting the rule comes from
+	Source string
+}
+
+// Allowed reports whether owners under the rule are accepted
+func (m DomainMatch) Allowed() bool {
+	return m.Action != "" && m.Action != config.DomainActionDeny
+}
+
+// String describes the rule for checks and messages
+func (m DomainMatch) String() string {
+	return m.Pattern + " (" + m.Action + ", " + m.Source + ")"
+}
+
+// ValidDomain checks if an email domain is allowed
+func ValidDomain(email string, domains []string) bool {
+	_, ok := MatchDomain(email, domains)
+	return ok
+}
+
+// MatchDomain returns the allowed domain an email falls under. Domains are
+// compared case-insensitively, with internationalized domains in their
+// ASCII form. A plain domain also matches its subdomains, and a pattern
+// holding * or ? is matched as a glob.
+func MatchDomain(email string, domains []string) (string, bool) {
+	domain, ok := emailDomain(email)
+	if !ok {
+		return "", false
+	}
+	for _, allowed := range domains {
+		if matchPattern(domain, allowed, false) {
+			return allowed, true
+		}
+	}
+	return "", false
+}
+
+// MatchOwnerDomain matches an owner email against the configured domain
+// rules. A rule from DENIED_DOMAINS, or a DOMAIN_ACTIONS rule denying the
+// domain, always wins. Otherwise the longest matching pattern of
+// DOMAIN_ACTIONS and ALLOWED_DOMAINS does, DOMAIN_ACTIONS first on a tie.
+// With DOMAIN_MATCH set to exact, plain domains no longer match their
+// subdomains. The second result is false when no rule matches.
+func MatchOwnerDomain(email string, cfg *config.Config) (DomainMatch, bool) {
+	domain, ok := emailDomain(email)
+	if !ok {
+		return DomainMatch{}, false
+	}
+	exact := cfg.DomainMatch == config.DomainMatchExact
+
+	var best DomainMatch
+	for _, rule := range domainRules(cfg) {
+		if !matchPattern(domain, rule.Pattern, exact) {
+			continue
+		}
+		if rule.Action == config.DomainActionDeny {
+			return rule, true
+		}
+		if best.Action == "" || len(rule.Pattern) > len(best.Pattern) {
+			best = rule
+		}
+	}
+	return best, best.Action != ""
+}
+
+// domainRules lists the owner domain rules, denied ones first. An unknown
+// action denies the domain, so a typo never lets a namespace be deleted.
+func domainRules(cfg *config.Config) []DomainMatch {
+	var rules []DomainMatch
+	for _, pattern := range cfg.DeniedDomains {
+		rules = append(rules, DomainMatch{Pattern: pattern, Action: config.DomainActionDeny, Source: "DENIED_DOMAINS"})
+	}
+
+	patterns := make([]string, 0, len(cfg.DomainActions))
+	for pattern := range cfg.DomainActions {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		action := strings.ToLower(cfg.DomainActions[pattern])
+		switch action {
+		case config.DomainActionAllow, config.DomainActionDeny, config.DomainActionKeep, config.DomainActionExpire:
+		default:
+			action = config.DomainActionDeny
+		}
+		rules = append(rules, DomainMatch{Pattern: pattern, Action: action, Source: "DOMAIN_ACTIONS"})
+	}
+
+	for _, pattern := range cfg.AllowedDomains {
+		rules = append(rules, DomainMatch{Pattern: pattern, Action: config.DomainActionAllow, Source: "ALLOWED_DOMAINS"})
+	}
+	return rules
+}
+
+// emailDomain returns the normalized domain of an email with exactly one @
+func emailDomain(email string) (string, bool) {
+	if strings.Count(email, "@") != 1 {
+		return "", false
+	}
+	domain, err := owner.Domain(email[strings.Index(email, "@")+1:])
+	if err != nil {
+		return "", false
+	}
+	return domain, true
+}
+
+// matchPattern reports whether a normalized domain matches a pattern. Glob
+// patterns are matched as is; plain domains also match their subdomains
+// unless exact is set.
+func matchPattern(domain, pattern string, exact bool) bool {
+	pattern, err := owner.Pattern(pattern)
+	if err != nil {
+		return false
+	}
+	if strings.ContainsAny(pattern, "*?") {
+		matched, err := path.Match(pattern, domain)
+		return err == nil && matched
+	}
+	return domain == pattern || (!exact && strings.HasSuffix(domain, "."+pattern))
+}
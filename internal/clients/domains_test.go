@@ -0,0 +1,71 @@
+package clients
+
+import (
+	"testing"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestMatchOwnerDomain(t *testing.T) {
+	cfg := &config.Config{
+		AllowedDomains: []string{"statcan.gc.ca", "*.cloud.statcan.ca"},
+		DeniedDomains:  []string{"external.statcan.gc.ca", "*.guest.cloud.statcan.ca"},
+		DomainActions: map[string]string{
+			"svc.cloud.statcan.ca": config.DomainActionKeep,
+			"old.statcan.gc.ca":    config.DomainActionExpire,
+			"typo.statcan.gc.ca":   "delete",
+			"partner.gc.ca":        config.DomainActionAllow,
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		email       string
+		match       string
+		wantPattern string
+		wantAction  string
+	}{
+		{"allowed domain", "user@statcan.gc.ca", config.DomainMatchSuffix, "statcan.gc.ca", config.DomainActionAllow},
+		{"allowed subdomain", "user@Team.StatCan.gc.ca", config.DomainMatchSuffix, "statcan.gc.ca", config.DomainActionAllow},
+		{"denied subdomain", "user@external.statcan.gc.ca", config.DomainMatchSuffix, "external.statcan.gc.ca", config.DomainActionDeny},
+		{"deny below denied subdomain", "user@a.external.statcan.gc.ca", config.DomainMatchSuffix, "external.statcan.gc.ca", config.DomainActionDeny},
+		{"glob", "user@team.cloud.statcan.ca", config.DomainMatchSuffix, "*.cloud.statcan.ca", config.DomainActionAllow},
+		{"glob skips the bare domain", "user@cloud.statcan.ca", config.DomainMatchSuffix, "", ""},
+		{"denied glob beats allowed glob", "user@x.guest.cloud.statcan.ca", config.DomainMatchSuffix, "*.guest.cloud.statcan.ca", config.DomainActionDeny},
+		{"longest pattern wins", "user@svc.cloud.statcan.ca", config.DomainMatchSuffix, "svc.cloud.statcan.ca", config.DomainActionKeep},
+		{"expire action", "user@old.statcan.gc.ca", config.DomainMatchSuffix, "old.statcan.gc.ca", config.DomainActionExpire},
+		{"unknown action denies", "user@typo.statcan.gc.ca", config.DomainMatchSuffix, "typo.statcan.gc.ca", config.DomainActionDeny},
+		{"exact keeps the domain", "user@statcan.gc.ca", config.DomainMatchExact, "statcan.gc.ca", config.DomainActionAllow},
+		{"exact drops subdomains", "user@team.statcan.gc.ca", config.DomainMatchExact, "", ""},
+		{"exact keeps globs", "user@team.cloud.statcan.ca", config.DomainMatchExact, "*.cloud.statcan.ca", config.DomainActionAllow},
+		{"exact still denies", "user@external.statcan.gc.ca", config.DomainMatchExact, "external.statcan.gc.ca", config.DomainActionDeny},
+		{"no rule", "user@example.com", config.DomainMatchSuffix, "", ""},
+		{"malformed email", "a@b@statcan.gc.ca", config.DomainMatchSuffix, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg.DomainMatch = tc.match
+			m, ok := MatchOwnerDomain(tc.email, cfg)
+			if ok != (tc.wantAction != "") || m.Pattern != tc.wantPattern || m.Action != tc.wantAction {
+				t.Errorf("Expected %q (%s), got %q (%s, %v)", tc.wantPattern, tc.wantAction, m.Pattern, m.Action, ok)
+			}
+			if m.Allowed() != (tc.wantAction != "" && tc.wantAction != config.DomainActionDeny) {
+				t.Errorf("Unexpected Allowed() for %s", m.Action)
+			}
+		})
+	}
+}
+
+func TestMatchDomainGlob(t *testing.T) {
+	domains := []string{"*.Cloud.StatCan.ca", "dept-?.gc.ca"}
+	if rule, ok := MatchDomain("user@team.cloud.statcan.ca", domains); !ok || rule != "*.Cloud.StatCan.ca" {
+		t.Errorf("Expected the glob to match, got %q (%v)", rule, ok)
+	}
+	if rule, ok := MatchDomain("user@dept-a.gc.ca", domains); !ok || rule != "dept-?.gc.ca" {
+		t.Errorf("Expected the ? glob to match, got %q (%v)", rule, ok)
+	}
+	if _, ok := MatchDomain("user@dept-ab.gc.ca", domains); ok {
+		t.Error("Expected ? to match a single character")
+	}
+}
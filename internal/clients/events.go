@@ -0,0 +1,94 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+)
+
+// eventComponent is the source reported on recorded events
+const eventComponent = "namespace-cleaner"
+
+// NewEventRecorder creates the recorder for cleaner decisions. Like the
+// client constructors it is a variable so tests can replace it.
+var NewEventRecorder = newEventRecorder
+
+// eventRecorder writes each event to the API server before returning. The
+// client-go broadcaster sends events from a background goroutine, which can
+// lose the last ones when the Job exits right after a run.
+type eventRecorder struct {
+	kubeClient kubernetes.Interface
+	source     corev1.EventSource
+}
+
+func newEventRecorder(kubeClient kubernetes.Interface) record.EventRecorder {
+	return &eventRecorder{
+		kubeClient: kubeClient,
+		source:     corev1.EventSource{Component: eventComponent},
+	}
+}
+
+// Event records an event with a plain message
+func (r *eventRecorder) Event(obj runtime.Object, eventtype, reason, message string) {
+	r.AnnotatedEventf(obj, nil, eventtype, reason, "%s", message)
+}
+
+// Eventf records an event with a formatted message
+func (r *eventRecorder) Eventf(obj runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.AnnotatedEventf(obj, nil, eventtype, reason, messageFmt, args...)
+}
+
+// AnnotatedEventf records an event with annotations and a formatted message.
+// Failures are logged, never returned, so events cannot block a run.
+func (r *eventRecorder) AnnotatedEventf(
+	obj runtime.Object,
+	annotations map[string]string,
+	eventtype, reason, messageFmt string,
+	args ...interface{},
+) {
+	ref, err := reference.GetReference(scheme.Scheme, obj)
+	if err != nil {
+		slog.Error("Error referencing object for event", "object", fmt.Sprintf("%T", obj), logging.KeyReason, reason, logging.KeyError, err)
+		return
+	}
+
+	// Events about cluster-scoped objects such as Namespaces live in default
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+
+	now := metav1.NewTime(time.Now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s.%x", ref.Name, now.UnixNano()),
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		InvolvedObject:      *ref,
+		Reason:              reason,
+		Message:             fmt.Sprintf(messageFmt, args...),
+		Type:                eventtype,
+		Source:              r.source,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: eventComponent,
+	}
+
+	_, err = r.kubeClient.CoreV1().Events(namespace).Create(context.Background(), event, metav1.CreateOptions{})
+	if err != nil {
+		slog.Error("Error recording event", logging.KeyNamespace, ref.Name, logging.KeyReason, reason, logging.KeyError, err)
+	}
+}
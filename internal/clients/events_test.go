@@ -0,0 +1,38 @@
+package clients
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEventRecorder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder := NewEventRecorder(client)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns", UID: "1234"}}
+	recorder.Eventf(ns, corev1.EventTypeWarning, "MarkedForDeletion", "owner %s gone", "user@example.com")
+
+	// Events are written synchronously into the default namespace
+	events, err := client.CoreV1().Events(metav1.NamespaceDefault).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events.Items))
+	}
+
+	event := events.Items[0]
+	if event.InvolvedObject.Kind != "Namespace" || event.InvolvedObject.Name != "test-ns" {
+		t.Errorf("Unexpected involved object %+v", event.InvolvedObject)
+	}
+	if event.Reason != "MarkedForDeletion" || event.Message != "owner user@example.com gone" {
+		t.Errorf("Unexpected event %s: %s", event.Reason, event.Message)
+	}
+	if event.Source.Component != "namespace-cleaner" {
+		t.Errorf("Unexpected source %q", event.Source.Component)
+	}
+}
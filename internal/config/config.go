@@ -4,6 +4,117 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
+)
+
+// Supported values for Config.DeletionMode
+const (
+	DeletionModeNamespace = "namespace"
+	DeletionModeProfile   = "profile"
+)
+
+// Supported values for Config.DeletionPropagation
+const (
+	PropagationBackground = "background"
+	PropagationForeground = "foreground"
+)
+
+// Supported values for Config.DomainMatch
+const (
+	DomainMatchSuffix = "suffix"
+	DomainMatchExact  = "exact"
+)
+
+// Supported values for Config.DomainActions
+const (
+	DomainActionAllow  = "allow"
+	DomainActionDeny   = "deny"
+	DomainActionKeep   = "keep"
+	DomainActionExpire = "expire"
+)
+
+// Supported values for Config.WebhookFormat
+const (
+	WebhookFormatTeams = "teams"
+	WebhookFormatSlack = "slack"
+)
+
+// Supported values for Config.WebhookThreshold
+const (
+	WebhookThresholdAlways    = "always"
+	WebhookThresholdDeletions = "deletions"
+	WebhookThresholdErrors    = "errors"
+)
+
+// Supported values for Config.RunMode
+const (
+	RunModeCronJob    = "cronjob"
+	RunModeController = "controller"
+)
+
+// Supported values for Config.PolicySource
+const (
+	PolicySourceFile    = "file"
+	PolicySourceCluster = "cluster"
+)
+
+// Supported values for Config.AdmissionMode
+const (
+	AdmissionModeDeny = "deny"
+	AdmissionModeWarn = "warn"
+)
+
+// Supported values for Config.ReportFormat
+const (
+	ReportFormatJSON     = "json"
+	ReportFormatYAML     = "yaml"
+	ReportFormatCSV      = "csv"
+	ReportFormatMarkdown = "markdown"
+)
+
+// Supported values for Config.ReportOutput
+const (
+	ReportOutputStdout    = "stdout"
+	ReportOutputFile      = "file"
+	ReportOutputConfigMap = "configmap"
+	ReportOutputNone      = "none"
+)
+
+// Supported values for Config.LogFormat
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Supported values for Config.TransferSources, tried in the given order
+const (
+	TransferSourceManager     = "manager"
+	TransferSourceContributor = "contributor"
+	TransferSourceSteward     = "steward"
+)
+
+// Supported values for Config.OrphanOwnerSources, tried in the given order
+const (
+	OrphanSourceProfile       = "profile"
+	OrphanSourceRoleBinding   = "rolebinding"
+	OrphanSourceManagedFields = "managedfields"
+)
+
+// Supported values for Config.ActivitySources
+const (
+	ActivitySourcePods      = "pods"
+	ActivitySourceNotebooks = "notebooks"
+	ActivitySourceEvents    = "events"
+	ActivitySourcePVCs      = "pvcs"
+)
+
+// Supported values for Config.AuditSink
+const (
+	AuditSinkNone      = "none"
+	AuditSinkFile      = "file"
+	AuditSinkConfigMap = "configmap"
+	AuditSinkSecret    = "secret"
+	AuditSinkSyslog    = "syslog"
 )
 
 // Config holds application configuration
@@ -16,6 +127,137 @@ type Config struct {
 	AllowedDomains []string
 	TestUsers      []string
 	GracePeriod    int
+
+	// Owner domain rules. DeniedDomains take precedence over everything
+	// else. DomainActions maps further patterns to a DomainAction*, and
+	// DomainMatch decides whether plain domains also match subdomains.
+	DeniedDomains []string
+	DomainActions map[string]string
+	DomainMatch   string
+
+	// OwnerDomainAliases maps old owner domains to the new ones they are
+	// looked up under, such as after a department renames its domain
+	OwnerDomainAliases map[string]string
+
+	// Quarantine settings
+	QuarantineEnabled bool
+	QuarantinePeriod  int
+
+	// MaxExtension caps, in days past the original delete-at date, how far
+	// owners can push deletion back with the extend-until annotation. Zero
+	// turns extensions off.
+	MaxExtension int
+
+	// Ownership transfer settings. When enabled, an expired namespace is
+	// handed to the first successor found through TransferSources instead
+	// of being deleted. TransferStewards maps owner domains to stewards.
+	TransferEnabled  bool
+	TransferSources  []string
+	TransferStewards map[string]string
+
+	// ContributorRoles are the Kubeflow roles that make a contributor
+	// eligible to take over a namespace. With ContributorsAsOwners, a
+	// contributor with one of them also keeps the namespace alive.
+	ContributorRoles     []string
+	ContributorsAsOwners bool
+
+	// Ownerless namespace settings. Namespaces without an owner
+	// annotation get the first owner found through OrphanOwnerSources.
+	// When none is found they are labeled for deletion OrphanGracePeriod
+	// days out and the OrphanNotify admins are told; zero leaves them
+	// alone.
+	OrphanOwnerSources []string
+	OrphanGracePeriod  int
+	OrphanNotify       []string
+
+	// Activity settings. Namespaces of existing owners idle for more than
+	// IdleDays are labeled, and expired namespaces active within the last
+	// ActiveDays are kept. Zero turns either off. ActivitySources are the
+	// signs of use looked at.
+	IdleDays        int
+	ActiveDays      int
+	ActivitySources []string
+
+	// PolicyFile holds ordered rules overriding these settings for the
+	// namespaces they match. Without it every namespace follows them.
+	PolicyFile string
+	// PolicySource selects whether rules come from the policy file alone
+	// or also from NamespaceCleanupPolicy objects in the cluster
+	PolicySource string
+
+	// DeletionMode selects whether the namespace or its owning
+	// Kubeflow Profile is deleted
+	DeletionMode string
+
+	// Deletion strategy settings
+	DeletionPropagation string
+	WaitForDeletion     bool
+	DeletionTimeout     time.Duration
+
+	// Owner notification settings
+	SMTPHost           string
+	SMTPPort           string
+	SMTPFrom           string
+	SMTPUsername       string
+	SMTPPassword       string
+	NotifyEvents       []string
+	NotifyReminderDays []int
+	NotifyManager      bool
+	NotifyTemplateDir  string
+
+	// Admin webhook report settings
+	WebhookURL       string
+	WebhookFormat    string
+	WebhookThreshold string
+	WebhookRetries   int
+	WebhookTemplate  string
+
+	// RunMode selects a single run (cronjob) or repeated runs every
+	// RunInterval (controller)
+	RunMode     string
+	RunInterval time.Duration
+
+	// Admission webhook settings. The webhook is served on AdmissionAddr
+	// in controller mode; an empty address turns it off. Namespaces
+	// matching AdmissionSelector must be created with a valid owner,
+	// otherwise they are denied or admitted with a warning.
+	AdmissionAddr       string
+	AdmissionCertFile   string
+	AdmissionKeyFile    string
+	AdmissionSelector   string
+	AdmissionMode       string
+	AdmissionCheckOwner bool
+	// AdmissionOwnerRules map the username creating a namespace without
+	// an owner to the owner's email, as ordered "regexp=template" rules,
+	// one per line since a regexp may hold commas
+	AdmissionOwnerRules []string
+
+	// Metrics settings
+	MetricsAddr    string
+	PushgatewayURL string
+
+	// TracingEndpoint is the OTLP/HTTP collector URL. Tracing is off when
+	// it is empty.
+	TracingEndpoint string
+
+	// Run report settings. ReportConfigMap is "<namespace>/<name>".
+	ReportFormat    string
+	ReportOutput    string
+	ReportFile      string
+	ReportConfigMap string
+
+	// Logging settings
+	LogLevel  string
+	LogFormat string
+	Verbose   bool
+
+	// Audit trail settings. AuditObject is "<namespace>/<name>".
+	AuditSink       string
+	AuditFile       string
+	AuditObject     string
+	AuditSyslogAddr string
+	AuditMaxBytes   int
+	AuditActor      string
 }
 
 // LoadConfig loads configuration from environment variables
@@ -29,9 +271,101 @@ func LoadConfig() *Config {
 		AllowedDomains: splitEnv("ALLOWED_DOMAINS"),
 		TestUsers:      splitEnv("TEST_USERS"),
 		GracePeriod:    getGracePeriod(),
+
+		DeniedDomains: splitEnv("DENIED_DOMAINS"),
+		DomainActions: getMapEnv("DOMAIN_ACTIONS"),
+		DomainMatch:   getDomainMatch(),
+
+		OwnerDomainAliases: getMapEnv("OWNER_DOMAIN_ALIASES"),
+
+		QuarantineEnabled: getBoolEnv("QUARANTINE_ENABLED", false),
+		QuarantinePeriod:  getIntEnv("QUARANTINE_PERIOD", 14),
+
+		MaxExtension: getIntEnv("MAX_EXTENSION", 90),
+
+		TransferEnabled:  getBoolEnv("TRANSFER_ENABLED", false),
+		TransferSources:  getListEnv("TRANSFER_SOURCES", TransferSourceManager, TransferSourceContributor, TransferSourceSteward),
+		TransferStewards: getMapEnv("TRANSFER_STEWARDS"),
+		ContributorRoles: getListEnv("CONTRIBUTOR_ROLES", "admin", "edit"),
+
+		ContributorsAsOwners: getBoolEnv("CONTRIBUTORS_AS_OWNERS", false),
+
+		OrphanOwnerSources: getListEnv("ORPHAN_OWNER_SOURCES"),
+		OrphanGracePeriod:  getIntEnv("ORPHAN_GRACE_PERIOD", 0),
+		OrphanNotify:       getListEnv("ORPHAN_NOTIFY"),
+
+		IdleDays:   getIntEnv("IDLE_DAYS", 0),
+		ActiveDays: getIntEnv("ACTIVE_DAYS", 0),
+		ActivitySources: getListEnv("ACTIVITY_SOURCES",
+			ActivitySourcePods, ActivitySourceNotebooks, ActivitySourceEvents, ActivitySourcePVCs),
+
+		PolicyFile:   os.Getenv("POLICY_FILE"),
+		PolicySource: getPolicySource(),
+
+		DeletionMode: getDeletionMode(),
+
+		DeletionPropagation: getPropagation(),
+		WaitForDeletion:     getBoolEnv("WAIT_FOR_DELETION", false),
+		DeletionTimeout:     getDurationEnv("DELETION_TIMEOUT", 5*time.Minute),
+
+		SMTPHost:           os.Getenv("SMTP_HOST"),
+		SMTPPort:           getEnv("SMTP_PORT", "25"),
+		SMTPFrom:           os.Getenv("SMTP_FROM"),
+		SMTPUsername:       os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:       os.Getenv("SMTP_PASSWORD"),
+		NotifyEvents:       splitEnv("NOTIFY_EVENTS"),
+		NotifyReminderDays: getDaysListEnv("NOTIFY_REMINDER_DAYS"),
+		NotifyManager:      getBoolEnv("NOTIFY_MANAGER", false),
+		NotifyTemplateDir:  os.Getenv("NOTIFY_TEMPLATE_DIR"),
+
+		WebhookURL:       os.Getenv("WEBHOOK_URL"),
+		WebhookFormat:    strings.ToLower(getEnv("WEBHOOK_FORMAT", WebhookFormatTeams)),
+		WebhookThreshold: strings.ToLower(getEnv("WEBHOOK_THRESHOLD", WebhookThresholdAlways)),
+		WebhookRetries:   getIntEnv("WEBHOOK_RETRIES", 3),
+		WebhookTemplate:  os.Getenv("WEBHOOK_TEMPLATE"),
+
+		RunMode:     getRunMode(),
+		RunInterval: getDurationEnv("RUN_INTERVAL", time.Hour),
+
+		AdmissionAddr:       os.Getenv("ADMISSION_ADDR"),
+		AdmissionCertFile:   getEnv("ADMISSION_TLS_CERT", "/etc/namespace-cleaner/tls/tls.crt"),
+		AdmissionKeyFile:    getEnv("ADMISSION_TLS_KEY", "/etc/namespace-cleaner/tls/tls.key"),
+		AdmissionSelector:   getEnv("ADMISSION_SELECTOR", "app.kubeflow.org/part-of=kubeflow-profile"),
+		AdmissionMode:       strings.ToLower(getEnv("ADMISSION_MODE", AdmissionModeWarn)),
+		AdmissionCheckOwner: getBoolEnv("ADMISSION_CHECK_OWNER", false),
+		AdmissionOwnerRules: splitLinesEnv("ADMISSION_OWNER_RULES"),
+
+		MetricsAddr:    getEnv("METRICS_ADDR", ":9090"),
+		PushgatewayURL: os.Getenv("PUSHGATEWAY_URL"),
+
+		TracingEndpoint: os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"),
+
+		ReportFormat:    strings.ToLower(getEnv("REPORT_FORMAT", ReportFormatJSON)),
+		ReportOutput:    strings.ToLower(getEnv("REPORT_OUTPUT", ReportOutputStdout)),
+		ReportFile:      getEnv("REPORT_FILE", "namespace-cleaner-report"),
+		ReportConfigMap: getEnv("REPORT_CONFIGMAP", "das/namespace-cleaner-report"),
+
+		LogLevel:  strings.ToLower(getEnv("LOG_LEVEL", "info")),
+		LogFormat: strings.ToLower(getEnv("LOG_FORMAT", LogFormatText)),
+		Verbose:   getBoolEnv("VERBOSE", false),
+
+		AuditSink:       strings.ToLower(getEnv("AUDIT_SINK", AuditSinkNone)),
+		AuditFile:       getEnv("AUDIT_FILE", "namespace-cleaner-audit.jsonl"),
+		AuditObject:     getEnv("AUDIT_OBJECT", "das/namespace-cleaner-audit"),
+		AuditSyslogAddr: os.Getenv("AUDIT_SYSLOG_ADDR"),
+		AuditMaxBytes:   getIntEnv("AUDIT_MAX_BYTES", 900000),
+		AuditActor:      os.Getenv("AUDIT_ACTOR"),
 	}
 }
 
+// getEnv reads an environment variable with a default value
+func getEnv(key, defaultValue string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultValue
+}
+
 // getBoolEnv parses a boolean environment variable
 func getBoolEnv(key string, defaultValue bool) bool {
 	val := os.Getenv(key)
@@ -50,11 +384,124 @@ func splitEnv(key string) []string {
 	return strings.Split(val, ",")
 }
 
+// splitLinesEnv splits an environment variable holding one entry per line,
+// dropping blank lines and surrounding spaces
+func splitLinesEnv(key string) []string {
+	values := []string{}
+	for _, val := range strings.Split(os.Getenv(key), "\n") {
+		if val = strings.TrimSpace(val); val != "" {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
+// getListEnv parses a comma-separated, case-insensitive list, falling back
+// to defaults when the variable is unset
+func getListEnv(key string, defaults ...string) []string {
+	if os.Getenv(key) == "" {
+		return defaults
+	}
+	values := []string{}
+	for _, val := range splitEnv(key) {
+		if val = strings.ToLower(strings.TrimSpace(val)); val != "" {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
+// getMapEnv parses comma-separated key=value pairs, dropping malformed ones.
+// Keys are lowercased.
+func getMapEnv(key string) map[string]string {
+	pairs := map[string]string{}
+	for _, pair := range splitEnv(key) {
+		k, v, found := strings.Cut(pair, "=")
+		k, v = strings.ToLower(strings.TrimSpace(k)), strings.TrimSpace(v)
+		if found && k != "" && v != "" {
+			pairs[k] = v
+		}
+	}
+	return pairs
+}
+
 // getGracePeriod parses GRACE_PERIOD environment variable
 func getGracePeriod() int {
-	val := os.Getenv("GRACE_PERIOD")
+	return getIntEnv("GRACE_PERIOD", 30)
+}
+
+// getDomainMatch parses DOMAIN_MATCH, falling back to suffix matching
+func getDomainMatch() string {
+	if strings.ToLower(os.Getenv("DOMAIN_MATCH")) == DomainMatchExact {
+		return DomainMatchExact
+	}
+	return DomainMatchSuffix
+}
+
+// getDeletionMode parses DELETION_MODE, falling back to namespace deletion
+func getDeletionMode() string {
+	if strings.ToLower(os.Getenv("DELETION_MODE")) == DeletionModeProfile {
+		return DeletionModeProfile
+	}
+	return DeletionModeNamespace
+}
+
+// getPropagation parses DELETION_PROPAGATION, defaulting to background
+func getPropagation() string {
+	if strings.ToLower(os.Getenv("DELETION_PROPAGATION")) == PropagationForeground {
+		return PropagationForeground
+	}
+	return PropagationBackground
+}
+
+// getRunMode parses RUN_MODE, defaulting to a single cronjob run
+func getRunMode() string {
+	if strings.ToLower(os.Getenv("RUN_MODE")) == RunModeController {
+		return RunModeController
+	}
+	return RunModeCronJob
+}
+
+// getPolicySource parses POLICY_SOURCE, defaulting to the policy file
+func getPolicySource() string {
+	if strings.ToLower(os.Getenv("POLICY_SOURCE")) == PolicySourceCluster {
+		return PolicySourceCluster
+	}
+	return PolicySourceFile
+}
+
+// getDurationEnv parses a positive duration environment variable such as "5m"
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	val := os.Getenv(key)
 	if val == "" {
-		return 30
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		return defaultValue
+	}
+	return d
+}
+
+// getDaysListEnv parses a comma-separated list of day counts, dropping
+// entries that are not non-negative integers
+func getDaysListEnv(key string) []int {
+	days := []int{}
+	for _, val := range splitEnv(key) {
+		d, err := strconv.Atoi(strings.TrimSpace(val))
+		if err == nil && d >= 0 {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// getIntEnv parses a non-negative integer environment variable
+func getIntEnv(key string, defaultValue int) int {
+	val := os.Getenv(key)
+	if val == "" {
+		return defaultValue
 	}
 
 	days, err := strconv.Atoi(val)
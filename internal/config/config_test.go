@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -88,3 +90,363 @@ func TestBoolEnvParsing(t *testing.T) {
 		})
 	}
 }
+
+func TestQuarantineConfig(t *testing.T) {
+	os.Setenv("QUARANTINE_ENABLED", "true")
+	os.Setenv("QUARANTINE_PERIOD", "7")
+	defer func() {
+		os.Unsetenv("QUARANTINE_ENABLED")
+		os.Unsetenv("QUARANTINE_PERIOD")
+	}()
+
+	cfg := LoadConfig()
+
+	if !cfg.QuarantineEnabled {
+		t.Error("Expected QuarantineEnabled to be true")
+	}
+	if cfg.QuarantinePeriod != 7 {
+		t.Errorf("Expected QuarantinePeriod 7, got %d", cfg.QuarantinePeriod)
+	}
+
+	os.Unsetenv("QUARANTINE_PERIOD")
+	if got := LoadConfig().QuarantinePeriod; got != 14 {
+		t.Errorf("Expected default QuarantinePeriod 14, got %d", got)
+	}
+}
+
+func TestDeletionMode(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{"", DeletionModeNamespace},
+		{"namespace", DeletionModeNamespace},
+		{"profile", DeletionModeProfile},
+		{"Profile", DeletionModeProfile},
+		{"invalid", DeletionModeNamespace},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			os.Setenv("DELETION_MODE", tc.value)
+			defer os.Unsetenv("DELETION_MODE")
+
+			if got := getDeletionMode(); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDeletionStrategyConfig(t *testing.T) {
+	os.Setenv("DELETION_PROPAGATION", "Foreground")
+	os.Setenv("WAIT_FOR_DELETION", "true")
+	os.Setenv("DELETION_TIMEOUT", "90s")
+	defer func() {
+		os.Unsetenv("DELETION_PROPAGATION")
+		os.Unsetenv("WAIT_FOR_DELETION")
+		os.Unsetenv("DELETION_TIMEOUT")
+	}()
+
+	cfg := LoadConfig()
+
+	if cfg.DeletionPropagation != PropagationForeground {
+		t.Errorf("Expected foreground propagation, got %q", cfg.DeletionPropagation)
+	}
+	if !cfg.WaitForDeletion {
+		t.Error("Expected WaitForDeletion to be true")
+	}
+	if cfg.DeletionTimeout != 90*time.Second {
+		t.Errorf("Expected DeletionTimeout 90s, got %v", cfg.DeletionTimeout)
+	}
+}
+
+func TestDurationEnvParsing(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", 5 * time.Minute},
+		{"30s", 30 * time.Second},
+		{"2h", 2 * time.Hour},
+		{"invalid", 5 * time.Minute},
+		{"-1m", 5 * time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			os.Setenv("TEST_DURATION", tc.value)
+			defer os.Unsetenv("TEST_DURATION")
+
+			if got := getDurationEnv("TEST_DURATION", 5*time.Minute); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNotificationConfig(t *testing.T) {
+	os.Setenv("SMTP_HOST", "smtp.example.com")
+	os.Setenv("NOTIFY_EVENTS", "labeled,reminder")
+	os.Setenv("NOTIFY_REMINDER_DAYS", "7, 1,invalid,-2")
+	defer func() {
+		os.Unsetenv("SMTP_HOST")
+		os.Unsetenv("NOTIFY_EVENTS")
+		os.Unsetenv("NOTIFY_REMINDER_DAYS")
+	}()
+
+	cfg := LoadConfig()
+
+	if cfg.SMTPHost != "smtp.example.com" {
+		t.Errorf("Expected SMTPHost 'smtp.example.com', got '%s'", cfg.SMTPHost)
+	}
+	if cfg.SMTPPort != "25" {
+		t.Errorf("Expected default SMTPPort '25', got '%s'", cfg.SMTPPort)
+	}
+	if len(cfg.NotifyEvents) != 2 {
+		t.Errorf("Expected 2 notify events, got %d", len(cfg.NotifyEvents))
+	}
+	if len(cfg.NotifyReminderDays) != 2 || cfg.NotifyReminderDays[0] != 7 || cfg.NotifyReminderDays[1] != 1 {
+		t.Errorf("Expected reminder days [7 1], got %v", cfg.NotifyReminderDays)
+	}
+}
+
+func TestRunModeConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.RunMode != RunModeCronJob || cfg.RunInterval != time.Hour || cfg.MetricsAddr != ":9090" {
+		t.Errorf("Unexpected defaults: %q %v %q", cfg.RunMode, cfg.RunInterval, cfg.MetricsAddr)
+	}
+
+	os.Setenv("RUN_MODE", "Controller")
+	os.Setenv("RUN_INTERVAL", "15m")
+	os.Setenv("PUSHGATEWAY_URL", "http://pushgateway:9091")
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://otel-collector:4318")
+	defer func() {
+		os.Unsetenv("RUN_MODE")
+		os.Unsetenv("RUN_INTERVAL")
+		os.Unsetenv("PUSHGATEWAY_URL")
+		os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}()
+
+	cfg = LoadConfig()
+	if cfg.RunMode != RunModeController {
+		t.Errorf("Expected controller mode, got %q", cfg.RunMode)
+	}
+	if cfg.RunInterval != 15*time.Minute {
+		t.Errorf("Expected RunInterval 15m, got %v", cfg.RunInterval)
+	}
+	if cfg.PushgatewayURL != "http://pushgateway:9091" {
+		t.Errorf("Unexpected PushgatewayURL %q", cfg.PushgatewayURL)
+	}
+	if cfg.TracingEndpoint != "http://otel-collector:4318" {
+		t.Errorf("Unexpected TracingEndpoint %q", cfg.TracingEndpoint)
+	}
+}
+
+func TestReportConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.ReportFormat != ReportFormatJSON || cfg.ReportOutput != ReportOutputStdout {
+		t.Errorf("Unexpected defaults: %q %q", cfg.ReportFormat, cfg.ReportOutput)
+	}
+
+	os.Setenv("REPORT_FORMAT", "Markdown")
+	os.Setenv("REPORT_OUTPUT", "ConfigMap")
+	os.Setenv("REPORT_CONFIGMAP", "ops/cleaner-report")
+	defer func() {
+		os.Unsetenv("REPORT_FORMAT")
+		os.Unsetenv("REPORT_OUTPUT")
+		os.Unsetenv("REPORT_CONFIGMAP")
+	}()
+
+	cfg = LoadConfig()
+	if cfg.ReportFormat != ReportFormatMarkdown || cfg.ReportOutput != ReportOutputConfigMap {
+		t.Errorf("Unexpected report settings: %q %q", cfg.ReportFormat, cfg.ReportOutput)
+	}
+	if cfg.ReportConfigMap != "ops/cleaner-report" {
+		t.Errorf("Unexpected ReportConfigMap %q", cfg.ReportConfigMap)
+	}
+}
+
+func TestLoggingConfig(t *testing.T) {
+	os.Setenv("LOG_LEVEL", "TRACE")
+	os.Setenv("LOG_FORMAT", "JSON")
+	os.Setenv("VERBOSE", "true")
+	defer func() {
+		os.Unsetenv("LOG_LEVEL")
+		os.Unsetenv("LOG_FORMAT")
+		os.Unsetenv("VERBOSE")
+	}()
+
+	cfg := LoadConfig()
+	if cfg.LogLevel != "trace" || cfg.LogFormat != LogFormatJSON || !cfg.Verbose {
+		t.Errorf("Unexpected logging settings: %q %q %v", cfg.LogLevel, cfg.LogFormat, cfg.Verbose)
+	}
+}
+
+func TestAuditConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.AuditSink != AuditSinkNone || cfg.AuditMaxBytes != 900000 {
+		t.Errorf("Unexpected defaults: %q %d", cfg.AuditSink, cfg.AuditMaxBytes)
+	}
+
+	os.Setenv("AUDIT_SINK", "Secret")
+	os.Setenv("AUDIT_OBJECT", "ops/audit")
+	defer func() {
+		os.Unsetenv("AUDIT_SINK")
+		os.Unsetenv("AUDIT_OBJECT")
+	}()
+
+	cfg = LoadConfig()
+	if cfg.AuditSink != AuditSinkSecret || cfg.AuditObject != "ops/audit" {
+		t.Errorf("Unexpected audit settings: %q %q", cfg.AuditSink, cfg.AuditObject)
+	}
+}
+
+func TestTransferConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.TransferEnabled || strings.Join(cfg.TransferSources, ",") != "manager,contributor,steward" {
+		t.Errorf("Unexpected defaults: %v %v", cfg.TransferEnabled, cfg.TransferSources)
+	}
+	if strings.Join(cfg.ContributorRoles, ",") != "admin,edit" || cfg.ContributorsAsOwners {
+		t.Errorf("Unexpected contributor defaults %v %v", cfg.ContributorRoles, cfg.ContributorsAsOwners)
+	}
+
+	os.Setenv("TRANSFER_ENABLED", "true")
+	os.Setenv("TRANSFER_SOURCES", "Steward, manager")
+	os.Setenv("TRANSFER_STEWARDS", "StatCan.gc.ca=lead@statcan.gc.ca,broken,=x")
+	defer func() {
+		os.Unsetenv("TRANSFER_ENABLED")
+		os.Unsetenv("TRANSFER_SOURCES")
+		os.Unsetenv("TRANSFER_STEWARDS")
+	}()
+
+	cfg = LoadConfig()
+	if !cfg.TransferEnabled || strings.Join(cfg.TransferSources, ",") != "steward,manager" {
+		t.Errorf("Unexpected transfer settings: %v %v", cfg.TransferEnabled, cfg.TransferSources)
+	}
+	if len(cfg.TransferStewards) != 1 || cfg.TransferStewards["statcan.gc.ca"] != "lead@statcan.gc.ca" {
+		t.Errorf("Unexpected stewards %v", cfg.TransferStewards)
+	}
+}
+
+func TestActivityConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.IdleDays != 0 || cfg.ActiveDays != 0 || strings.Join(cfg.ActivitySources, ",") != "pods,notebooks,events,pvcs" {
+		t.Errorf("Unexpected defaults: %d %d %v", cfg.IdleDays, cfg.ActiveDays, cfg.ActivitySources)
+	}
+
+	os.Setenv("IDLE_DAYS", "180")
+	os.Setenv("ACTIVE_DAYS", "14")
+	os.Setenv("ACTIVITY_SOURCES", "Pods,events")
+	defer func() {
+		os.Unsetenv("IDLE_DAYS")
+		os.Unsetenv("ACTIVE_DAYS")
+		os.Unsetenv("ACTIVITY_SOURCES")
+	}()
+
+	cfg = LoadConfig()
+	if cfg.IdleDays != 180 || cfg.ActiveDays != 14 || strings.Join(cfg.ActivitySources, ",") != "pods,events" {
+		t.Errorf("Unexpected activity settings: %d %d %v", cfg.IdleDays, cfg.ActiveDays, cfg.ActivitySources)
+	}
+}
+
+func TestPolicySourceConfig(t *testing.T) {
+	if cfg := LoadConfig(); cfg.PolicySource != PolicySourceFile {
+		t.Errorf("Expected the policy file by default, got %q", cfg.PolicySource)
+	}
+
+	os.Setenv("POLICY_SOURCE", "Cluster")
+	defer os.Unsetenv("POLICY_SOURCE")
+	if cfg := LoadConfig(); cfg.PolicySource != PolicySourceCluster {
+		t.Errorf("Expected cluster policies, got %q", cfg.PolicySource)
+	}
+}
+
+func TestAdmissionConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if cfg.AdmissionAddr != "" || cfg.AdmissionMode != AdmissionModeWarn || cfg.AdmissionCheckOwner {
+		t.Errorf("Unexpected defaults: %q %q %v", cfg.AdmissionAddr, cfg.AdmissionMode, cfg.AdmissionCheckOwner)
+	}
+
+	os.Setenv("ADMISSION_ADDR", ":8443")
+	os.Setenv("ADMISSION_MODE", "Deny")
+	os.Setenv("ADMISSION_CHECK_OWNER", "true")
+	os.Setenv("ADMISSION_OWNER_RULES", "^oidc:(.+)$=$1\n\n  ^([a-z]{1,3}[0-9,]+)$=$1@statcan.gc.ca\n")
+	defer func() {
+		os.Unsetenv("ADMISSION_ADDR")
+		os.Unsetenv("ADMISSION_MODE")
+		os.Unsetenv("ADMISSION_CHECK_OWNER")
+		os.Unsetenv("ADMISSION_OWNER_RULES")
+	}()
+
+	cfg = LoadConfig()
+	if cfg.AdmissionAddr != ":8443" || cfg.AdmissionMode != AdmissionModeDeny || !cfg.AdmissionCheckOwner {
+		t.Errorf("Unexpected settings: %q %q %v", cfg.AdmissionAddr, cfg.AdmissionMode, cfg.AdmissionCheckOwner)
+	}
+	if len(cfg.AdmissionOwnerRules) != 2 || cfg.AdmissionOwnerRules[1] != "^([a-z]{1,3}[0-9,]+)$=$1@statcan.gc.ca" {
+		t.Errorf("Expected the owner rules in order, got %v", cfg.AdmissionOwnerRules)
+	}
+}
+
+func TestOrphanConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if len(cfg.OrphanOwnerSources) != 0 || cfg.OrphanGracePeriod != 0 || len(cfg.OrphanNotify) != 0 {
+		t.Errorf("Unexpected defaults: %v %d %v", cfg.OrphanOwnerSources, cfg.OrphanGracePeriod, cfg.OrphanNotify)
+	}
+
+	os.Setenv("ORPHAN_OWNER_SOURCES", "Profile, rolebinding,managedFields")
+	os.Setenv("ORPHAN_GRACE_PERIOD", "90")
+	os.Setenv("ORPHAN_NOTIFY", "admins@statcan.gc.ca")
+	defer func() {
+		os.Unsetenv("ORPHAN_OWNER_SOURCES")
+		os.Unsetenv("ORPHAN_GRACE_PERIOD")
+		os.Unsetenv("ORPHAN_NOTIFY")
+	}()
+
+	cfg = LoadConfig()
+	if strings.Join(cfg.OrphanOwnerSources, ",") != "profile,rolebinding,managedfields" {
+		t.Errorf("Expected the sources in order, got %v", cfg.OrphanOwnerSources)
+	}
+	if cfg.OrphanGracePeriod != 90 || strings.Join(cfg.OrphanNotify, ",") != "admins@statcan.gc.ca" {
+		t.Errorf("Unexpected settings: %d %v", cfg.OrphanGracePeriod, cfg.OrphanNotify)
+	}
+}
+
+func TestOwnerDomainAliasesConfig(t *testing.T) {
+	if cfg := LoadConfig(); len(cfg.OwnerDomainAliases) != 0 {
+		t.Errorf("Expected no aliases by default, got %v", cfg.OwnerDomainAliases)
+	}
+
+	os.Setenv("OWNER_DOMAIN_ALIASES", "Canada.ca=statcan.gc.ca, old.example.com = example.com")
+	defer os.Unsetenv("OWNER_DOMAIN_ALIASES")
+	cfg := LoadConfig()
+	if cfg.OwnerDomainAliases["canada.ca"] != "statcan.gc.ca" || cfg.OwnerDomainAliases["old.example.com"] != "example.com" {
+		t.Errorf("Unexpected aliases %v", cfg.OwnerDomainAliases)
+	}
+}
+
+func TestDomainRulesConfig(t *testing.T) {
+	cfg := LoadConfig()
+	if len(cfg.DeniedDomains) != 0 || len(cfg.DomainActions) != 0 || cfg.DomainMatch != DomainMatchSuffix {
+		t.Errorf("Unexpected defaults %v %v %q", cfg.DeniedDomains, cfg.DomainActions, cfg.DomainMatch)
+	}
+
+	os.Setenv("DENIED_DOMAINS", "external.statcan.gc.ca")
+	os.Setenv("DOMAIN_ACTIONS", "*.Cloud.statcan.ca=keep,old.statcan.gc.ca=expire")
+	os.Setenv("DOMAIN_MATCH", "EXACT")
+	defer func() {
+		os.Unsetenv("DENIED_DOMAINS")
+		os.Unsetenv("DOMAIN_ACTIONS")
+		os.Unsetenv("DOMAIN_MATCH")
+	}()
+	cfg = LoadConfig()
+	if len(cfg.DeniedDomains) != 1 || cfg.DeniedDomains[0] != "external.statcan.gc.ca" {
+		t.Errorf("Unexpected denied domains %v", cfg.DeniedDomains)
+	}
+	if cfg.DomainActions["*.cloud.statcan.ca"] != DomainActionKeep || cfg.DomainActions["old.statcan.gc.ca"] != DomainActionExpire {
+		t.Errorf("Unexpected domain actions %v", cfg.DomainActions)
+	}
+	if cfg.DomainMatch != DomainMatchExact {
+		t.Errorf("Expected exact matching, got %q", cfg.DomainMatch)
+	}
+}
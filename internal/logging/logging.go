@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+// Attribute keys shared by every log line about a namespace
+const (
+	KeyNamespace = "namespace"
+	KeyOwner     = "owner"
+	KeyPhase     = "phase"
+	KeyAction    = "action"
+	KeyReason    = "reason"
+	KeyDryRun    = "dry_run"
+	KeyError     = "error"
+)
+
+// Setup installs the default slog logger from configuration. Output from
+// the standard log package is routed through it as well.
+func Setup(cfg *config.Config) *slog.Logger {
+	logger := New(os.Stderr, cfg)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New creates a logger writing to w in the configured format and level
+func New(w io.Writer, cfg *config.Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: Level(cfg.LogLevel, cfg.Verbose)}
+
+	var handler slog.Handler
+	if cfg.LogFormat == config.LogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(handler).With(KeyDryRun, cfg.DryRun)
+}
+
+// Level parses LOG_LEVEL. "trace" is treated as debug, the most detailed
+// level slog has, and verbose raises any level to debug.
+func Level(name string, verbose bool) slog.Level {
+	if verbose {
+		return slog.LevelDebug
+	}
+
+	switch strings.ToLower(name) {
+	case "trace", "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Fatal logs an error and exits, standing in for log.Fatalf
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestLevel(t *testing.T) {
+	testCases := []struct {
+		name    string
+		verbose bool
+		want    slog.Level
+	}{
+		{"", false, slog.LevelInfo},
+		{"trace", false, slog.LevelDebug},
+		{"DEBUG", false, slog.LevelDebug},
+		{"warning", false, slog.LevelWarn},
+		{"error", false, slog.LevelError},
+		{"bogus", false, slog.LevelInfo},
+		{"error", true, slog.LevelDebug},
+	}
+
+	for _, tc := range testCases {
+		if got := Level(tc.name, tc.verbose); got != tc.want {
+			t.Errorf("Level(%q, %v) = %v, want %v", tc.name, tc.verbose, got, tc.want)
+		}
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, &config.Config{DryRun: true, LogFormat: config.LogFormatJSON, LogLevel: "info"})
+
+	logger.Debug("hidden")
+	logger.Info("Would delete namespace", KeyNamespace, "test-ns", KeyAction, "delete")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected only the info line, got %q", buf.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Invalid JSON log line: %v", err)
+	}
+	if entry[KeyNamespace] != "test-ns" || entry[KeyAction] != "delete" || entry[KeyDryRun] != true {
+		t.Errorf("Unexpected fields %v", entry)
+	}
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, &config.Config{LogFormat: config.LogFormatText}).Warn("careful", KeyReason, "test")
+
+	if !strings.Contains(buf.String(), "level=WARN") || !strings.Contains(buf.String(), "reason=test") {
+		t.Errorf("Unexpected text output %q", buf.String())
+	}
+}
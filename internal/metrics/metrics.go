@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+const (
+	metricsNamespace = "namespace_cleaner"
+	pushJobName      = "namespace_cleaner"
+)
+
+// Registry holds the cleaner's metrics. A dedicated registry keeps Go
+// runtime metrics out of the Pushgateway.
+var Registry = prometheus.NewRegistry()
+
+var (
+	decisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "decisions_total",
+		Help:      "Namespaces evaluated, by outcome and reason.",
+	}, []string{"outcome", "reason"})
+
+	runErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "errors_total",
+		Help:      "Errors hit while processing namespaces.",
+	})
+
+	pendingDeletion = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_deletion",
+		Help:      "Namespaces labeled for deletion that are not deleted yet, as of the last run.",
+	})
+
+	timeUntilDeletion = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "time_until_deletion_seconds",
+		Help:      "Time left before each pending namespace is deleted, observed once per run.",
+		Buckets:   dayBuckets(1, 3, 7, 14, 30, 60, 90),
+	})
+
+	graphDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "graph_request_duration_seconds",
+		Help:      "Latency of Microsoft Graph lookups.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	graphErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "graph_errors_total",
+		Help:      "Microsoft Graph lookups that failed, not counting users that do not exist.",
+	}, []string{"operation"})
+
+	runDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "run_duration_seconds",
+		Help:      "Duration of the last run.",
+	})
+
+	lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time at which the last run without errors finished.",
+	})
+
+	admissionReviews = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "admission_reviews_total",
+		Help:      "Namespace admission reviews answered, by webhook and result.",
+	}, []string{"webhook", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		decisions, runErrors, pendingDeletion, timeUntilDeletion,
+		graphDuration, graphErrors, runDuration, lastSuccess,
+		admissionReviews,
+	)
+}
+
+// ObserveGraph records the latency of a Graph request and whether it failed
+func ObserveGraph(operation string, start time.Time, err error) {
+	graphDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		graphErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// ObserveAdmission records the result of an admission review
+func ObserveAdmission(webhook, result string) {
+	admissionReviews.WithLabelValues(webhook, result).Inc()
+}
+
+// ObserveRun records the outcome of a run that started at start and
+// finished at end
+func ObserveRun(s *stats.Stats, start, end time.Time) {
+	for _, d := range []struct {
+		outcome, reason string
+		count           int
+	}{
+		{"labeled", "owner_not_found", s.Labeled - s.LabeledIdle - s.LabeledOwnerless},
+		{"labeled", "idle", s.LabeledIdle},
+		{"labeled", "ownerless", s.LabeledOwnerless},
+		{"label_removed", "owner_found", s.LabelsRemoved},
+		{"quarantined", "grace_period_expired", s.Quarantined},
+		{"restored", "owner_found", s.Restored},
+		{"deleted", "grace_period_expired", s.Deleted},
+		{"stuck", "deletion_timeout", s.Stuck},
+		{"transferred", "owner_transferred", s.Transferred},
+		{"skipped", "owner_exists", s.SkippedExistingUser},
+		{"skipped", "missing_owner", s.SkippedMissingOwner},
+		{"skipped", "invalid_domain", s.SkippedInvalidDomain},
+		{"skipped", "invalid_owner", s.SkippedInvalidOwner},
+		{"skipped", "invalid_label", s.InvalidLabels},
+		{"skipped", "lookup_failed", s.SkippedLookupFailed},
+		{"skipped", "recently_used", s.SkippedRecentlyUsed},
+		{"skipped", "policy", s.SkippedByPolicy},
+	} {
+		decisions.WithLabelValues(d.outcome, d.reason).Add(float64(d.count))
+	}
+	runErrors.Add(float64(len(s.Errors)))
+
+	pendingDeletion.Set(float64(len(s.PendingDeletion)))
+	for _, deleteAt := range s.PendingDeletion {
+		timeUntilDeletion.Observe(deleteAt.Sub(end).Seconds())
+	}
+
+	runDuration.Set(end.Sub(start).Seconds())
+	if len(s.Errors) == 0 {
+		lastSuccess.Set(float64(end.Unix()))
+	}
+}
+
+// Serve exposes the metrics on /metrics in the background
+func Serve(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Error serving metrics", "error", err)
+		}
+	}()
+	return server
+}
+
+// Push replaces the cleaner's metrics on a Pushgateway
+func Push(ctx context.Context, url string) error {
+	return push.New(url, pushJobName).Gatherer(Registry).PushContext(ctx)
+}
+
+// dayBuckets converts day counts to histogram buckets in seconds
+func dayBuckets(days ...float64) []float64 {
+	buckets := make([]float64, len(days))
+	for i, d := range days {
+		buckets[i] = d * 24 * time.Hour.Seconds()
+	}
+	return buckets
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func TestObserveRun(t *testing.T) {
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Second)
+
+	s := &stats.Stats{}
+	s.AddLabeled("ns-a")
+	s.AddDeleted("ns-b")
+	s.IncSkippedMissingOwner()
+	s.AddPending("ns-a", end.Add(30*24*time.Hour))
+	s.AddPending("ns-c", end.Add(2*24*time.Hour))
+
+	ObserveRun(s, start, end)
+
+	if got := testutil.ToFloat64(decisions.WithLabelValues("labeled", "owner_not_found")); got != 1 {
+		t.Errorf("Expected 1 labeled decision, got %v", got)
+	}
+	if got := testutil.ToFloat64(decisions.WithLabelValues("skipped", "missing_owner")); got != 1 {
+		t.Errorf("Expected 1 missing owner skip, got %v", got)
+	}
+	if got := testutil.ToFloat64(pendingDeletion); got != 2 {
+		t.Errorf("Expected 2 pending namespaces, got %v", got)
+	}
+	if got := testutil.ToFloat64(runDuration); got != 90 {
+		t.Errorf("Expected run duration 90s, got %v", got)
+	}
+	if got := testutil.ToFloat64(lastSuccess); got != float64(end.Unix()) {
+		t.Errorf("Expected last success %d, got %v", end.Unix(), got)
+	}
+
+	// A run with errors leaves the last success untouched
+	failed := &stats.Stats{}
+	failed.AddError("Error listing namespaces: boom")
+	ObserveRun(failed, end, end.Add(time.Hour))
+	if got := testutil.ToFloat64(lastSuccess); got != float64(end.Unix()) {
+		t.Errorf("Failed run should not update last success, got %v", got)
+	}
+}
+
+func TestObserveGraph(t *testing.T) {
+	before := testutil.ToFloat64(graphErrors.WithLabelValues("user"))
+
+	ObserveGraph("user", time.Now(), nil)
+	ObserveGraph("user", time.Now(), errors.New("throttled"))
+
+	if got := testutil.ToFloat64(graphErrors.WithLabelValues("user")) - before; got != 1 {
+		t.Errorf("Expected 1 Graph error, got %v", got)
+	}
+}
+
+func TestObserveAdmission(t *testing.T) {
+	before := testutil.ToFloat64(admissionReviews.WithLabelValues("validate", "denied"))
+
+	ObserveAdmission("validate", "denied")
+
+	if got := testutil.ToFloat64(admissionReviews.WithLabelValues("validate", "denied")) - before; got != 1 {
+		t.Errorf("Expected 1 denied review, got %v", got)
+	}
+}
+
+func TestPush(t *testing.T) {
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	if err := Push(context.TODO(), server.URL); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if method != http.MethodPut || path != "/metrics/job/namespace_cleaner" {
+		t.Errorf("Unexpected push request %s %s", method, path)
+	}
+}
+
+func TestServe(t *testing.T) {
+	server := Serve("127.0.0.1:0")
+	defer server.Close()
+
+	// Serve listens in the background, so exercise its handler directly
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	if !strings.Contains(string(body), "namespace_cleaner_run_duration_seconds") {
+		t.Errorf("Metrics output missing run duration:\n%s", body)
+	}
+}
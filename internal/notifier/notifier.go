@@ -0,0 +1,231 @@
+package notifier
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+// Event identifies the point in a namespace's lifecycle a notice is sent at
+type Event string
+
+const (
+	EventLabeled  Event = "labeled"
+	EventReminder Event = "reminder"
+	EventDeleted  Event = "deleted"
+	// EventTransferred is sent to the new owner of a namespace
+	EventTransferred Event = "transferred"
+	// EventIdle is sent when a namespace is labeled for inactivity
+	EventIdle Event = "idle"
+	// EventOwnerless is sent to the admins when a namespace without an
+	// owner is labeled for deletion
+	EventOwnerless Event = "ownerless"
+	// EventExpression is sent when a policy expression labels a namespace,
+	// whether or not its owner exists
+	EventExpression Event = "expression"
+)
+
+// annotationPrefix prefixes the annotations recording sent notices
+const annotationPrefix = "namespace-cleaner/notified-"
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Notice describes a single owner notification
+type Notice struct {
+	Event     Event
+	Namespace string
+	Owner     string
+	Manager   string
+	// PreviousOwner is the departed owner, for transferred notices
+	PreviousOwner string
+	// IdleSince is the last activity seen, for idle notices
+	IdleSince time.Time
+	// Expression names the policy expression, for expression notices
+	Expression string
+	DeleteAt  time.Time
+	// DaysLeft is the number of days until DeleteAt
+	DaysLeft int
+	// Reminder is the reminder threshold in days that triggered the notice
+	Reminder int
+	// TemplateDir selects templates loaded with LoadTemplateDir. The
+	// configured templates are used when it is empty or unknown.
+	TemplateDir string
+}
+
+// AnnotationKey names the namespace annotation recording this notice
+func (n Notice) AnnotationKey() string {
+	if n.Event == EventReminder {
+		return annotationPrefix + string(n.Event) + "-" + strconv.Itoa(n.Reminder) + "d"
+	}
+	return annotationPrefix + string(n.Event)
+}
+
+// Notifier emails namespace owners through SMTP
+type Notifier struct {
+	addr   string
+	from   string
+	auth   smtp.Auth
+	events map[Event]bool
+	// admins receive ownerless notices, which have no owner to go to
+	admins    []string
+	templates map[Event]*template.Template
+	// templateSets holds templates loaded for policy rules, by dir
+	templateSets map[string]map[Event]*template.Template
+
+	// sendMail is swapped in tests
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// New creates a notifier from configuration. It returns nil when no
+// SMTP host is configured, which disables notifications.
+func New(cfg *config.Config) (*Notifier, error) {
+	if cfg.SMTPHost == "" {
+		return nil, nil
+	}
+
+	n := &Notifier{
+		addr:         net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		from:         cfg.SMTPFrom,
+		events:       map[Event]bool{},
+		admins:       cfg.OrphanNotify,
+		templateSets: map[string]map[Event]*template.Template{},
+		sendMail:     smtp.SendMail,
+	}
+	if cfg.SMTPUsername != "" {
+		n.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	for _, e := range cfg.NotifyEvents {
+		n.events[Event(strings.TrimSpace(e))] = true
+	}
+	if len(n.admins) > 0 {
+		n.events[EventOwnerless] = true
+	}
+
+	templates, err := loadTemplates(cfg.NotifyTemplateDir)
+	if err != nil {
+		return nil, err
+	}
+	n.templates = templates
+	return n, nil
+}
+
+// LoadTemplateDir loads another set of templates, picked by notices whose
+// TemplateDir names it
+func (n *Notifier) LoadTemplateDir(dir string) error {
+	if n == nil || n.templateSets[dir] != nil {
+		return nil
+	}
+	templates, err := loadTemplates(dir)
+	if err != nil {
+		return err
+	}
+	n.templateSets[dir] = templates
+	return nil
+}
+
+// loadTemplates reads the template of every event from dir
+func loadTemplates(dir string) (map[Event]*template.Template, error) {
+	templates := map[Event]*template.Template{}
+	for _, event := range []Event{EventLabeled, EventReminder, EventDeleted, EventTransferred, EventIdle, EventOwnerless, EventExpression} {
+		tmpl, err := loadTemplate(dir, event)
+		if err != nil {
+			return nil, err
+		}
+		templates[event] = tmpl
+	}
+	return templates, nil
+}
+
+// optionalTemplates may be missing from a custom template dir. They were
+// added after custom dirs came into use, so those dirs keep working.
+var optionalTemplates = map[Event]bool{EventTransferred: true, EventIdle: true, EventOwnerless: true, EventExpression: true}
+
+// loadTemplate reads <event>.tmpl from dir, or the built-in template
+// when dir is empty
+func loadTemplate(dir string, event Event) (*template.Template, error) {
+	name := string(event) + ".tmpl"
+	if dir != "" {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil || !optionalTemplates[event] {
+			return template.ParseFiles(path)
+		}
+	}
+	return template.ParseFS(defaultTemplates, "templates/"+name)
+}
+
+// Enabled reports whether notices are sent for an event
+func (n *Notifier) Enabled(event Event) bool {
+	return n != nil && n.events[event]
+}
+
+// Send renders and emails a notice to the owner, copying their manager.
+// Ownerless notices go to the admins instead.
+func (n *Notifier) Send(notice Notice) error {
+	msg, err := n.render(notice)
+	if err != nil {
+		return err
+	}
+
+	to := n.recipients(notice)
+	if notice.Manager != "" {
+		to = append(to, notice.Manager)
+	}
+	if err := n.sendMail(n.addr, n.auth, n.from, to, msg); err != nil {
+		return fmt.Errorf("sending %s notice for %s: %w", notice.Event, notice.Namespace, err)
+	}
+	return nil
+}
+
+// recipients lists who a notice is addressed to, leaving out the manager
+func (n *Notifier) recipients(notice Notice) []string {
+	if notice.Event == EventOwnerless {
+		return append([]string{}, n.admins...)
+	}
+	return []string{notice.Owner}
+}
+
+// render builds the full MIME message for a notice
+func (n *Notifier) render(notice Notice) ([]byte, error) {
+	templates := n.templates
+	if set, found := n.templateSets[notice.TemplateDir]; found {
+		templates = set
+	}
+	tmpl, found := templates[notice.Event]
+	if !found {
+		return nil, fmt.Errorf("no template for %s notices", notice.Event)
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", notice); err != nil {
+		return nil, err
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", notice); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.recipients(notice), ", "))
+	if notice.Manager != "" {
+		fmt.Fprintf(&msg, "Cc: %s\r\n", notice.Manager)
+	}
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.TrimSpace(body.String()), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,250 @@
+package notifier
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/notifier/smtptest"
+)
+
+func TestNewDisabledWithoutHost(t *testing.T) {
+	n, err := New(&config.Config{})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if n != nil {
+		t.Error("Notifier should be nil without an SMTP host")
+	}
+	if n.Enabled(EventLabeled) {
+		t.Error("Nil notifier should not be enabled")
+	}
+}
+
+func TestSendToSMTPSink(t *testing.T) {
+	sink, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatalf("Failed to start SMTP sink: %v", err)
+	}
+	defer sink.Close()
+
+	n, err := New(&config.Config{
+		SMTPHost:     sink.Host(),
+		SMTPPort:     sink.Port(),
+		SMTPFrom:     "cleaner@example.com",
+		NotifyEvents: []string{"labeled", "reminder"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if !n.Enabled(EventLabeled) || n.Enabled(EventDeleted) {
+		t.Error("Only configured events should be enabled")
+	}
+
+	notice := Notice{
+		Event:     EventLabeled,
+		Namespace: "test-ns",
+		Owner:     "user@example.com",
+		Manager:   "manager@example.com",
+		DeleteAt:  time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := n.Send(notice); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	messages := sink.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	msg := messages[0]
+	if msg.From != "cleaner@example.com" {
+		t.Errorf("Unexpected sender %q", msg.From)
+	}
+	if strings.Join(msg.To, ",") != "user@example.com,manager@example.com" {
+		t.Errorf("Unexpected recipients %v", msg.To)
+	}
+	for _, want := range []string{
+		"Cc: manager@example.com",
+		"Your namespace test-ns",
+		"Votre espace de noms test-ns",
+		"2023-02-01",
+	} {
+		if !strings.Contains(msg.Data, want) {
+			t.Errorf("Message should contain %q:\n%s", want, msg.Data)
+		}
+	}
+}
+
+func TestOwnerlessNoticesGoToAdmins(t *testing.T) {
+	sink, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatalf("Failed to start SMTP sink: %v", err)
+	}
+	defer sink.Close()
+
+	n, err := New(&config.Config{
+		SMTPHost:     sink.Host(),
+		SMTPPort:     sink.Port(),
+		SMTPFrom:     "cleaner@example.com",
+		OrphanNotify: []string{"admin1@example.com", "admin2@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if !n.Enabled(EventOwnerless) {
+		t.Fatal("Ownerless notices should be enabled by their recipients")
+	}
+
+	err = n.Send(Notice{
+		Event:     EventOwnerless,
+		Namespace: "test-ns",
+		DeleteAt:  time.Date(2023, 4, 10, 0, 0, 0, 0, time.UTC),
+		DaysLeft:  90,
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	messages := sink.Messages()
+	if len(messages) != 1 || strings.Join(messages[0].To, ",") != "admin1@example.com,admin2@example.com" {
+		t.Fatalf("Expected one message to the admins, got %+v", messages)
+	}
+	for _, want := range []string{"To: admin1@example.com, admin2@example.com", "test-ns has no owner", "2023-04-10"} {
+		if !strings.Contains(messages[0].Data, want) {
+			t.Errorf("Message should contain %q:\n%s", want, messages[0].Data)
+		}
+	}
+
+	if n, _ := New(&config.Config{SMTPHost: "localhost"}); n.Enabled(EventOwnerless) {
+		t.Error("Ownerless notices should be off without recipients")
+	}
+}
+
+func TestRenderTemplates(t *testing.T) {
+	n, err := New(&config.Config{SMTPHost: "localhost", SMTPPort: "25"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	testCases := []struct {
+		event Event
+		want  string
+	}{
+		{EventLabeled, "unless the account is restored"},
+		{EventReminder, "in 3 day(s)"},
+		{EventDeleted, "has been deleted"},
+		{EventIdle, "has not been used since"},
+		{EventExpression, `matches the cleanup policy "scratch". It will be deleted on 2023-02-01`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.event), func(t *testing.T) {
+			msg, err := n.render(Notice{
+				Event:      tc.event,
+				Namespace:  "test-ns",
+				Owner:      "user@example.com",
+				DeleteAt:   time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC),
+				DaysLeft:   3,
+				Expression: "scratch",
+			})
+			if err != nil {
+				t.Fatalf("render failed: %v", err)
+			}
+			if !strings.Contains(string(msg), tc.want) {
+				t.Errorf("Message should contain %q:\n%s", tc.want, msg)
+			}
+		})
+	}
+}
+
+func TestRenderExpressionDueAtOnce(t *testing.T) {
+	n, err := New(&config.Config{SMTPHost: "localhost", SMTPPort: "25"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	msg, err := n.render(Notice{Event: EventExpression, Namespace: "test-ns", Owner: "user@example.com", Expression: "scratch"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(string(msg), "deleted the next time the namespace\r\ncleaner runs") {
+		t.Errorf("Expected deletion on the next run:\n%s", msg)
+	}
+	if strings.Contains(string(msg), "Entra ID") {
+		t.Errorf("An expression notice should not claim the owner is missing:\n%s", msg)
+	}
+}
+
+func TestCustomTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, event := range []string{"labeled", "reminder", "deleted"} {
+		content := `{{define "subject"}}Custom {{.Namespace}}{{end}}{{define "body"}}Custom body{{end}}`
+		if err := os.WriteFile(filepath.Join(dir, event+".tmpl"), []byte(content), 0o644); err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+	}
+
+	n, err := New(&config.Config{SMTPHost: "localhost", SMTPPort: "25", NotifyTemplateDir: dir})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	msg, err := n.render(Notice{Event: EventDeleted, Namespace: "test-ns"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(string(msg), "Subject: Custom test-ns") {
+		t.Errorf("Custom template not used:\n%s", msg)
+	}
+
+	// Transferred notices fall back to the built-in template
+	msg, err = n.render(Notice{Event: EventTransferred, Namespace: "test-ns", Owner: "new@example.com", PreviousOwner: "old@example.com"})
+	if err != nil || !strings.Contains(string(msg), "old@example.com") {
+		t.Errorf("Expected the built-in transferred template, got %v:\n%s", err, msg)
+	}
+
+	if _, err := New(&config.Config{SMTPHost: "localhost", NotifyTemplateDir: t.TempDir()}); err == nil {
+		t.Error("Expected an error for a template dir without templates")
+	}
+}
+
+func TestLoadTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, event := range []string{"labeled", "reminder", "deleted"} {
+		content := `{{define "subject"}}Contractor {{.Namespace}}{{end}}{{define "body"}}Contractor body{{end}}`
+		if err := os.WriteFile(filepath.Join(dir, event+".tmpl"), []byte(content), 0o644); err != nil {
+			t.Fatalf("Setup failed: %v", err)
+		}
+	}
+
+	n, err := New(&config.Config{SMTPHost: "localhost", SMTPPort: "25"})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := n.LoadTemplateDir(dir); err != nil {
+		t.Fatalf("LoadTemplateDir failed: %v", err)
+	}
+
+	msg, err := n.render(Notice{Event: EventLabeled, Namespace: "test-ns", TemplateDir: dir})
+	if err != nil || !strings.Contains(string(msg), "Subject: Contractor test-ns") {
+		t.Errorf("Expected the rule's template, got %v:\n%s", err, msg)
+	}
+	msg, err = n.render(Notice{Event: EventLabeled, Namespace: "test-ns"})
+	if err != nil || strings.Contains(string(msg), "Contractor") {
+		t.Errorf("Expected the configured template, got %v:\n%s", err, msg)
+	}
+
+	if err := n.LoadTemplateDir(t.TempDir()); err == nil {
+		t.Error("Expected an error for a template dir without templates")
+	}
+}
+
+func TestAnnotationKey(t *testing.T) {
+	if got := (Notice{Event: EventLabeled}).AnnotationKey(); got != "namespace-cleaner/notified-labeled" {
+		t.Errorf("Unexpected key %q", got)
+	}
+	if got := (Notice{Event: EventReminder, Reminder: 7}).AnnotationKey(); got != "namespace-cleaner/notified-reminder-7d" {
+		t.Errorf("Unexpected key %q", got)
+	}
+}
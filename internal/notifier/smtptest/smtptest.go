@@ -0,0 +1,135 @@
+// Package smtptest provides a local SMTP sink for testing notifications.
+package smtptest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Message is a mail accepted by the sink
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Server is a minimal SMTP server that records every message it receives
+type Server struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewServer starts a sink listening on a random local port
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: listener}
+	go s.serve()
+	return s, nil
+}
+
+// Host returns the host the sink listens on
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+// Port returns the port the sink listens on
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+// Messages returns the messages received so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Close stops the sink
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle speaks just enough SMTP for net/smtp.SendMail
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 smtptest ready")
+	var msg Message
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 smtptest")
+		case "MAIL":
+			msg = Message{From: addressOf(cmd)}
+			reply("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, addressOf(cmd))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// addressOf extracts the address from "MAIL FROM:<a>" or "RCPT TO:<a>"
+func addressOf(cmd string) string {
+	start := strings.Index(cmd, "<")
+	end := strings.LastIndex(cmd, ">")
+	if start == -1 || end < start {
+		return ""
+	}
+	return cmd[start+1 : end]
+}
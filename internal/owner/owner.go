@@ -0,0 +1,153 @@
+package owner
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// Errors returned for owner values that are not a usable email address
+var (
+	ErrEmpty       = errors.New("no owner is given")
+	ErrMultipleAts = errors.New("an owner must hold exactly one @")
+)
+
+// mailtoPrefix is stripped from owners copied from mail links
+const mailtoPrefix = "mailto:"
+
+// Parse reads the owners listed in an owner annotation, separated by
+// commas, semicolons or spaces. Each is normalized and has its domain
+// mapped through aliases (old domain to new domain). Duplicates are
+// dropped and the order is kept, so the first owner stays the primary
+// one. A single malformed owner fails the whole value.
+func Parse(value string, aliases map[string]string) ([]string, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+
+	var owners []string
+	seen := map[string]bool{}
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		// "mailto: user@example.com" splits into two fields
+		if strings.EqualFold(field, mailtoPrefix) && i+1 < len(fields) {
+			i++
+			field = fields[i]
+		}
+		email, err := Normalize(field)
+		if err != nil {
+			return nil, fmt.Errorf("owner %q: %w", field, err)
+		}
+		email = MapAlias(email, aliases)
+		// Entra ID matches local parts case-insensitively too
+		if key := strings.ToLower(email); !seen[key] {
+			seen[key] = true
+			owners = append(owners, email)
+		}
+	}
+	if len(owners) == 0 {
+		return nil, ErrEmpty
+	}
+	return owners, nil
+}
+
+// Normalize turns a single owner into the bare email address looked up
+// in Entra ID. Surrounding spaces, a mailto: prefix and angle brackets
+// are removed, and the domain is lowercased, with internationalized
+// domains in their ASCII form. The local part is kept as is.
+func Normalize(raw string) (string, error) {
+	email := strings.TrimSpace(raw)
+	if len(email) >= len(mailtoPrefix) && strings.EqualFold(email[:len(mailtoPrefix)], mailtoPrefix) {
+		email = strings.TrimSpace(email[len(mailtoPrefix):])
+	}
+	if strings.HasPrefix(email, "<") && strings.HasSuffix(email, ">") {
+		email = strings.TrimSpace(email[1 : len(email)-1])
+	}
+	if email == "" {
+		return "", ErrEmpty
+	}
+	if strings.Count(email, "@") != 1 {
+		return "", ErrMultipleAts
+	}
+
+	local, domain, _ := strings.Cut(email, "@")
+	if local == "" {
+		return "", errors.New("the part before the @ is empty")
+	}
+	domain, err := Domain(domain)
+	if err != nil {
+		return "", err
+	}
+
+	email = local + "@" + domain
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return "", err
+	}
+	if address.Address != email || address.Name != "" {
+		return "", errors.New("it must be a bare email address")
+	}
+	return email, nil
+}
+
+// Domain lowercases a domain and converts internationalized domains to
+// their ASCII form, so domains can be compared as plain strings
+func Domain(domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return "", errors.New("the domain is empty")
+	}
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+	if !strings.Contains(ascii, ".") {
+		return "", fmt.Errorf("invalid domain %q: it has no dot", domain)
+	}
+	return strings.ToLower(ascii), nil
+}
+
+// Pattern normalizes a domain pattern like Domain does. Labels holding
+// the glob characters * or ? are lowercased but otherwise kept.
+func Pattern(pattern string) (string, error) {
+	if !strings.ContainsAny(pattern, "*?") {
+		return Domain(pattern)
+	}
+	labels := strings.Split(strings.TrimSuffix(strings.TrimSpace(pattern), "."), ".")
+	for i, label := range labels {
+		if strings.ContainsAny(label, "*?") {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+		ascii, err := idna.Lookup.ToASCII(label)
+		if err != nil || ascii == "" {
+			return "", fmt.Errorf("invalid domain pattern %q", pattern)
+		}
+		labels[i] = strings.ToLower(ascii)
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// MapAlias moves an email from an old domain to the new one aliases maps
+// it to. Only the exact domain is mapped, not its subdomains.
+func MapAlias(email string, aliases map[string]string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 || len(aliases) == 0 {
+		return email
+	}
+	domain := email[at+1:]
+	for from, to := range aliases {
+		from, err := Domain(from)
+		if err != nil || from != domain {
+			continue
+		}
+		if to, err := Domain(to); err == nil {
+			return email[:at+1] + to
+		}
+	}
+	return email
+}
@@ -0,0 +1,184 @@
+package owner
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr string
+	}{
+		{"plain", "user@statcan.gc.ca", "user@statcan.gc.ca", ""},
+		{"domain lowercased", "User.Name@StatCan.GC.CA", "User.Name@statcan.gc.ca", ""},
+		{"surrounding spaces", " \tuser@statcan.gc.ca\n", "user@statcan.gc.ca", ""},
+		{"mailto prefix", "mailto:user@statcan.gc.ca", "user@statcan.gc.ca", ""},
+		{"uppercase mailto prefix", "MAILTO: user@statcan.gc.ca", "user@statcan.gc.ca", ""},
+		{"angle brackets", "<user@statcan.gc.ca>", "user@statcan.gc.ca", ""},
+		{"trailing dot in domain", "user@statcan.gc.ca.", "user@statcan.gc.ca", ""},
+		{"plus addressing", "user+ns@statcan.gc.ca", "user+ns@statcan.gc.ca", ""},
+		{"internationalized domain", "user@Bücher.example", "user@xn--bcher-kva.example", ""},
+		{"punycode domain", "user@XN--BCHER-KVA.example", "user@xn--bcher-kva.example", ""},
+		{"fullwidth domain", "user@ｓｔａｔｃａｎ.gc.ca", "user@statcan.gc.ca", ""},
+		{"empty", "", "", "no owner"},
+		{"only spaces", "   ", "", "no owner"},
+		{"only mailto", "mailto:", "", "no owner"},
+		{"no at", "statcan.gc.ca", "", "exactly one @"},
+		{"two ats", "a@b@statcan.gc.ca", "", "exactly one @"},
+		{"empty local part", "@statcan.gc.ca", "", "before the @ is empty"},
+		{"empty domain", "user@", "", "domain is empty"},
+		{"single label domain", "user@localhost", "", "no dot"},
+		{"invalid domain label", "user@-statcan.gc.ca", "", "invalid domain"},
+		{"underscore in domain", "user@stat_can.gc.ca", "", "invalid domain"},
+		{"display name", "User <user@statcan.gc.ca>", "", "invalid domain"},
+		{"space in local part", "first last@statcan.gc.ca", "", "mail:"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Normalize(tc.raw)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Expected an error with %q, got %q, %v", tc.wantErr, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize failed: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	aliases := map[string]string{"canada.ca": "statcan.gc.ca", "old.example.com": "New.Example.com"}
+
+	testCases := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr string
+	}{
+		{"single owner", "user@statcan.gc.ca", []string{"user@statcan.gc.ca"}, ""},
+		{"comma separated", "a@statcan.gc.ca,b@statcan.gc.ca", []string{"a@statcan.gc.ca", "b@statcan.gc.ca"}, ""},
+		{"semicolons and spaces", " a@statcan.gc.ca; b@statcan.gc.ca  c@statcan.gc.ca ", []string{"a@statcan.gc.ca", "b@statcan.gc.ca", "c@statcan.gc.ca"}, ""},
+		{"empty entries", "a@statcan.gc.ca,,;", []string{"a@statcan.gc.ca"}, ""},
+		{"duplicates dropped", "a@statcan.gc.ca, A@STATCAN.gc.ca, a@canada.ca", []string{"a@statcan.gc.ca"}, ""},
+		{"order kept", "b@statcan.gc.ca,a@statcan.gc.ca", []string{"b@statcan.gc.ca", "a@statcan.gc.ca"}, ""},
+		{"split mailto", "mailto: user@statcan.gc.ca", []string{"user@statcan.gc.ca"}, ""},
+		{"alias mapped", "user@Canada.ca", []string{"user@statcan.gc.ca"}, ""},
+		{"alias target normalized", "user@old.example.com", []string{"user@new.example.com"}, ""},
+		{"alias subdomains untouched", "user@sub.canada.ca", []string{"user@sub.canada.ca"}, ""},
+		{"empty", "", nil, "no owner"},
+		{"separators only", " , ; ", nil, "no owner"},
+		{"one bad owner fails all", "a@statcan.gc.ca, b@@statcan.gc.ca", nil, `owner "b@@statcan.gc.ca"`},
+		{"display name", "Jane Doe <jane@statcan.gc.ca>", nil, `owner "Jane"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse(tc.value, aliases)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Expected an error with %q, got %v, %v", tc.wantErr, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("Expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := Parse("", nil); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty, got %v", err)
+	}
+	if _, err := Parse("a@b@statcan.gc.ca", nil); !errors.Is(err, ErrMultipleAts) {
+		t.Errorf("Expected ErrMultipleAts, got %v", err)
+	}
+}
+
+func TestDomain(t *testing.T) {
+	testCases := []struct {
+		domain  string
+		want    string
+		wantErr bool
+	}{
+		{"StatCan.GC.CA", "statcan.gc.ca", false},
+		{" statcan.gc.ca. ", "statcan.gc.ca", false},
+		{"bücher.example", "xn--bcher-kva.example", false},
+		{"", "", true},
+		{"localhost", "", true},
+		{"bad_label.example", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.domain, func(t *testing.T) {
+			got, err := Domain(tc.domain)
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("Expected %q (error %v), got %q, %v", tc.want, tc.wantErr, got, err)
+			}
+		})
+	}
+}
+
+func TestPattern(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{"StatCan.GC.CA", "statcan.gc.ca", false},
+		{"*.Cloud.StatCan.ca", "*.cloud.statcan.ca", false},
+		{"dept-?.gc.ca.", "dept-?.gc.ca", false},
+		{"*.bücher.example", "*.xn--bcher-kva.example", false},
+		{"*..example", "", true},
+		{"*.bad_label.example", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			got, err := Pattern(tc.pattern)
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("Expected %q (error %v), got %q, %v", tc.want, tc.wantErr, got, err)
+			}
+		})
+	}
+}
+
+func TestMapAlias(t *testing.T) {
+	aliases := map[string]string{"canada.ca": "statcan.gc.ca", "bücher.example": "books.example", "broken.example": "-bad"}
+
+	testCases := []struct {
+		email string
+		want  string
+	}{
+		{"user@canada.ca", "user@statcan.gc.ca"},
+		{"User@canada.ca", "User@statcan.gc.ca"},
+		{"user@xn--bcher-kva.example", "user@books.example"},
+		{"user@statcan.gc.ca", "user@statcan.gc.ca"},
+		{"user@broken.example", "user@broken.example"},
+		{"not-an-email", "not-an-email"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.email, func(t *testing.T) {
+			if got := MapAlias(tc.email, aliases); got != tc.want {
+				t.Errorf("Expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+	if got := MapAlias("user@canada.ca", nil); got != "user@canada.ca" {
+		t.Errorf("Expected no mapping without aliases, got %q", got)
+	}
+}
@@ -0,0 +1,287 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/internal/logging"
+	"github.com/StatCan/namespace-cleaner/internal/notifier"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// ClusterPolicyGVR identifies NamespaceCleanupPolicy objects, defined by
+// manifests/namespacecleanuppolicy-crd.yaml
+var ClusterPolicyGVR = schema.GroupVersionResource{
+	Group:    "namespace-cleaner.statcan.gc.ca",
+	Version:  "v1alpha1",
+	Resource: "namespacecleanuppolicies",
+}
+
+// maxStatusErrors caps the errors reported in a policy's status
+const maxStatusErrors = 10
+
+// ClusterPolicy is a cluster-scoped NamespaceCleanupPolicy object. Each
+// one becomes a rule named after it.
+type ClusterPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterPolicySpec   `json:"spec"`
+	Status ClusterPolicyStatus `json:"status,omitempty"`
+}
+
+// ClusterPolicySpec holds the settings of a NamespaceCleanupPolicy
+type ClusterPolicySpec struct {
+	// Priority orders the policies; lower values are matched first and
+	// ties are broken by name
+	Priority        int                `json:"priority,omitempty"`
+	Selector        PolicySelector     `json:"selector,omitempty"`
+	GracePeriodDays *int               `json:"gracePeriodDays,omitempty"`
+	IdentitySource  string             `json:"identitySource,omitempty"`
+	Action          string             `json:"action,omitempty"`
+	Notification    PolicyNotification `json:"notification,omitempty"`
+	Limits          PolicyLimits       `json:"limits,omitempty"`
+}
+
+// PolicySelector selects the namespaces a policy applies to
+type PolicySelector struct {
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	NamePattern       string                `json:"namePattern,omitempty"`
+	OwnerDomains      []string              `json:"ownerDomains,omitempty"`
+}
+
+// PolicyNotification holds a policy's notification settings
+type PolicyNotification struct {
+	TemplateDir string   `json:"templateDir,omitempty"`
+	Events      []string `json:"events,omitempty"`
+}
+
+// PolicyLimits caps what a policy does in a run
+type PolicyLimits struct {
+	MaxDeletionsPerRun int `json:"maxDeletionsPerRun,omitempty"`
+}
+
+// ClusterPolicyStatus reports the last run of a policy. Errors is never
+// omitted so a merge patch clears those of earlier runs.
+type ClusterPolicyStatus struct {
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+	Matched            int          `json:"matched"`
+	Pending            int          `json:"pending"`
+	Deleted            int          `json:"deleted"`
+	LastRun            *metav1.Time `json:"lastRun,omitempty"`
+	Errors             []string     `json:"errors"`
+}
+
+// Rule converts the policy into a rule and validates it
+func (p *ClusterPolicy) Rule() (*Rule, error) {
+	rule := &Rule{
+		Name: p.Name,
+		Match: Match{
+			Selector:     p.Spec.Selector.NamespaceSelector,
+			Name:         p.Spec.Selector.NamePattern,
+			OwnerDomains: p.Spec.Selector.OwnerDomains,
+		},
+		GracePeriod:       p.Spec.GracePeriodDays,
+		Identity:          p.Spec.IdentitySource,
+		Action:            p.Spec.Action,
+		NotifyTemplateDir: p.Spec.Notification.TemplateDir,
+		NotifyEvents:      p.Spec.Notification.Events,
+		MaxDeletions:      p.Spec.Limits.MaxDeletionsPerRun,
+	}
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// ClusterSource reads rules from NamespaceCleanupPolicy objects into an
+// engine and reports each run back in their status
+type ClusterSource struct {
+	client    dynamic.Interface
+	notifier  *notifier.Notifier
+	cfg       *config.Config
+	dryRun    bool
+	engine    *Engine
+	fileRules []*Rule
+
+	policies []*ClusterPolicy
+	// invalid holds why policies were left out of the last refresh
+	invalid map[string]error
+}
+
+// NewClusterSource returns a source filling engine, as returned by Load,
+// or nil unless policies are read from the cluster. The notice templates
+// of the policies are loaded into n.
+func NewClusterSource(cfg *config.Config, client dynamic.Interface, n *notifier.Notifier, engine *Engine) *ClusterSource {
+	if cfg.PolicySource != config.PolicySourceCluster {
+		return nil
+	}
+	return &ClusterSource{
+		client:    client,
+		notifier:  n,
+		cfg:       cfg,
+		dryRun:    cfg.DryRun,
+		engine:    engine,
+		fileRules: engine.Rules,
+	}
+}
+
+// Refresh lists the policies and replaces the engine's rules with theirs,
+// in priority order and ahead of the policy file's rules. A policy that
+// fails validation is left out and its error reported in its status.
+func (s *ClusterSource) Refresh(ctx context.Context) error {
+	list, err := s.client.Resource(ClusterPolicyGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("listing cleanup policies: %w", err)
+	}
+
+	policies := make([]*ClusterPolicy, 0, len(list.Items))
+	for i := range list.Items {
+		policy := &ClusterPolicy{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].Object, policy); err != nil {
+			return fmt.Errorf("decoding cleanup policy %s: %w", list.Items[i].GetName(), err)
+		}
+		policies = append(policies, policy)
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].Spec.Priority != policies[j].Spec.Priority {
+			return policies[i].Spec.Priority < policies[j].Spec.Priority
+		}
+		return policies[i].Name < policies[j].Name
+	})
+
+	fileRules := map[string]bool{}
+	for _, rule := range s.fileRules {
+		fileRules[rule.Name] = true
+	}
+	s.policies = policies
+	s.invalid = map[string]error{}
+	var rules []*Rule
+	for _, policy := range policies {
+		rule, err := policy.Rule()
+		if err == nil && fileRules[rule.Name] {
+			err = fmt.Errorf("a rule of the policy file is also named %q", rule.Name)
+		}
+		if err == nil {
+			err = rule.checkIdentity(s.cfg)
+		}
+		if err == nil && rule.NotifyTemplateDir != "" {
+			err = s.notifier.LoadTemplateDir(rule.NotifyTemplateDir)
+		}
+		if err != nil {
+			s.invalid[policy.Name] = err
+			slog.Warn("Ignoring invalid cleanup policy", "policy", policy.Name, logging.KeyError, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	s.engine.Rules = append(rules, s.fileRules...)
+	return nil
+}
+
+// ReportStatus writes what the run did under each policy to its status
+func (s *ClusterSource) ReportStatus(ctx context.Context, runStats *stats.Stats, at time.Time) error {
+	var errs []error
+	for _, policy := range s.policies {
+		status := s.status(policy, runStats, at)
+		if s.dryRun {
+			slog.Info("Would update cleanup policy status", "policy", policy.Name,
+				"matched", status.Matched, "pending", status.Pending, "deleted", status.Deleted)
+			continue
+		}
+
+		patch, err := json.Marshal(map[string]interface{}{"status": status})
+		if err != nil {
+			return err
+		}
+		if _, err := s.client.Resource(ClusterPolicyGVR).Patch(ctx, policy.Name,
+			types.MergePatchType, patch, metav1.PatchOptions{}, "status"); err != nil {
+			errs = append(errs, fmt.Errorf("updating status of cleanup policy %s: %w", policy.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// status counts the decisions made under a policy's rule
+func (s *ClusterSource) status(policy *ClusterPolicy, runStats *stats.Stats, at time.Time) ClusterPolicyStatus {
+	status := ClusterPolicyStatus{
+		ObservedGeneration: policy.Generation,
+		LastRun:            &metav1.Time{Time: at},
+	}
+	if err, found := s.invalid[policy.Name]; found {
+		status.Errors = []string{err.Error()}
+		return status
+	}
+
+	for _, d := range runStats.Decisions {
+		if d.Rule != policy.Name {
+			continue
+		}
+		status.Matched++
+		switch d.Decision {
+		case stats.DecisionLabeled, stats.DecisionPending, stats.DecisionQuarantined:
+			status.Pending++
+		case stats.DecisionDeleted:
+			status.Deleted++
+		case stats.DecisionFailed:
+			if len(status.Errors) < maxStatusErrors {
+				status.Errors = append(status.Errors, d.Namespace+": "+d.Error)
+			}
+		}
+	}
+	return status
+}
+
+// Watch returns a channel signalled when a policy is created, deleted or
+// has its spec changed, until ctx is done. Status updates bump no
+// generation, so reporting a run does not signal.
+func (s *ClusterSource) Watch(ctx context.Context) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	signal := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(s.client, 0)
+	informer := factory.ForResource(ClusterPolicyGVR).Informer()
+	informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if !isInInitialList {
+				signal()
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if generation(oldObj) != generation(newObj) {
+				signal()
+			}
+		},
+		DeleteFunc: func(obj interface{}) { signal() },
+	})
+	factory.Start(ctx.Done())
+	return changes
+}
+
+// generation reads the generation of a watched object
+func generation(obj interface{}) int64 {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GetGeneration()
+	}
+	return 0
+}
@@ -0,0 +1,189 @@
+package policy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func newClusterPolicy(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	policy.SetAPIVersion(ClusterPolicyGVR.GroupVersion().String())
+	policy.SetKind("NamespaceCleanupPolicy")
+	policy.SetName(name)
+	policy.SetGeneration(2)
+	return policy
+}
+
+func newClusterSource(t *testing.T, file string, objects ...runtime.Object) (*ClusterSource, *Engine, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	engine, err := Parse([]byte(file))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{ClusterPolicyGVR: "NamespaceCleanupPolicyList"},
+		objects...,
+	)
+	cfg := &config.Config{PolicySource: config.PolicySourceCluster}
+	return NewClusterSource(cfg, client, nil, engine), engine, client
+}
+
+func TestClusterSourceRefresh(t *testing.T) {
+	source, engine, _ := newClusterSource(t, `rules: [{name: default, gracePeriod: 90}]`,
+		newClusterPolicy("sandbox", map[string]interface{}{
+			"priority": int64(20),
+			"selector": map[string]interface{}{
+				"namespaceSelector": map[string]interface{}{
+					"matchExpressions": []interface{}{
+						map[string]interface{}{"key": "tier", "operator": "In", "values": []interface{}{"sandbox", "dev"}},
+					},
+				},
+			},
+			"action": "label",
+		}),
+		newClusterPolicy("contractors", map[string]interface{}{
+			"priority":        int64(10),
+			"selector":        map[string]interface{}{"ownerDomains": []interface{}{"contractors.example.com"}},
+			"gracePeriodDays": int64(7),
+			"limits":          map[string]interface{}{"maxDeletionsPerRun": int64(5)},
+		}),
+		newClusterPolicy("broken", map[string]interface{}{
+			"selector": map[string]interface{}{"namePattern": "("},
+		}),
+		newClusterPolicy("default", map[string]interface{}{}),
+	)
+
+	if err := source.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	var names []string
+	for _, rule := range engine.Rules {
+		names = append(names, rule.Name)
+	}
+	if len(names) != 3 || names[0] != "contractors" || names[1] != "sandbox" || names[2] != "default" {
+		t.Fatalf("Expected policies in priority order ahead of the file rules, got %v", names)
+	}
+	if engine.Rules[0].MaxDeletions != 5 || *engine.Rules[0].GracePeriod != 7 {
+		t.Errorf("Unexpected contractors rule: %s", engine.Rules[0])
+	}
+	if source.invalid["broken"] == nil || source.invalid["default"] == nil {
+		t.Errorf("Expected the broken and clashing policies to be left out, got %v", source.invalid)
+	}
+
+	testCases := []struct {
+		nsName string
+		labels map[string]string
+		owner  string
+		want   string
+	}{
+		{"team-a", nil, "user@contractors.example.com", "contractors"},
+		{"team-a", map[string]string{"tier": "dev"}, "user@example.com", "sandbox"},
+		{"team-a", map[string]string{"tier": "prod"}, "user@example.com", "default"},
+	}
+	for _, tc := range testCases {
+		if rule := engine.Match(tc.nsName, tc.labels, tc.owner); rule == nil || rule.Name != tc.want {
+			t.Errorf("Expected %v to match %q, got %+v", tc.labels, tc.want, rule)
+		}
+	}
+
+	// Policies removed from the cluster no longer apply
+	source, engine, client := newClusterSource(t, `rules: [{name: default}]`, newClusterPolicy("sandbox", map[string]interface{}{}))
+	if err := source.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if err := client.Resource(ClusterPolicyGVR).Delete(context.TODO(), "sandbox", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := source.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if len(engine.Rules) != 1 || engine.Rules[0].Name != "default" {
+		t.Errorf("Expected only the file rule to remain, got %v", engine.Rules)
+	}
+}
+
+func TestClusterSourceGraphIdentityInTestMode(t *testing.T) {
+	source, engine, _ := newClusterSource(t, ``,
+		newClusterPolicy("staff", map[string]interface{}{"identitySource": "graph"}))
+	source.cfg = &config.Config{PolicySource: config.PolicySourceCluster, TestMode: true}
+
+	if err := source.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if len(engine.Rules) != 0 || source.invalid["staff"] == nil {
+		t.Errorf("Expected the graph policy to be left out in test mode, got %v, %v", engine.Rules, source.invalid)
+	}
+}
+
+func TestClusterSourceReportStatus(t *testing.T) {
+	source, _, client := newClusterSource(t, ``,
+		newClusterPolicy("contractors", map[string]interface{}{}),
+		newClusterPolicy("broken", map[string]interface{}{"action": "archive"}),
+	)
+	if err := source.Refresh(context.TODO()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	s := &stats.Stats{}
+	deleteAt := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	s.NewDecision("ns-a").Deleted("grace_period_expired")
+	s.NewDecision("ns-b").Labeled("owner_not_found", deleteAt)
+	s.NewDecision("ns-c").Fail("grace_period_expired", "delete failed")
+	s.NewDecision("ns-d").Skip("owner_exists")
+	for _, d := range s.Decisions[:3] {
+		d.Rule = "contractors"
+	}
+
+	at := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	if err := source.ReportStatus(context.TODO(), s, at); err != nil {
+		t.Fatalf("ReportStatus failed: %v", err)
+	}
+
+	read := func(name string) ClusterPolicyStatus {
+		obj, err := client.Resource(ClusterPolicyGVR).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		policy := &ClusterPolicy{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, policy); err != nil {
+			t.Fatalf("Decoding failed: %v", err)
+		}
+		return policy.Status
+	}
+
+	status := read("contractors")
+	if status.Matched != 3 || status.Pending != 1 || status.Deleted != 1 || status.ObservedGeneration != 2 {
+		t.Errorf("Unexpected status %+v", status)
+	}
+	if len(status.Errors) != 1 || status.Errors[0] != "ns-c: delete failed" {
+		t.Errorf("Expected the failed namespace in the errors, got %v", status.Errors)
+	}
+	if status.LastRun == nil || !status.LastRun.Time.Equal(at) {
+		t.Errorf("Expected last run %s, got %v", at, status.LastRun)
+	}
+	if status := read("broken"); len(status.Errors) != 1 || status.Matched != 0 {
+		t.Errorf("Expected the validation error in the status, got %+v", status)
+	}
+}
+
+func TestNewClusterSource(t *testing.T) {
+	if source := NewClusterSource(&config.Config{PolicySource: config.PolicySourceFile}, nil, nil, nil); source != nil {
+		t.Error("Expected no source when policies are read from the file")
+	}
+	engine, err := Load(&config.Config{PolicySource: config.PolicySourceCluster})
+	if err != nil || engine == nil {
+		t.Errorf("Expected an empty engine for cluster policies, got %v, %v", engine, err)
+	}
+}
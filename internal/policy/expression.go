@@ -0,0 +1,166 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+)
+
+// Supported values for Expression.Decision
+const (
+	DecisionSkip   = "skip"
+	DecisionLabel  = "label"
+	DecisionDelete = "delete"
+)
+
+// Variables expressions can refer to
+const (
+	VarNamespace = "ns"
+	VarOwner     = "owner"
+	VarAgeDays   = "ageDays"
+	VarIdleDays  = "idleDays"
+)
+
+// Expression is a CEL condition deciding what happens to the namespaces
+// it holds for
+type Expression struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Decision   string `json:"decision"`
+
+	program cel.Program
+}
+
+// Facts are what expressions are evaluated against
+type Facts struct {
+	Namespace   *corev1.Namespace
+	Owner       string
+	OwnerExists bool
+	// OwnerRecord is nil when the owner does not exist or the record was
+	// not read
+	OwnerRecord *clients.User
+	AgeDays     int
+	IdleDays    int
+}
+
+// newExpressionEnv declares the variables expressions can use. Namespace
+// and owner fields are dynamic; optional syntax such as
+// ns.labels[?"tier"].orValue("") reads labels that may be missing.
+func newExpressionEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.OptionalTypes(),
+		cel.Variable(VarNamespace, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(VarOwner, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(VarAgeDays, cel.IntType),
+		cel.Variable(VarIdleDays, cel.IntType),
+	)
+}
+
+// compile parses and type-checks an expression, which must be boolean,
+// and records the variables it refers to
+func (x *Expression) compile(env *cel.Env, uses map[string]bool) error {
+	switch x.Decision {
+	case DecisionSkip, DecisionLabel, DecisionDelete:
+	default:
+		return fmt.Errorf("unknown decision %q", x.Decision)
+	}
+
+	ast, issues := env.Compile(x.Expression)
+	if issues != nil && issues.Err() != nil {
+		return issues.Err()
+	}
+	if !ast.OutputType().IsExactType(cel.BoolType) {
+		return fmt.Errorf("expression returns %s, not bool", ast.OutputType())
+	}
+	checked, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return err
+	}
+	for _, ref := range checked.GetReferenceMap() {
+		uses[ref.GetName()] = true
+	}
+
+	x.program, err = env.Program(ast)
+	return err
+}
+
+// Uses reports whether any expression refers to a variable, so facts that
+// are costly to gather are only gathered when needed
+func (e *Engine) Uses(variable string) bool {
+	return e != nil && e.uses[variable]
+}
+
+// HasExpressions reports whether any expression is configured
+func (e *Engine) HasExpressions() bool {
+	return e != nil && len(e.Expressions) > 0
+}
+
+// Evaluate returns the first expression that holds, or nil. Expressions
+// failing to evaluate, such as on a missing map key, do not hold; their
+// errors are returned along with the result.
+func (e *Engine) Evaluate(facts Facts) (*Expression, error) {
+	if !e.HasExpressions() {
+		return nil, nil
+	}
+
+	vars := facts.variables()
+	var errs []error
+	for _, x := range e.Expressions {
+		out, _, err := x.program.Eval(vars)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("expression %q: %w", x.Name, err))
+			continue
+		}
+		if out == types.True {
+			return x, errors.Join(errs...)
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// variables lays the facts out as expression variables
+func (f Facts) variables() map[string]interface{} {
+	ns := map[string]interface{}{
+		"name":              f.Namespace.Name,
+		"labels":            stringMap(f.Namespace.Labels),
+		"annotations":       stringMap(f.Namespace.Annotations),
+		"creationTimestamp": f.Namespace.CreationTimestamp.Time,
+	}
+
+	owner := map[string]interface{}{
+		"email":          f.Owner,
+		"exists":         f.OwnerExists,
+		"displayName":    "",
+		"mail":           "",
+		"department":     "",
+		"jobTitle":       "",
+		"accountEnabled": false,
+	}
+	if r := f.OwnerRecord; r != nil {
+		owner["displayName"] = r.DisplayName
+		owner["mail"] = r.Mail
+		owner["department"] = r.Department
+		owner["jobTitle"] = r.JobTitle
+		owner["accountEnabled"] = r.AccountEnabled
+	}
+
+	return map[string]interface{}{
+		VarNamespace: ns,
+		VarOwner:     owner,
+		VarAgeDays:   f.AgeDays,
+		VarIdleDays:  f.IdleDays,
+	}
+}
+
+// stringMap never returns nil, so expressions can index an empty map
+func stringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
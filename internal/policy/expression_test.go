@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+)
+
+func TestParseCompilesExpressions(t *testing.T) {
+	testCases := map[string]string{
+		"syntax error":     `expressions: [{name: a, expression: "idleDays >", decision: skip}]`,
+		"not boolean":      `expressions: [{name: a, expression: "idleDays + 1", decision: skip}]`,
+		"unknown variable": `expressions: [{name: a, expression: "lastLogin > 60", decision: skip}]`,
+		"type mismatch":    `expressions: [{name: a, expression: "idleDays > \"60\"", decision: skip}]`,
+		"unknown decision": `expressions: [{name: a, expression: "idleDays > 60", decision: archive}]`,
+		"missing name":     `expressions: [{expression: "idleDays > 60", decision: skip}]`,
+		"duplicate name": `expressions: [{name: a, expression: "true", decision: skip},
+                                    {name: a, expression: "true", decision: label}]`,
+	}
+
+	for name, policy := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(policy)); err == nil {
+				t.Errorf("Expected an error for %s", policy)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	engine, err := Parse([]byte(`
+expressions:
+  - name: production
+    expression: 'ns.labels[?"tier"].orValue("") == "prod"'
+    decision: skip
+  - name: stale-das
+    expression: 'ns.labels["tier"] != "prod" && owner.department == "DAS" && idleDays > 60'
+    decision: delete
+  - name: old-and-orphaned
+    expression: '!owner.exists && ageDays > 365'
+    decision: label
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !engine.Uses(VarOwner) || !engine.Uses(VarIdleDays) || !engine.Uses(VarAgeDays) {
+		t.Error("Expected the variables used by the expressions to be recorded")
+	}
+
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	namespace := func(labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a", Labels: labels, CreationTimestamp: metav1.NewTime(created),
+		}}
+	}
+	das := &clients.User{Department: "DAS", AccountEnabled: true}
+
+	testCases := []struct {
+		name      string
+		facts     Facts
+		want      string
+		wantError bool
+	}{
+		{"production skipped", Facts{Namespace: namespace(map[string]string{"tier": "prod"}), OwnerExists: true, OwnerRecord: das, IdleDays: 90}, "production", false},
+		{"idle DAS namespace", Facts{Namespace: namespace(map[string]string{"tier": "dev"}), OwnerExists: true, OwnerRecord: das, IdleDays: 90}, "stale-das", false},
+		{"recently used", Facts{Namespace: namespace(map[string]string{"tier": "dev"}), OwnerExists: true, OwnerRecord: das, IdleDays: 10}, "", false},
+		{"other department", Facts{Namespace: namespace(map[string]string{"tier": "dev"}), OwnerExists: true, OwnerRecord: &clients.User{Department: "IT"}, IdleDays: 90}, "", false},
+		{"old orphan", Facts{Namespace: namespace(map[string]string{"tier": "dev"}), AgeDays: 400}, "old-and-orphaned", false},
+		// ns.labels["tier"] fails without the label, so stale-das does not hold
+		{"missing label", Facts{Namespace: namespace(nil), OwnerExists: true, OwnerRecord: das, IdleDays: 90}, "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expression, err := engine.Evaluate(tc.facts)
+			if (err != nil) != tc.wantError {
+				t.Errorf("Unexpected error %v", err)
+			}
+			got := ""
+			if expression != nil {
+				got = expression.Name
+			}
+			if got != tc.want {
+				t.Errorf("Expected %q to hold, got %q", tc.want, got)
+			}
+		})
+	}
+
+	var none *Engine
+	if expression, err := none.Evaluate(Facts{Namespace: namespace(nil)}); expression != nil || err != nil {
+		t.Errorf("Expected nothing without a policy, got %v, %v", expression, err)
+	}
+}
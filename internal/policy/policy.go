@@ -0,0 +1,305 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"github.com/StatCan/namespace-cleaner/internal/clients"
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+// Supported values for Rule.Action
+const (
+	// ActionLabel labels the namespace but never deletes it
+	ActionLabel = "label"
+	// ActionDelete deletes the namespace once the grace period is over
+	ActionDelete = "delete"
+	// ActionQuarantine stops the namespace's workloads before deleting it
+	ActionQuarantine = "quarantine"
+	// ActionTransfer hands the namespace to a successor before deleting it
+	ActionTransfer = "transfer"
+	// ActionIgnore leaves the namespace alone
+	ActionIgnore = "ignore"
+)
+
+// Supported values for Rule.Identity
+const (
+	IdentityGraph = "graph"
+	IdentityTest  = "test"
+)
+
+// Match selects the namespaces a rule applies to. Every criterion given
+// must hold; a rule without any matches every namespace.
+type Match struct {
+	// Labels must all be set on the namespace with these values
+	Labels map[string]string `json:"labels,omitempty"`
+	// Selector is a label selector the namespace must match, for set-based
+	// requirements labels cannot express
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// Name is a regular expression the namespace name must match
+	Name string `json:"name,omitempty"`
+	// OwnerDomains holds domains the owner must fall under, subdomains
+	// included
+	OwnerDomains []string `json:"ownerDomains,omitempty"`
+
+	name     *regexp.Regexp
+	selector labels.Selector
+}
+
+// Rule overrides the cleaner's settings for the namespaces it matches.
+// Settings left out keep their configured value.
+type Rule struct {
+	Name  string `json:"name"`
+	Match Match  `json:"match"`
+	// GracePeriod is the number of days between labeling and deletion
+	GracePeriod *int `json:"gracePeriod,omitempty"`
+	// Identity is where owners are looked up
+	Identity string `json:"identity,omitempty"`
+	Action   string `json:"action,omitempty"`
+	// NotifyTemplateDir holds the notice templates sent to owners
+	NotifyTemplateDir string `json:"notifyTemplateDir,omitempty"`
+	// NotifyEvents replaces the events owners are notified of
+	NotifyEvents []string `json:"notifyEvents,omitempty"`
+	// MaxDeletions caps the namespaces deleted under this rule per run.
+	// Zero means no limit.
+	MaxDeletions int `json:"maxDeletions,omitempty"`
+}
+
+// Engine holds the ordered rules and expressions read from the policy
+// file
+type Engine struct {
+	Rules       []*Rule       `json:"rules"`
+	Expressions []*Expression `json:"expressions,omitempty"`
+
+	// uses holds the variables referred to by expressions
+	uses map[string]bool
+}
+
+// Load reads and validates the policy file. It returns nil when no
+// policy file is configured, so every namespace follows the configuration,
+// and an empty engine for cluster policies to fill when they are read.
+func Load(cfg *config.Config) (*Engine, error) {
+	if cfg.PolicyFile == "" {
+		if cfg.PolicySource == config.PolicySourceCluster {
+			return &Engine{}, nil
+		}
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(cfg.PolicyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file: %w", err)
+	}
+	engine, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range engine.Rules {
+		if err := rule.checkIdentity(cfg); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return engine, nil
+}
+
+// Parse reads rules and expressions from YAML or JSON. Unknown fields are
+// rejected so a misspelled setting is not silently ignored, and
+// expressions are compiled and type-checked.
+func Parse(data []byte) (*Engine, error) {
+	engine := &Engine{}
+	if err := yaml.UnmarshalStrict(data, engine); err != nil {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for i, rule := range engine.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d has no name", i+1)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("rule %q is defined twice", rule.Name)
+		}
+		seen[rule.Name] = true
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+
+	if len(engine.Expressions) == 0 {
+		return engine, nil
+	}
+	env, err := newExpressionEnv()
+	if err != nil {
+		return nil, err
+	}
+	engine.uses = map[string]bool{}
+	seen = map[string]bool{}
+	for i, x := range engine.Expressions {
+		if x.Name == "" {
+			return nil, fmt.Errorf("expression %d has no name", i+1)
+		}
+		if seen[x.Name] {
+			return nil, fmt.Errorf("expression %q is defined twice", x.Name)
+		}
+		seen[x.Name] = true
+		if err := x.compile(env, engine.uses); err != nil {
+			return nil, fmt.Errorf("expression %q: %w", x.Name, err)
+		}
+	}
+	return engine, nil
+}
+
+// validate checks a rule's settings and compiles its name expression
+func (r *Rule) validate() error {
+	switch r.Action {
+	case "", ActionLabel, ActionDelete, ActionQuarantine, ActionTransfer, ActionIgnore:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	switch r.Identity {
+	case "", IdentityGraph, IdentityTest:
+	default:
+		return fmt.Errorf("unknown identity backend %q", r.Identity)
+	}
+	if r.GracePeriod != nil && *r.GracePeriod < 1 {
+		return fmt.Errorf("grace period must be at least 1 day, got %d", *r.GracePeriod)
+	}
+	if r.MaxDeletions < 0 {
+		return fmt.Errorf("max deletions cannot be negative, got %d", r.MaxDeletions)
+	}
+
+	if r.Match.Name != "" {
+		name, err := regexp.Compile(r.Match.Name)
+		if err != nil {
+			return fmt.Errorf("invalid name expression: %w", err)
+		}
+		r.Match.name = name
+	}
+	if r.Match.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(r.Match.Selector)
+		if err != nil {
+			return fmt.Errorf("invalid selector: %w", err)
+		}
+		r.Match.selector = selector
+	}
+	for i, domain := range r.Match.OwnerDomains {
+		r.Match.OwnerDomains[i] = strings.ToLower(strings.TrimSpace(domain))
+	}
+	return nil
+}
+
+// checkIdentity rejects a rule looking owners up in Graph when TEST_MODE
+// is on, since no Graph client is built in test mode
+func (r *Rule) checkIdentity(cfg *config.Config) error {
+	if r.Identity == IdentityGraph && cfg.TestMode {
+		return fmt.Errorf("identity %q needs TEST_MODE off, as no Graph client is built in test mode", IdentityGraph)
+	}
+	return nil
+}
+
+// Match returns the first rule matching a namespace and its owner, or nil
+// when none does
+func (e *Engine) Match(nsName string, nsLabels map[string]string, owner string) *Rule {
+	if e == nil {
+		return nil
+	}
+	for _, rule := range e.Rules {
+		if rule.Match.matches(nsName, nsLabels, owner) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// TemplateDirs lists the notice template dirs used by the rules
+func (e *Engine) TemplateDirs() []string {
+	if e == nil {
+		return nil
+	}
+	var dirs []string
+	for _, rule := range e.Rules {
+		if rule.NotifyTemplateDir != "" {
+			dirs = append(dirs, rule.NotifyTemplateDir)
+		}
+	}
+	return dirs
+}
+
+// matches reports whether every criterion holds
+func (m *Match) matches(nsName string, nsLabels map[string]string, owner string) bool {
+	for key, value := range m.Labels {
+		if actual, found := nsLabels[key]; !found || actual != value {
+			return false
+		}
+	}
+	if m.selector != nil && !m.selector.Matches(labels.Set(nsLabels)) {
+		return false
+	}
+	if m.name != nil && !m.name.MatchString(nsName) {
+		return false
+	}
+	if len(m.OwnerDomains) > 0 && !clients.ValidDomain(strings.ToLower(owner), m.OwnerDomains) {
+		return false
+	}
+	return true
+}
+
+// Apply returns a copy of cfg with the rule's settings. The quarantine and
+// transfer steps follow the rule's action when it sets one.
+func (r *Rule) Apply(cfg *config.Config) *config.Config {
+	applied := *cfg
+	if r.GracePeriod != nil {
+		applied.GracePeriod = *r.GracePeriod
+	}
+	switch r.Identity {
+	case IdentityGraph:
+		applied.TestMode = false
+	case IdentityTest:
+		applied.TestMode = true
+	}
+	if r.NotifyTemplateDir != "" {
+		applied.NotifyTemplateDir = r.NotifyTemplateDir
+	}
+	if r.NotifyEvents != nil {
+		applied.NotifyEvents = r.NotifyEvents
+	}
+	switch r.Action {
+	case ActionLabel, ActionDelete:
+		applied.QuarantineEnabled, applied.TransferEnabled = false, false
+	case ActionQuarantine:
+		applied.QuarantineEnabled, applied.TransferEnabled = true, false
+	case ActionTransfer:
+		applied.TransferEnabled = true
+	}
+	return &applied
+}
+
+// String summarizes what the rule changes, for explain
+func (r *Rule) String() string {
+	parts := []string{"action " + r.Action}
+	if r.Action == "" {
+		parts[0] = "action " + ActionDelete
+	}
+	if r.GracePeriod != nil {
+		parts = append(parts, fmt.Sprintf("grace period %d day(s)", *r.GracePeriod))
+	}
+	if r.Identity != "" {
+		parts = append(parts, "identity "+r.Identity)
+	}
+	if r.NotifyTemplateDir != "" {
+		parts = append(parts, "templates "+r.NotifyTemplateDir)
+	}
+	if r.NotifyEvents != nil {
+		parts = append(parts, "notify on "+strings.Join(r.NotifyEvents, ","))
+	}
+	if r.MaxDeletions > 0 {
+		parts = append(parts, fmt.Sprintf("at most %d deletion(s) per run", r.MaxDeletions))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,153 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+const samplePolicy = `
+rules:
+  - name: system
+    match:
+      name: "^(kube-|istio-)"
+    action: ignore
+  - name: contractors
+    match:
+      ownerDomains: [Contractors.Example.com]
+    gracePeriod: 7
+    maxDeletions: 5
+  - name: sandbox
+    match:
+      labels:
+        tier: sandbox
+    action: label
+    identity: test
+  - name: default
+    gracePeriod: 90
+`
+
+func TestMatch(t *testing.T) {
+	engine, err := Parse([]byte(samplePolicy))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		nsName string
+		labels map[string]string
+		owner  string
+		want   string
+	}{
+		{"name expression", "kube-system", nil, "user@contractors.example.com", "system"},
+		{"owner domain", "team-a", nil, "user@contractors.example.com", "contractors"},
+		{"owner subdomain", "team-a", nil, "user@ops.contractors.example.com", "contractors"},
+		{"label", "team-a", map[string]string{"tier": "sandbox"}, "user@example.com", "sandbox"},
+		{"label with another value", "team-a", map[string]string{"tier": "prod"}, "user@example.com", "default"},
+		{"catch-all", "team-a", nil, "user@example.com", "default"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule := engine.Match(tc.nsName, tc.labels, tc.owner)
+			if rule == nil || rule.Name != tc.want {
+				t.Errorf("Expected rule %q, got %+v", tc.want, rule)
+			}
+		})
+	}
+
+	var none *Engine
+	if rule := none.Match("team-a", nil, "user@example.com"); rule != nil {
+		t.Errorf("Expected no rule without a policy, got %+v", rule)
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	testCases := map[string]string{
+		"missing name":    "rules: [{action: delete}]",
+		"duplicate name":  "rules: [{name: a}, {name: a}]",
+		"unknown action":  "rules: [{name: a, action: archive}]",
+		"unknown backend": "rules: [{name: a, identity: ldap}]",
+		"zero grace":      "rules: [{name: a, gracePeriod: 0}]",
+		"negative limit":  "rules: [{name: a, maxDeletions: -1}]",
+		"bad expression":  "rules: [{name: a, match: {name: '('}}]",
+		"unknown field":   "rules: [{name: a, grace: 7}]",
+	}
+
+	for name, policy := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse([]byte(policy)); err == nil {
+				t.Errorf("Expected an error for %s", policy)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	engine, err := Load(&config.Config{})
+	if engine != nil || err != nil {
+		t.Errorf("Expected no policy without a file, got %v, %v", engine, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(samplePolicy), 0o644); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	engine, err = Load(&config.Config{PolicyFile: path})
+	if err != nil || len(engine.Rules) != 4 {
+		t.Fatalf("Expected 4 rules, got %v, %v", engine, err)
+	}
+
+	if _, err := Load(&config.Config{PolicyFile: path + ".missing"}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+
+	graphPath := filepath.Join(t.TempDir(), "graph.yaml")
+	if err := os.WriteFile(graphPath, []byte(`rules: [{name: staff, identity: graph}]`), 0o644); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if _, err := Load(&config.Config{PolicyFile: graphPath, TestMode: true}); err == nil || !strings.Contains(err.Error(), "TEST_MODE") {
+		t.Errorf("Expected a graph identity rule to be rejected in test mode, got %v", err)
+	}
+	if _, err := Load(&config.Config{PolicyFile: graphPath}); err != nil {
+		t.Errorf("Expected a graph identity rule outside test mode, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	grace := 7
+	cfg := &config.Config{GracePeriod: 30, QuarantineEnabled: true, TransferEnabled: true, NotifyTemplateDir: "/etc/templates"}
+
+	applied := (&Rule{GracePeriod: &grace, Identity: IdentityTest, Action: ActionDelete, NotifyTemplateDir: "/etc/contractors"}).Apply(cfg)
+	if applied.GracePeriod != 7 || !applied.TestMode || applied.QuarantineEnabled || applied.TransferEnabled ||
+		applied.NotifyTemplateDir != "/etc/contractors" {
+		t.Errorf("Unexpected settings %+v", applied)
+	}
+	if cfg.GracePeriod != 30 || cfg.TestMode || !cfg.QuarantineEnabled {
+		t.Errorf("Apply changed the configuration: %+v", cfg)
+	}
+
+	applied = (&Rule{}).Apply(cfg)
+	if applied.GracePeriod != 30 || !applied.QuarantineEnabled || !applied.TransferEnabled || applied.NotifyTemplateDir != "/etc/templates" {
+		t.Errorf("Expected an empty rule to keep the configuration, got %+v", applied)
+	}
+
+	applied = (&Rule{Action: ActionQuarantine}).Apply(&config.Config{TransferEnabled: true})
+	if !applied.QuarantineEnabled || applied.TransferEnabled {
+		t.Errorf("Expected quarantine only, got %+v", applied)
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	grace := 7
+	got := (&Rule{GracePeriod: &grace, Identity: IdentityGraph, MaxDeletions: 5}).String()
+	for _, want := range []string{"action delete", "grace period 7 day(s)", "identity graph", "at most 5 deletion(s)"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in %q", want, got)
+		}
+	}
+}
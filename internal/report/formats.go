@@ -0,0 +1,145 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+// extensions maps each report format to its file extension
+var extensions = map[string]string{
+	config.ReportFormatJSON:     "json",
+	config.ReportFormatYAML:     "yaml",
+	config.ReportFormatCSV:      "csv",
+	config.ReportFormatMarkdown: "md",
+}
+
+// csvHeader names the columns of a CSV report
+var csvHeader = []string{"namespace", "owner", "owner_source", "domain_rule", "lookup_strategy", "new_owner", "rule",
+	"expression", "decision", "reason", "previous_delete_at", "new_delete_at", "error"}
+
+// Render formats a report
+func Render(report Report, format string) ([]byte, error) {
+	switch format {
+	case config.ReportFormatJSON:
+		data, err := json.MarshalIndent(report, "", "  ")
+		return append(data, '\n'), err
+	case config.ReportFormatYAML:
+		return yaml.Marshal(report)
+	case config.ReportFormatCSV:
+		return renderCSV(report)
+	case config.ReportFormatMarkdown:
+		return renderMarkdown(report), nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q", format)
+	}
+}
+
+// renderCSV writes one row per namespace. The aggregate counts go in
+// leading # comment lines, which most CSV readers can skip.
+func renderCSV(report Report) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# generated_at=%s dry_run=%t\n", report.GeneratedAt.Format(time.RFC3339), report.DryRun)
+	for _, c := range summaryRows(report.Summary) {
+		fmt.Fprintf(&buf, "# %s=%d\n", c.name, c.count)
+	}
+
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	for _, d := range report.Namespaces {
+		row := []string{d.Namespace, d.Owner, d.OwnerSource, d.DomainRule, d.LookupStrategy, d.NewOwner, d.Rule,
+			d.Expression, d.Decision, d.Reason, formatTime(d.PreviousDeleteAt), formatTime(d.NewDeleteAt), d.Error}
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// renderMarkdown writes the summary and namespaces as tables
+func renderMarkdown(report Report) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Namespace cleaner report\n\nGenerated at %s", report.GeneratedAt.Format(time.RFC3339))
+	if report.DryRun {
+		buf.WriteString(" (dry run)")
+	}
+
+	buf.WriteString("\n\n## Summary\n\n| Count | Value |\n|-------|-------|\n")
+	for _, c := range summaryRows(report.Summary) {
+		fmt.Fprintf(&buf, "| %s | %d |\n", c.name, c.count)
+	}
+
+	buf.WriteString("\n## Namespaces\n\n| Namespace | Owner | Owner source | Domain rule | Lookup | New owner | Rule | " +
+		"Expression | Decision | Reason | Previous delete-at | New delete-at | Error |\n")
+	buf.WriteString("|-----------|-------|--------------|-------------|--------|-----------|------|" +
+		"------------|----------|--------|--------------------|---------------|-------|\n")
+	for _, d := range report.Namespaces {
+		fmt.Fprintf(&buf, "| %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s |\n",
+			cell(d.Namespace), cell(d.Owner), cell(d.OwnerSource), cell(d.DomainRule), d.LookupStrategy,
+			cell(d.NewOwner), cell(d.Rule), cell(d.Expression), d.Decision, d.Reason,
+			formatTime(d.PreviousDeleteAt), formatTime(d.NewDeleteAt), cell(d.Error))
+	}
+
+	if len(report.Errors) > 0 {
+		buf.WriteString("\n## Errors\n\n")
+		for _, e := range report.Errors {
+			fmt.Fprintf(&buf, "- %s\n", e)
+		}
+	}
+	return buf.Bytes()
+}
+
+type summaryRow struct {
+	name  string
+	count int
+}
+
+// summaryRows lists the aggregate counts in a fixed order
+func summaryRows(s Summary) []summaryRow {
+	return []summaryRow{
+		{"checked", s.Checked},
+		{"labeled", s.Labeled},
+		{"labeled_idle", s.LabeledIdle},
+		{"labeled_ownerless", s.LabeledOwnerless},
+		{"labels_removed", s.LabelsRemoved},
+		{"pending_deletion", s.PendingDeletion},
+		{"quarantined", s.Quarantined},
+		{"restored", s.Restored},
+		{"deleted", s.Deleted},
+		{"stuck", s.Stuck},
+		{"transferred", s.Transferred},
+		{"invalid_labels", s.InvalidLabels},
+		{"owners_inferred", s.OwnersInferred},
+		{"skipped_existing_user", s.SkippedExistingUser},
+		{"skipped_missing_owner", s.SkippedMissingOwner},
+		{"skipped_invalid_domain", s.SkippedInvalidDomain},
+		{"skipped_invalid_owner", s.SkippedInvalidOwner},
+		{"skipped_lookup_failed", s.SkippedLookupFailed},
+		{"skipped_recently_used", s.SkippedRecentlyUsed},
+		{"skipped_by_policy", s.SkippedByPolicy},
+		{"errors", s.Errors},
+	}
+}
+
+// formatTime renders an optional delete-at time, empty when unset
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// cell escapes characters that would break a Markdown table
+func cell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
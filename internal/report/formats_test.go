@@ -0,0 +1,73 @@
+package report
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+func TestRenderFormats(t *testing.T) {
+	testCases := []struct {
+		format string
+		want   []string
+	}{
+		{config.ReportFormatJSON, []string{`"decision": "labeled"`, `"newDeleteAt": "2023-02-01T00:00:00Z"`, `"checked": 3`}},
+		{config.ReportFormatYAML, []string{"decision: skipped", "reason: missing_owner", "dryRun: true"}},
+		{config.ReportFormatCSV, []string{"# checked=3", "namespace,owner,owner_source,domain_rule,lookup_strategy,new_owner,rule,expression,decision",
+			"ns-a,,,,,,,,labeled,owner_not_found,,2023-02-01T00:00:00Z,",
+			`ns-c,user@example.com,owner annotation,"example.com (allow, ALLOWED_DOMAINS)",upn,,employees,,failed,lookup_failed`}},
+		{config.ReportFormatMarkdown, []string{"(dry run)", "| checked | 3 |",
+			"| ns-c | user@example.com | owner annotation | example.com (allow, ALLOWED_DOMAINS) | upn |  | employees |  | failed | lookup_failed |",
+			"## Errors"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			data, err := Render(sampleReport(), tc.format)
+			if err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("Report should contain %q:\n%s", want, data)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderCSVRows(t *testing.T) {
+	data, err := Render(sampleReport(), config.ReportFormatCSV)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	r := csv.NewReader(strings.NewReader(string(data)))
+	r.Comment = '#'
+	rows, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v", err)
+	}
+	if len(rows) != 4 || len(rows[0]) != len(csvHeader) {
+		t.Errorf("Expected a header and 3 rows, got %v", rows)
+	}
+}
+
+func TestRenderYAMLRoundTrip(t *testing.T) {
+	data, err := Render(sampleReport(), config.ReportFormatYAML)
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	var got Report
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Invalid YAML: %v", err)
+	}
+	if got.Summary.Labeled != 1 || len(got.Namespaces) != 3 {
+		t.Errorf("Unexpected round trip %+v", got)
+	}
+}
@@ -0,0 +1,167 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// Report is the machine-readable record of one run
+type Report struct {
+	GeneratedAt time.Time         `json:"generatedAt"`
+	DryRun      bool              `json:"dryRun"`
+	Summary     Summary           `json:"summary"`
+	Namespaces  []*stats.Decision `json:"namespaces"`
+	Errors      []string          `json:"errors,omitempty"`
+}
+
+// Summary holds the aggregate counts of a run
+type Summary struct {
+	Checked              int `json:"checked"`
+	Labeled              int `json:"labeled"`
+	LabeledIdle          int `json:"labeledIdle"`
+	LabeledOwnerless     int `json:"labeledOwnerless"`
+	LabelsRemoved        int `json:"labelsRemoved"`
+	PendingDeletion      int `json:"pendingDeletion"`
+	Quarantined          int `json:"quarantined"`
+	Restored             int `json:"restored"`
+	Deleted              int `json:"deleted"`
+	Stuck                int `json:"stuck"`
+	Transferred          int `json:"transferred"`
+	InvalidLabels        int `json:"invalidLabels"`
+	OwnersInferred       int `json:"ownersInferred"`
+	SkippedExistingUser  int `json:"skippedExistingUser"`
+	SkippedMissingOwner  int `json:"skippedMissingOwner"`
+	SkippedInvalidDomain int `json:"skippedInvalidDomain"`
+	SkippedInvalidOwner  int `json:"skippedInvalidOwner"`
+	SkippedLookupFailed  int `json:"skippedLookupFailed"`
+	SkippedRecentlyUsed  int `json:"skippedRecentlyUsed"`
+	SkippedByPolicy      int `json:"skippedByPolicy"`
+	Errors               int `json:"errors"`
+}
+
+// New builds the report for a run
+func New(s *stats.Stats, dryRun bool, generatedAt time.Time) Report {
+	return Report{
+		GeneratedAt: generatedAt.UTC(),
+		DryRun:      dryRun,
+		Summary: Summary{
+			Checked:              s.TotalNamespaces,
+			Labeled:              s.Labeled,
+			LabeledIdle:          s.LabeledIdle,
+			LabeledOwnerless:     s.LabeledOwnerless,
+			LabelsRemoved:        s.LabelsRemoved,
+			PendingDeletion:      len(s.PendingDeletion),
+			Quarantined:          s.Quarantined,
+			Restored:             s.Restored,
+			Deleted:              s.Deleted,
+			Stuck:                s.Stuck,
+			Transferred:          s.Transferred,
+			InvalidLabels:        s.InvalidLabels,
+			OwnersInferred:       s.OwnersInferred,
+			SkippedExistingUser:  s.SkippedExistingUser,
+			SkippedMissingOwner:  s.SkippedMissingOwner,
+			SkippedInvalidDomain: s.SkippedInvalidDomain,
+			SkippedInvalidOwner:  s.SkippedInvalidOwner,
+			SkippedLookupFailed:  s.SkippedLookupFailed,
+			SkippedRecentlyUsed:  s.SkippedRecentlyUsed,
+			SkippedByPolicy:      s.SkippedByPolicy,
+			Errors:               len(s.Errors),
+		},
+		Namespaces: s.Decisions,
+		Errors:     s.Errors,
+	}
+}
+
+// Writer renders run reports and writes them to the configured output
+type Writer struct {
+	format     string
+	output     string
+	file       string
+	cmNs       string
+	cmName     string
+	kubeClient kubernetes.Interface
+}
+
+// NewWriter validates the report settings
+func NewWriter(cfg *config.Config, kubeClient kubernetes.Interface) (*Writer, error) {
+	if _, ok := extensions[cfg.ReportFormat]; !ok {
+		return nil, fmt.Errorf("unknown report format %q", cfg.ReportFormat)
+	}
+
+	w := &Writer{
+		format:     cfg.ReportFormat,
+		output:     cfg.ReportOutput,
+		file:       cfg.ReportFile,
+		kubeClient: kubeClient,
+	}
+
+	switch cfg.ReportOutput {
+	case config.ReportOutputStdout, config.ReportOutputNone:
+	case config.ReportOutputFile:
+		if cfg.ReportFile == "" {
+			return nil, fmt.Errorf("REPORT_FILE is required for file output")
+		}
+	case config.ReportOutputConfigMap:
+		parts := strings.Split(cfg.ReportConfigMap, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("report ConfigMap %q is not <namespace>/<name>", cfg.ReportConfigMap)
+		}
+		w.cmNs, w.cmName = parts[0], parts[1]
+	default:
+		return nil, fmt.Errorf("unknown report output %q", cfg.ReportOutput)
+	}
+	return w, nil
+}
+
+// Write renders a report and writes it out
+func (w *Writer) Write(ctx context.Context, report Report) error {
+	if w.output == config.ReportOutputNone {
+		return nil
+	}
+
+	data, err := Render(report, w.format)
+	if err != nil {
+		return err
+	}
+
+	switch w.output {
+	case config.ReportOutputFile:
+		return os.WriteFile(w.file, data, 0o644)
+	case config.ReportOutputConfigMap:
+		return w.writeConfigMap(ctx, data)
+	default:
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+}
+
+// writeConfigMap stores the report under report.<ext>, replacing the
+// previous run's report
+func (w *Writer) writeConfigMap(ctx context.Context, data []byte) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      w.cmName,
+			Namespace: w.cmNs,
+			Labels:    map[string]string{"app.kubernetes.io/name": "namespace-cleaner"},
+		},
+		Data: map[string]string{"report." + extensions[w.format]: string(data)},
+	}
+
+	configMaps := w.kubeClient.CoreV1().ConfigMaps(w.cmNs)
+	_, err := configMaps.Create(ctx, cm, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	return err
+}
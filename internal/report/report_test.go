@@ -0,0 +1,117 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func sampleReport() Report {
+	deleteAt := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	s := &stats.Stats{TotalNamespaces: 3}
+	s.AddLabeled("ns-a")
+	s.AddPending("ns-a", deleteAt)
+	s.NewDecision("ns-a").Labeled("owner_not_found", deleteAt)
+	s.NewDecision("ns-b").Skip("missing_owner")
+	failed := s.NewDecision("ns-c")
+	failed.Owner = "user@example.com"
+	failed.OwnerSource = "owner annotation"
+	failed.DomainRule = "example.com (allow, ALLOWED_DOMAINS)"
+	failed.LookupStrategy = "upn"
+	failed.Rule = "employees"
+	failed.Fail("lookup_failed", "Error looking up owner of ns-c: throttled")
+	s.AddError(failed.Error)
+
+	return New(s, true, time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC))
+}
+
+func TestNew(t *testing.T) {
+	r := sampleReport()
+
+	if r.Summary.Checked != 3 || r.Summary.Labeled != 1 || r.Summary.PendingDeletion != 1 || r.Summary.Errors != 1 {
+		t.Errorf("Unexpected summary %+v", r.Summary)
+	}
+	if len(r.Namespaces) != 3 || r.Namespaces[2].Error == "" {
+		t.Errorf("Unexpected namespaces %+v", r.Namespaces)
+	}
+}
+
+func TestNewWriterRejectsInvalidSettings(t *testing.T) {
+	testCases := []config.Config{
+		{ReportFormat: "xml", ReportOutput: config.ReportOutputStdout},
+		{ReportFormat: config.ReportFormatJSON, ReportOutput: "s3"},
+		{ReportFormat: config.ReportFormatJSON, ReportOutput: config.ReportOutputFile},
+		{ReportFormat: config.ReportFormatJSON, ReportOutput: config.ReportOutputConfigMap, ReportConfigMap: "report"},
+	}
+
+	for _, cfg := range testCases {
+		if _, err := NewWriter(&cfg, nil); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	w, err := NewWriter(&config.Config{
+		ReportFormat: config.ReportFormatJSON,
+		ReportOutput: config.ReportOutputFile,
+		ReportFile:   path,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	if err := w.Write(context.TODO(), sampleReport()); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Report not written: %v", err)
+	}
+	var got Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Invalid JSON report: %v", err)
+	}
+	if len(got.Namespaces) != 3 || got.Namespaces[0].NewDeleteAt == nil {
+		t.Errorf("Unexpected namespaces %+v", got.Namespaces)
+	}
+}
+
+func TestWriteConfigMap(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	w, err := NewWriter(&config.Config{
+		ReportFormat:    config.ReportFormatYAML,
+		ReportOutput:    config.ReportOutputConfigMap,
+		ReportConfigMap: "das/cleaner-report",
+	}, client)
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+
+	// The second write replaces the first run's report
+	for i := 0; i < 2; i++ {
+		if err := w.Write(context.TODO(), sampleReport()); err != nil {
+			t.Fatalf("Write %d failed: %v", i, err)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("das").Get(context.TODO(), "cleaner-report", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("ConfigMap not created: %v", err)
+	}
+	if _, ok := cm.Data["report.yaml"]; !ok {
+		t.Errorf("Expected report.yaml key, got %v", cm.Data)
+	}
+}
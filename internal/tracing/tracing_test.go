@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+)
+
+// useRecorder routes spans to an in-memory recorder for one test
+func useRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+	return recorder
+}
+
+func TestExporterOptions(t *testing.T) {
+	testCases := []struct {
+		endpoint string
+		want     int
+		wantErr  bool
+	}{
+		{"http://collector:4318", 2, false},
+		{"https://collector:4318", 1, false},
+		{"https://collector:4318/otlp/v1/traces", 2, false},
+		{"collector:4318", 0, true},
+		{"grpc://collector:4317", 0, true},
+	}
+
+	for _, tc := range testCases {
+		opts, err := exporterOptions(tc.endpoint)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error %v", tc.endpoint, err)
+		}
+		if len(opts) != tc.want {
+			t.Errorf("%s: expected %d options, got %d", tc.endpoint, tc.want, len(opts))
+		}
+	}
+}
+
+func TestTransportPropagatesContext(t *testing.T) {
+	recorder := useRecorder(t)
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	ctx, parent := Start(context.Background(), "parent")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1.0/users/someone", nil)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	if req.Header.Get("traceparent") != "" {
+		t.Error("The caller's request should not be modified")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	client := spans[0]
+	if client.Name() != "HTTP GET" || client.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("Expected an HTTP GET child span, got %s", client.Name())
+	}
+	if client.Status().Code != codes.Error {
+		t.Errorf("Expected a 404 to mark the span as failed, got %v", client.Status())
+	}
+	want := "00-" + client.SpanContext().TraceID().String() + "-" + client.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("Expected traceparent %s, got %q", want, traceparent)
+	}
+}
+
+func TestEndRecordsError(t *testing.T) {
+	recorder := useRecorder(t)
+
+	_, span := Start(context.Background(), "failing")
+	End(span, errors.New("boom"))
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error || spans[0].Status().Description != "boom" {
+		t.Errorf("Expected an error status, got %+v", spans)
+	}
+}
+
+func TestSetupExportsSpans(t *testing.T) {
+	original := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(original)
+
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	shutdown, err := Setup(context.Background(), &config.Config{TracingEndpoint: server.URL})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	_, span := Start(context.Background(), "run")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 1 || paths[0] != "/v1/traces" {
+		t.Errorf("Expected one export to /v1/traces, got %v", paths)
+	}
+}
+
+func TestSetupWithoutEndpoint(t *testing.T) {
+	shutdown, err := Setup(context.Background(), &config.Config{})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Expected a no-op shutdown, got %v", err)
+	}
+}
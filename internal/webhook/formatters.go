@@ -0,0 +1,107 @@
+package webhook
+
+import "strconv"
+
+// reportTitle heads every message
+const reportTitle = "Namespace cleaner report"
+
+// section is a titled list of namespaces or errors
+type section struct {
+	title   string
+	entries []string
+}
+
+// sections returns the non-empty lists of a report
+func sections(report Report) []section {
+	all := []section{
+		{"Labeled", report.Stats.LabeledNamespaces},
+		{"Deleted", report.Stats.DeletedNamespaces},
+		{"Un-labeled", report.Stats.LabelRemovedNamespaces},
+		{"Errors", report.Stats.Errors},
+	}
+
+	var nonEmpty []section
+	for _, s := range all {
+		if len(s.entries) > 0 {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return nonEmpty
+}
+
+// counts returns the aggregate figures shown as facts or fields
+func counts(report Report) [][2]string {
+	s := report.Stats
+	return [][2]string{
+		{"Checked", strconv.Itoa(s.TotalNamespaces)},
+		{"Labeled", strconv.Itoa(s.Labeled)},
+		{"Deleted", strconv.Itoa(s.Deleted)},
+		{"Un-labeled", strconv.Itoa(s.LabelsRemoved)},
+		{"Quarantined", strconv.Itoa(s.Quarantined)},
+		{"Errors", strconv.Itoa(len(s.Errors))},
+	}
+}
+
+// teamsMessage builds an Adaptive Card for a Teams incoming webhook
+func teamsMessage(report Report) map[string]interface{} {
+	facts := []map[string]string{}
+	for _, c := range counts(report) {
+		facts = append(facts, map[string]string{"title": c[0], "value": c[1]})
+	}
+
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": reportTitle, "weight": "Bolder", "size": "Medium"},
+		{"type": "TextBlock", "text": report.Summary, "wrap": true},
+		{"type": "FactSet", "facts": facts},
+	}
+	for _, s := range sections(report) {
+		body = append(body,
+			map[string]interface{}{"type": "TextBlock", "text": s.title, "weight": "Bolder", "separator": true},
+			map[string]interface{}{"type": "TextBlock", "text": listed(s.entries), "wrap": true},
+		)
+	}
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+				},
+			},
+		},
+	}
+}
+
+// slackMessage builds a Block Kit message for a Slack incoming webhook
+func slackMessage(report Report) map[string]interface{} {
+	fields := []map[string]string{}
+	for _, c := range counts(report) {
+		fields = append(fields, map[string]string{"type": "mrkdwn", "text": "*" + c[0] + ":* " + c[1]})
+	}
+
+	blocks := []map[string]interface{}{
+		{"type": "header", "text": map[string]string{"type": "plain_text", "text": reportTitle}},
+		{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": report.Summary}},
+		{"type": "section", "fields": fields},
+	}
+	for _, s := range sections(report) {
+		blocks = append(blocks,
+			map[string]interface{}{"type": "divider"},
+			map[string]interface{}{
+				"type": "section",
+				"text": map[string]string{"type": "mrkdwn", "text": "*" + s.title + "*\n```" + listed(s.entries) + "```"},
+			},
+		)
+	}
+
+	return map[string]interface{}{
+		// Shown in notifications and clients without Block Kit support
+		"text":   report.Summary,
+		"blocks": blocks,
+	}
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+func sampleReport() Report {
+	s := &stats.Stats{TotalNamespaces: 4}
+	s.AddLabeled("ns-labeled")
+	s.AddDeleted("ns-deleted")
+	s.AddLabelRemoved("ns-back")
+	s.AddError("Error deleting ns ns-stuck: timeout")
+	return Report{Summary: "summary line", Stats: s}
+}
+
+func TestTeamsMessage(t *testing.T) {
+	data, err := json.Marshal(teamsMessage(sampleReport()))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	payload := string(data)
+
+	for _, want := range []string{
+		`"contentType":"application/vnd.microsoft.card.adaptive"`,
+		`"type":"AdaptiveCard"`,
+		`"type":"FactSet"`,
+		`"summary line"`,
+		"ns-labeled", "ns-deleted", "ns-back", "ns-stuck",
+	} {
+		if !strings.Contains(payload, want) {
+			t.Errorf("Teams payload should contain %s:\n%s", want, payload)
+		}
+	}
+}
+
+func TestSlackMessage(t *testing.T) {
+	data, err := json.Marshal(slackMessage(sampleReport()))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	payload := string(data)
+
+	for _, want := range []string{
+		`"text":"summary line"`,
+		`"type":"header"`,
+		`"type":"divider"`,
+		"ns-labeled", "ns-deleted", "ns-back", "ns-stuck",
+	} {
+		if !strings.Contains(payload, want) {
+			t.Errorf("Slack payload should contain %s:\n%s", want, payload)
+		}
+	}
+}
+
+func TestSectionsSkipEmptyLists(t *testing.T) {
+	report := Report{Stats: &stats.Stats{}}
+	report.Stats.AddDeleted("ns-deleted")
+
+	got := sections(report)
+	if len(got) != 1 || got[0].title != "Deleted" {
+		t.Errorf("Expected only the Deleted section, got %+v", got)
+	}
+}
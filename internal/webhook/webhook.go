@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// defaultTemplate renders the one-line summary at the top of a report
+const defaultTemplate = `Namespace cleaner{{if .DryRun}} (dry run){{end}}: ` +
+	`{{.Stats.Labeled}} labeled, {{.Stats.Deleted}} deleted, ` +
+	`{{.Stats.LabelsRemoved}} unlabeled, {{len .Stats.Errors}} errors ` +
+	`out of {{.Stats.TotalNamespaces}} namespaces checked`
+
+// maxListed caps how many namespaces or errors are listed per section
+const maxListed = 50
+
+// Report is the data passed to the summary template and formatters
+type Report struct {
+	Summary string
+	DryRun  bool
+	Stats   *stats.Stats
+}
+
+// Reporter posts run reports to a Teams or Slack incoming webhook
+type Reporter struct {
+	url       string
+	format    string
+	threshold string
+	retries   int
+	dryRun    bool
+	summary   *template.Template
+	client    *http.Client
+	backoff   time.Duration
+}
+
+// New creates a reporter from configuration. It returns nil when no
+// webhook URL is configured, which disables reports.
+func New(cfg *config.Config) (*Reporter, error) {
+	if cfg.WebhookURL == "" {
+		return nil, nil
+	}
+
+	switch cfg.WebhookFormat {
+	case config.WebhookFormatTeams, config.WebhookFormatSlack:
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", cfg.WebhookFormat)
+	}
+	switch cfg.WebhookThreshold {
+	case config.WebhookThresholdAlways, config.WebhookThresholdDeletions, config.WebhookThresholdErrors:
+	default:
+		return nil, fmt.Errorf("unknown webhook threshold %q", cfg.WebhookThreshold)
+	}
+
+	text := cfg.WebhookTemplate
+	if text == "" {
+		text = defaultTemplate
+	}
+	summary, err := template.New("summary").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook template: %w", err)
+	}
+
+	return &Reporter{
+		url:       cfg.WebhookURL,
+		format:    cfg.WebhookFormat,
+		threshold: cfg.WebhookThreshold,
+		retries:   cfg.WebhookRetries,
+		dryRun:    cfg.DryRun,
+		summary:   summary,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		backoff:   2 * time.Second,
+	}, nil
+}
+
+// ShouldSend reports whether a run passes the configured threshold
+func (r *Reporter) ShouldSend(s *stats.Stats) bool {
+	switch r.threshold {
+	case config.WebhookThresholdErrors:
+		return len(s.Errors) > 0
+	case config.WebhookThresholdDeletions:
+		return s.Deleted > 0 || len(s.Errors) > 0
+	default:
+		return true
+	}
+}
+
+// Send posts the report for a run, retrying transient failures
+func (r *Reporter) Send(ctx context.Context, s *stats.Stats) error {
+	if !r.ShouldSend(s) {
+		return nil
+	}
+
+	payload, err := r.payload(s)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= r.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(r.backoff << (attempt - 1)):
+			}
+		}
+
+		retry, err := r.post(ctx, payload)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return fmt.Errorf("posting webhook report: %w", lastErr)
+}
+
+// payload renders the summary and formats it for the target service
+func (r *Reporter) payload(s *stats.Stats) ([]byte, error) {
+	report := Report{DryRun: r.dryRun, Stats: s}
+
+	var summary bytes.Buffer
+	if err := r.summary.Execute(&summary, report); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	report.Summary = strings.TrimSpace(summary.String())
+
+	if r.format == config.WebhookFormatSlack {
+		return json.Marshal(slackMessage(report))
+	}
+	return json.Marshal(teamsMessage(report))
+}
+
+// post sends one request and reports whether a failure is worth retrying
+func (r *Reporter) post(ctx context.Context, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+// listed joins up to maxListed entries, noting how many were left out
+func listed(entries []string) string {
+	if len(entries) <= maxListed {
+		return strings.Join(entries, "\n")
+	}
+	return strings.Join(entries[:maxListed], "\n") + fmt.Sprintf("\n... and %d more", len(entries)-maxListed)
+}
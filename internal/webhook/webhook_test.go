@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/StatCan/namespace-cleaner/internal/config"
+	"github.com/StatCan/namespace-cleaner/pkg/stats"
+)
+
+// recorder is a local stand-in for a chat webhook that fails the first
+// few requests with a given status
+type recorder struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	bodies   []string
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.bodies = append(rec.bodies, string(body))
+	if len(rec.bodies) <= rec.failures {
+		w.WriteHeader(rec.status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func newReporter(t *testing.T, url string, cfg config.Config) *Reporter {
+	t.Helper()
+	cfg.WebhookURL = url
+	if cfg.WebhookFormat == "" {
+		cfg.WebhookFormat = config.WebhookFormatTeams
+	}
+	if cfg.WebhookThreshold == "" {
+		cfg.WebhookThreshold = config.WebhookThresholdAlways
+	}
+
+	r, err := New(&cfg)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	r.backoff = time.Millisecond
+	return r
+}
+
+func sampleStats() *stats.Stats {
+	s := &stats.Stats{TotalNamespaces: 3}
+	s.AddLabeled("ns-a")
+	s.AddDeleted("ns-b")
+	return s
+}
+
+func TestNewDisabledWithoutURL(t *testing.T) {
+	r, err := New(&config.Config{})
+	if err != nil || r != nil {
+		t.Errorf("Expected nil reporter without URL, got %v (%v)", r, err)
+	}
+}
+
+func TestNewRejectsInvalidSettings(t *testing.T) {
+	testCases := []config.Config{
+		{WebhookURL: "http://x", WebhookFormat: "irc", WebhookThreshold: config.WebhookThresholdAlways},
+		{WebhookURL: "http://x", WebhookFormat: config.WebhookFormatSlack, WebhookThreshold: "sometimes"},
+		{WebhookURL: "http://x", WebhookFormat: config.WebhookFormatSlack, WebhookThreshold: config.WebhookThresholdAlways, WebhookTemplate: "{{.Missing"},
+	}
+
+	for _, cfg := range testCases {
+		if _, err := New(&cfg); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestSendRetriesServerErrors(t *testing.T) {
+	rec := &recorder{failures: 2, status: http.StatusBadGateway}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	r := newReporter(t, server.URL, config.Config{WebhookRetries: 3})
+	if err := r.Send(context.TODO(), sampleStats()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(rec.bodies) != 3 {
+		t.Errorf("Expected 3 attempts, got %d", len(rec.bodies))
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	testCases := []struct {
+		name         string
+		status       int
+		wantAttempts int
+	}{
+		{"client error is not retried", http.StatusBadRequest, 1},
+		{"server error exhausts retries", http.StatusInternalServerError, 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &recorder{failures: 10, status: tc.status}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			r := newReporter(t, server.URL, config.Config{WebhookRetries: 2})
+			if err := r.Send(context.TODO(), sampleStats()); err == nil {
+				t.Error("Expected an error")
+			}
+			if len(rec.bodies) != tc.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tc.wantAttempts, len(rec.bodies))
+			}
+		})
+	}
+}
+
+func TestShouldSendThreshold(t *testing.T) {
+	quiet := &stats.Stats{TotalNamespaces: 5}
+	quiet.AddLabeled("ns-a")
+	withDeletion := sampleStats()
+	withError := &stats.Stats{}
+	withError.AddError("Error listing namespaces: boom")
+
+	testCases := []struct {
+		threshold string
+		stats     *stats.Stats
+		want      bool
+	}{
+		{config.WebhookThresholdAlways, quiet, true},
+		{config.WebhookThresholdDeletions, quiet, false},
+		{config.WebhookThresholdDeletions, withDeletion, true},
+		{config.WebhookThresholdDeletions, withError, true},
+		{config.WebhookThresholdErrors, withDeletion, false},
+		{config.WebhookThresholdErrors, withError, true},
+	}
+
+	for _, tc := range testCases {
+		r := newReporter(t, "http://unused", config.Config{WebhookThreshold: tc.threshold})
+		if got := r.ShouldSend(tc.stats); got != tc.want {
+			t.Errorf("%s: ShouldSend = %v, want %v", tc.threshold, got, tc.want)
+		}
+	}
+}
+
+func TestSendSkipsBelowThreshold(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	r := newReporter(t, server.URL, config.Config{WebhookThreshold: config.WebhookThresholdErrors})
+	if err := r.Send(context.TODO(), sampleStats()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(rec.bodies) != 0 {
+		t.Errorf("Expected no request, got %d", len(rec.bodies))
+	}
+}
+
+func TestSendCustomTemplate(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	r := newReporter(t, server.URL, config.Config{
+		DryRun:          true,
+		WebhookFormat:   config.WebhookFormatSlack,
+		WebhookTemplate: `{{if .DryRun}}[dry run] {{end}}{{.Stats.Deleted}} gone`,
+	})
+	if err := r.Send(context.TODO(), sampleStats()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal([]byte(rec.bodies[0]), &msg); err != nil {
+		t.Fatalf("Invalid JSON payload: %v", err)
+	}
+	if msg["text"] != "[dry run] 1 gone" {
+		t.Errorf("Unexpected summary %q", msg["text"])
+	}
+}
+
+func TestListedCapsEntries(t *testing.T) {
+	entries := make([]string, maxListed+5)
+	for i := range entries {
+		entries[i] = "ns"
+	}
+	if got := listed(entries); !strings.HasSuffix(got, "... and 5 more") {
+		t.Errorf("Expected truncation note, got %q", got[len(got)-20:])
+	}
+}
@@ -0,0 +1,105 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+)
+
+// Decisions recorded for a namespace
+const (
+	DecisionLabeled      = "labeled"
+	DecisionLabelRemoved = "label_removed"
+	DecisionPending      = "pending"
+	DecisionQuarantined  = "quarantined"
+	DecisionDeleted      = "deleted"
+	DecisionTransferred  = "transferred"
+	DecisionSkipped      = "skipped"
+	DecisionFailed       = "failed"
+)
+
+// Decision records what a run did with one namespace and why
+type Decision struct {
+	Namespace        string     `json:"namespace"`
+	Owner            string     `json:"owner,omitempty"`
+	OwnerSource      string     `json:"ownerSource,omitempty"`
+	DomainRule       string     `json:"domainRule,omitempty"`
+	LookupStrategy   string     `json:"lookupStrategy,omitempty"`
+	NewOwner         string     `json:"newOwner,omitempty"`
+	Rule             string     `json:"rule,omitempty"`
+	Expression       string     `json:"expression,omitempty"`
+	Decision         string     `json:"decision"`
+	Reason           string     `json:"reason"`
+	PreviousDeleteAt *time.Time `json:"previousDeleteAt,omitempty"`
+	NewDeleteAt      *time.Time `json:"newDeleteAt,omitempty"`
+	Error            string     `json:"error,omitempty"`
+
+	// Checks lists the steps that led to the decision, for explain
+	Checks []Check `json:"-"`
+}
+
+// Check is one step of a namespace's evaluation and its outcome
+type Check struct {
+	Name   string
+	Result string
+}
+
+// NewDecision starts the record for a namespace. The caller fills it in
+// as processing goes on.
+func (s *Stats) NewDecision(ns string) *Decision {
+	d := &Decision{Namespace: ns}
+	s.Decisions = append(s.Decisions, d)
+	return d
+}
+
+// AddCheck records a step of the evaluation
+func (d *Decision) AddCheck(name, format string, args ...interface{}) {
+	d.Checks = append(d.Checks, Check{Name: name, Result: fmt.Sprintf(format, args...)})
+}
+
+// Skip records that the namespace was left alone
+func (d *Decision) Skip(reason string) {
+	d.set(DecisionSkipped, reason, d.PreviousDeleteAt)
+}
+
+// Fail records that acting on the namespace failed
+func (d *Decision) Fail(reason, err string) {
+	d.set(DecisionFailed, reason, d.PreviousDeleteAt)
+	d.Error = err
+}
+
+// Labeled records a new delete-at label
+func (d *Decision) Labeled(reason string, deleteAt time.Time) {
+	d.set(DecisionLabeled, reason, &deleteAt)
+}
+
+// LabelRemoved records that the delete-at label was removed
+func (d *Decision) LabelRemoved(reason string) {
+	d.set(DecisionLabelRemoved, reason, nil)
+}
+
+// Pending records a namespace still waiting for deletion
+func (d *Decision) Pending(reason string, deleteAt time.Time) {
+	d.set(DecisionPending, reason, &deleteAt)
+}
+
+// Quarantined records a namespace quarantined until purgeAt
+func (d *Decision) Quarantined(purgeAt time.Time) {
+	d.set(DecisionQuarantined, "grace_period_expired", &purgeAt)
+}
+
+// Deleted records a deleted namespace
+func (d *Decision) Deleted(reason string) {
+	d.set(DecisionDeleted, reason, nil)
+}
+
+// Transferred records that the namespace was handed to a new owner
+func (d *Decision) Transferred(newOwner string) {
+	d.set(DecisionTransferred, "owner_transferred", nil)
+	d.NewOwner = newOwner
+}
+
+func (d *Decision) set(decision, reason string, deleteAt *time.Time) {
+	d.Decision = decision
+	d.Reason = reason
+	d.NewDeleteAt = deleteAt
+}
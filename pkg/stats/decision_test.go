@@ -0,0 +1,44 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecisions(t *testing.T) {
+	previous := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	purge := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		apply        func(d *Decision)
+		wantDecision string
+		wantReason   string
+		wantNew      *time.Time
+	}{
+		{"skip keeps label", func(d *Decision) { d.Skip("invalid_domain") }, DecisionSkipped, "invalid_domain", &previous},
+		{"fail keeps label", func(d *Decision) { d.Fail("grace_period_expired", "boom") }, DecisionFailed, "grace_period_expired", &previous},
+		{"label removed", func(d *Decision) { d.LabelRemoved("owner_found") }, DecisionLabelRemoved, "owner_found", nil},
+		{"quarantined", func(d *Decision) { d.Quarantined(purge) }, DecisionQuarantined, "grace_period_expired", &purge},
+		{"deleted", func(d *Decision) { d.Deleted("grace_period_expired") }, DecisionDeleted, "grace_period_expired", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &Stats{}
+			d := s.NewDecision("test-ns")
+			d.PreviousDeleteAt = &previous
+			tc.apply(d)
+
+			if len(s.Decisions) != 1 || s.Decisions[0] != d {
+				t.Fatalf("Decision not recorded: %v", s.Decisions)
+			}
+			if d.Decision != tc.wantDecision || d.Reason != tc.wantReason {
+				t.Errorf("Got %s/%s, want %s/%s", d.Decision, d.Reason, tc.wantDecision, tc.wantReason)
+			}
+			if (d.NewDeleteAt == nil) != (tc.wantNew == nil) || (d.NewDeleteAt != nil && !d.NewDeleteAt.Equal(*tc.wantNew)) {
+				t.Errorf("Got new delete-at %v, want %v", d.NewDeleteAt, tc.wantNew)
+			}
+		})
+	}
+}
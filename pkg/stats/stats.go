@@ -1,6 +1,9 @@
 package stats
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Stats tracks processing statistics
 type Stats struct {
@@ -11,7 +14,31 @@ type Stats struct {
 	InvalidLabels        int
 	SkippedMissingOwner  int
 	SkippedInvalidDomain int
+	SkippedInvalidOwner  int
 	SkippedExistingUser  int
+	SkippedLookupFailed  int
+	SkippedRecentlyUsed  int
+	SkippedByPolicy      int
+	LabeledIdle          int
+	LabeledOwnerless     int
+	OwnersInferred       int
+	Quarantined          int
+	Restored             int
+	Stuck                int
+	Transferred          int
+
+	// Namespaces acted on and errors hit, for run reports
+	LabeledNamespaces      []string
+	DeletedNamespaces      []string
+	LabelRemovedNamespaces []string
+	Errors                 []string
+
+	// PendingDeletion maps namespaces still waiting for deletion to the
+	// time they will be deleted
+	PendingDeletion map[string]time.Time
+
+	// Decisions holds one record per namespace evaluated
+	Decisions []*Decision
 }
 
 // IncTotal increments total namespaces count
@@ -49,11 +76,98 @@ func (s *Stats) IncSkippedInvalidDomain() {
 	s.SkippedInvalidDomain++
 }
 
+// IncSkippedInvalidOwner increments unparsable owner skip count
+func (s *Stats) IncSkippedInvalidOwner() {
+	s.SkippedInvalidOwner++
+}
+
 // IncSkippedExistingUser increments existing user skip count
 func (s *Stats) IncSkippedExistingUser() {
 	s.SkippedExistingUser++
 }
 
+// IncSkippedLookupFailed increments failed owner lookup skip count
+func (s *Stats) IncSkippedLookupFailed() {
+	s.SkippedLookupFailed++
+}
+
+// IncSkippedRecentlyUsed increments recent activity skip count
+func (s *Stats) IncSkippedRecentlyUsed() {
+	s.SkippedRecentlyUsed++
+}
+
+// IncSkippedByPolicy increments policy rule skip count
+func (s *Stats) IncSkippedByPolicy() {
+	s.SkippedByPolicy++
+}
+
+// IncLabeledIdle increments namespaces labeled for inactivity count
+func (s *Stats) IncLabeledIdle() {
+	s.LabeledIdle++
+}
+
+// IncLabeledOwnerless increments namespaces labeled for having no owner count
+func (s *Stats) IncLabeledOwnerless() {
+	s.LabeledOwnerless++
+}
+
+// IncOwnersInferred increments namespaces whose missing owner was inferred
+// count
+func (s *Stats) IncOwnersInferred() {
+	s.OwnersInferred++
+}
+
+// IncQuarantined increments quarantined namespaces count
+func (s *Stats) IncQuarantined() {
+	s.Quarantined++
+}
+
+// IncRestored increments restored namespaces count
+func (s *Stats) IncRestored() {
+	s.Restored++
+}
+
+// IncStuck increments namespaces stuck terminating count
+func (s *Stats) IncStuck() {
+	s.Stuck++
+}
+
+// IncTransferred increments namespaces handed to a new owner count
+func (s *Stats) IncTransferred() {
+	s.Transferred++
+}
+
+// AddLabeled counts and records a labeled namespace
+func (s *Stats) AddLabeled(ns string) {
+	s.IncLabeled()
+	s.LabeledNamespaces = append(s.LabeledNamespaces, ns)
+}
+
+// AddDeleted counts and records a deleted namespace
+func (s *Stats) AddDeleted(ns string) {
+	s.IncDeleted()
+	s.DeletedNamespaces = append(s.DeletedNamespaces, ns)
+}
+
+// AddLabelRemoved counts and records a namespace whose label was removed
+func (s *Stats) AddLabelRemoved(ns string) {
+	s.IncLabelRemoved()
+	s.LabelRemovedNamespaces = append(s.LabelRemovedNamespaces, ns)
+}
+
+// AddPending records a namespace that will be deleted at deleteAt
+func (s *Stats) AddPending(ns string, deleteAt time.Time) {
+	if s.PendingDeletion == nil {
+		s.PendingDeletion = map[string]time.Time{}
+	}
+	s.PendingDeletion[ns] = deleteAt
+}
+
+// AddError records an error message
+func (s *Stats) AddError(msg string) {
+	s.Errors = append(s.Errors, msg)
+}
+
 // PrintSummary displays statistics summary
 func (s *Stats) PrintSummary() {
 	fmt.Println("\n============================")
@@ -61,11 +175,24 @@ func (s *Stats) PrintSummary() {
 	fmt.Println("----------------------------")
 	fmt.Printf("Namespaces checked:         %d\n", s.TotalNamespaces)
 	fmt.Printf("Labeled:                    %d\n", s.Labeled)
+	fmt.Printf("Labeled (idle):             %d\n", s.LabeledIdle)
+	fmt.Printf("Labeled (ownerless):        %d\n", s.LabeledOwnerless)
 	fmt.Printf("Deleted:                    %d\n", s.Deleted)
+	fmt.Printf("Pending deletion:           %d\n", len(s.PendingDeletion))
+	fmt.Printf("Quarantined:                %d\n", s.Quarantined)
+	fmt.Printf("Restored from quarantine:   %d\n", s.Restored)
+	fmt.Printf("Stuck terminating:          %d\n", s.Stuck)
+	fmt.Printf("Ownership transferred:      %d\n", s.Transferred)
 	fmt.Printf("Labels removed:             %d\n", s.LabelsRemoved)
 	fmt.Printf("Invalid labels:             %d\n", s.InvalidLabels)
 	fmt.Printf("Skipped (valid owner):      %d\n", s.SkippedExistingUser)
+	fmt.Printf("Owners inferred:            %d\n", s.OwnersInferred)
 	fmt.Printf("Skipped (missing owner):    %d\n", s.SkippedMissingOwner)
 	fmt.Printf("Skipped (invalid domain):   %d\n", s.SkippedInvalidDomain)
+	fmt.Printf("Skipped (invalid owner):    %d\n", s.SkippedInvalidOwner)
+	fmt.Printf("Skipped (lookup failed):    %d\n", s.SkippedLookupFailed)
+	fmt.Printf("Skipped (recently used):    %d\n", s.SkippedRecentlyUsed)
+	fmt.Printf("Skipped (policy rule):      %d\n", s.SkippedByPolicy)
+	fmt.Printf("Errors:                     %d\n", len(s.Errors))
 	fmt.Println("============================")
 }
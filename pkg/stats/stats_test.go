@@ -2,6 +2,7 @@ package stats
 
 import (
 	"testing"
+	"time"
 )
 
 func TestStatsIncrements(t *testing.T) {
@@ -16,6 +17,10 @@ func TestStatsIncrements(t *testing.T) {
 	s.IncSkippedMissingOwner()
 	s.IncSkippedInvalidDomain()
 	s.IncSkippedExistingUser()
+	s.IncSkippedLookupFailed()
+	s.IncQuarantined()
+	s.IncRestored()
+	s.IncStuck()
 
 	// Verify all increments
 	if s.TotalNamespaces != 1 {
@@ -42,6 +47,18 @@ func TestStatsIncrements(t *testing.T) {
 	if s.SkippedExistingUser != 1 {
 		t.Errorf("Expected SkippedExistingUser=1, got %d", s.SkippedExistingUser)
 	}
+	if s.SkippedLookupFailed != 1 {
+		t.Errorf("Expected SkippedLookupFailed=1, got %d", s.SkippedLookupFailed)
+	}
+	if s.Quarantined != 1 {
+		t.Errorf("Expected Quarantined=1, got %d", s.Quarantined)
+	}
+	if s.Restored != 1 {
+		t.Errorf("Expected Restored=1, got %d", s.Restored)
+	}
+	if s.Stuck != 1 {
+		t.Errorf("Expected Stuck=1, got %d", s.Stuck)
+	}
 
 	// Test multiple increments
 	s.IncTotal()
@@ -51,6 +68,33 @@ func TestStatsIncrements(t *testing.T) {
 	}
 }
 
+func TestStatsRecordNamespaces(t *testing.T) {
+	s := &Stats{}
+
+	s.AddLabeled("ns-a")
+	s.AddDeleted("ns-b")
+	s.AddLabelRemoved("ns-c")
+	s.AddError("Error deleting ns ns-d: boom")
+	deleteAt := time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)
+	s.AddPending("ns-a", deleteAt)
+
+	if s.Labeled != 1 || len(s.LabeledNamespaces) != 1 || s.LabeledNamespaces[0] != "ns-a" {
+		t.Errorf("Labeled not recorded: %d %v", s.Labeled, s.LabeledNamespaces)
+	}
+	if s.Deleted != 1 || len(s.DeletedNamespaces) != 1 || s.DeletedNamespaces[0] != "ns-b" {
+		t.Errorf("Deleted not recorded: %d %v", s.Deleted, s.DeletedNamespaces)
+	}
+	if s.LabelsRemoved != 1 || len(s.LabelRemovedNamespaces) != 1 || s.LabelRemovedNamespaces[0] != "ns-c" {
+		t.Errorf("Label removal not recorded: %d %v", s.LabelsRemoved, s.LabelRemovedNamespaces)
+	}
+	if len(s.Errors) != 1 {
+		t.Errorf("Expected 1 error, got %v", s.Errors)
+	}
+	if len(s.PendingDeletion) != 1 || !s.PendingDeletion["ns-a"].Equal(deleteAt) {
+		t.Errorf("Pending deletion not recorded: %v", s.PendingDeletion)
+	}
+}
+
 func TestPrintSummary(t *testing.T) {
 	s := &Stats{
 		TotalNamespaces:      5,